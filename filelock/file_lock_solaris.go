@@ -21,6 +21,12 @@ func (l lockCloser) Close() error {
 	return l.f.Close()
 }
 
+// Stat returns the FileInfo of the locked file, e.g. to check it was not
+// removed by its previous owner before it was locked.
+func (l lockCloser) Stat() (os.FileInfo, error) {
+	return l.f.Stat()
+}
+
 func Lock(name string) (io.Closer, error) {
 	f, err := os.Create(name)
 	if err != nil {
@@ -0,0 +1,327 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var errAsyncClosed = errors.New("async handler closed")
+
+const asyncBatchSize = 64
+
+//what AsyncHandler does when its queue is full
+const (
+	AsyncBlock      = iota //wait until there is room
+	AsyncDropOldest        //drop the oldest queued log
+	AsyncDropNew           //drop the log being written
+)
+
+//AsyncHandler queues logs and writes them to another handler in a background goroutine,
+//so a slow handler does not block the caller. Queued logs are written in batches
+//of at most 64 logs if the handler implements BatchWriter.
+//
+//Logs are written in the order they were queued: the queue is a FIFO channel
+//drained by a single goroutine, and a batch keeps the queue order. So the logs of
+//one goroutine are never reordered, and the logs of concurrent goroutines are
+//written in the order their Writes got into the queue. Dropping, by AsyncDropOldest
+//or AsyncDropNew, removes logs but never reorders the remaining ones.
+//Any future mode with more writing goroutines must keep the order per submitter.
+//
+//It is safe for concurrent use.
+type AsyncHandler struct {
+	mu     sync.RWMutex
+	closed bool
+	//closed by Shutdown first, so writers blocked on a full queue give up h.mu
+	quit     chan struct{}
+	stopping int32
+
+	h      Handler
+	policy int
+	queue  chan []byte
+
+	dropped int64
+	metrics metricsValue
+
+	//highFunc is called when the queue length reaches highMark,
+	//high is 1 until it drops below again, highMark is read by run atomically
+	highMark int32
+	highFunc func(queueLen int)
+	high     int32
+
+	//queued counts logs accepted by Write, done counts the written or dropped ones,
+	//Sync waits for done to reach queued.
+	cmu    sync.Mutex
+	cond   *sync.Cond
+	queued uint64
+	done   uint64
+
+	wg sync.WaitGroup
+}
+
+//NewAsyncHandler creates an AsyncHandler writing to h with a queue of size logs,
+//policy is one of AsyncBlock, AsyncDropOldest and AsyncDropNew.
+func NewAsyncHandler(h Handler, size int, policy int) (*AsyncHandler, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid queue size %d", size)
+	}
+
+	switch policy {
+	case AsyncBlock, AsyncDropOldest, AsyncDropNew:
+	default:
+		return nil, fmt.Errorf("invalid async policy %d", policy)
+	}
+
+	a := new(AsyncHandler)
+
+	a.h = h
+	a.policy = policy
+	a.queue = make(chan []byte, size)
+	a.quit = make(chan struct{})
+	a.cond = sync.NewCond(&a.cmu)
+
+	a.wg.Add(1)
+	go a.run()
+
+	return a, nil
+}
+
+func (h *AsyncHandler) run() {
+	defer h.wg.Done()
+
+	batch := make([][]byte, 0, asyncBatchSize)
+	for p := range h.queue {
+		batch = append(batch[0:0], p)
+
+		//coalesce queued logs into a batch, so the handler may write them at once
+	coalesce:
+		for len(batch) < asyncBatchSize {
+			select {
+			case p, ok := <-h.queue:
+				if !ok {
+					break coalesce
+				}
+				batch = append(batch, p)
+			default:
+				break coalesce
+			}
+		}
+
+		h.write(batch)
+		h.finish(len(batch))
+
+		if atomic.LoadInt32(&h.high) == 1 && len(h.queue) < int(atomic.LoadInt32(&h.highMark)) {
+			atomic.StoreInt32(&h.high, 0)
+		}
+	}
+}
+
+//write writes batch to the wrapped handler, logs failed to be written are reported
+//as dropped, all of them if it panicked, which is recovered so run goes on.
+func (h *AsyncHandler) write(batch [][]byte) {
+	m := h.metrics.load()
+	defer func() {
+		if e := recover(); e != nil {
+			backgroundPanic(e, m)
+			for range batch {
+				m.IncDropped()
+			}
+		}
+	}()
+
+	if w, ok := h.h.(BatchWriter); ok {
+		if _, err := w.WriteBatch(batch); err != nil {
+			for range batch {
+				m.IncDropped()
+			}
+		}
+		return
+	}
+
+	for _, p := range batch {
+		if _, err := h.h.Write(p); err != nil {
+			m.IncDropped()
+		}
+	}
+}
+
+func (h *AsyncHandler) enqueue() {
+	h.cmu.Lock()
+	h.queued++
+	h.cmu.Unlock()
+}
+
+func (h *AsyncHandler) finish(n int) {
+	h.cmu.Lock()
+	h.done += uint64(n)
+	h.cond.Broadcast()
+	h.cmu.Unlock()
+}
+
+//Write queues a copy of p, it never returns the wrapped handler's error.
+func (h *AsyncHandler) Write(p []byte) (n int, err error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return 0, errAsyncClosed
+	}
+
+	h.enqueue()
+	defer h.checkHighMark()
+
+	switch h.policy {
+	case AsyncBlock:
+		select {
+		case h.queue <- b:
+		case <-h.quit:
+			h.finish(1)
+			return 0, errAsyncClosed
+		}
+	case AsyncDropNew:
+		select {
+		case h.queue <- b:
+		default:
+			atomic.AddInt64(&h.dropped, 1)
+			h.metrics.load().IncDropped()
+			h.finish(1)
+		}
+	case AsyncDropOldest:
+		for {
+			select {
+			case h.queue <- b:
+				return len(p), nil
+			default:
+			}
+
+			select {
+			case <-h.queue:
+				atomic.AddInt64(&h.dropped, 1)
+				h.metrics.load().IncDropped()
+				h.finish(1)
+			default:
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+//checkHighMark calls highFunc if the queue just reached the high-water mark, h.mu must be held.
+func (h *AsyncHandler) checkHighMark() {
+	if h.highFunc == nil {
+		return
+	}
+
+	if n := len(h.queue); n >= int(h.highMark) && atomic.CompareAndSwapInt32(&h.high, 0, 1) {
+		h.highFunc(n)
+	}
+}
+
+//SetHighWaterMark makes fn be called with the queue length when it reaches mark,
+//to warn that logging can not keep up before logs are dropped or writers blocked.
+//fn is called again only after the queue drops below mark. It runs in the
+//writing goroutine and must not write to this handler.
+func (h *AsyncHandler) SetHighWaterMark(mark int, fn func(queueLen int)) {
+	h.mu.Lock()
+	atomic.StoreInt32(&h.highMark, int32(mark))
+	h.highFunc = fn
+	h.mu.Unlock()
+}
+
+//QueueLen returns the number of queued logs not being written yet.
+func (h *AsyncHandler) QueueLen() int {
+	return len(h.queue)
+}
+
+//QueueCap returns the size of the queue.
+func (h *AsyncHandler) QueueCap() int {
+	return cap(h.queue)
+}
+
+//SetMetrics makes logs dropped because the queue was full, or failed to be written
+//by the wrapped handler, be reported to m.IncDropped, and panics of the wrapped handler
+//to m.IncHandlerPanic if m is a HandlerPanicMetrics, the other methods of m are not
+//called, see Logger.SetMetrics. A nil m disables it.
+func (h *AsyncHandler) SetMetrics(m Metrics) {
+	h.metrics.store(m)
+}
+
+//Dropped returns the number of logs dropped because the queue was full.
+func (h *AsyncHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+//wait waits until the logs queued before the call are written.
+func (h *AsyncHandler) wait() {
+	h.cmu.Lock()
+	for n := h.queued; h.done < n; {
+		h.cond.Wait()
+	}
+	h.cmu.Unlock()
+}
+
+//Sync waits until the logs queued before the call are written, then syncs the wrapped handler.
+func (h *AsyncHandler) Sync() error {
+	h.wait()
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *AsyncHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush waits until the logs queued before the call are written, then flushes the wrapped handler.
+func (h *AsyncHandler) Flush() error {
+	h.wait()
+	return flushHandler(h.h)
+}
+
+//Close writes all queued logs, then syncs the wrapped handler if it implements Syncer,
+//once for all of them, and closes it, so every log queued before Close is on disk.
+func (h *AsyncHandler) Close() error {
+	return h.Shutdown(context.Background())
+}
+
+//Shutdown is like Close, but if ctx is done before the queued logs are written,
+//it gives up and returns an error with the number of lost logs,
+//the wrapped handler is not closed then, as it may be stuck.
+//Writes blocked on a full queue with AsyncBlock fail at once.
+func (h *AsyncHandler) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&h.stopping, 0, 1) {
+		return nil
+	}
+	close(h.quit)
+
+	h.mu.Lock()
+	h.closed = true
+	close(h.queue)
+	h.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		h.cmu.Lock()
+		lost := h.queued - h.done
+		h.cmu.Unlock()
+		return fmt.Errorf("async handler shutdown, %d logs lost: %w", lost, ctx.Err())
+	}
+
+	err := syncHandler(h.h)
+	if e := h.h.Close(); err == nil {
+		err = e
+	}
+	return err
+}
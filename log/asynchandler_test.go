@@ -0,0 +1,385 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//blockHandler blocks every write until release is closed.
+type blockHandler struct {
+	release chan struct{}
+	buf     lockedBuffer
+	closed  int32
+}
+
+func (h *blockHandler) Write(p []byte) (int, error) {
+	<-h.release
+	return h.buf.Write(p)
+}
+
+func (h *blockHandler) Close() error {
+	atomic.StoreInt32(&h.closed, 1)
+	return nil
+}
+
+func TestAsyncHandler(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, err := NewAsyncHandler(s, 16, AsyncBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := []byte("hello\n")
+	h.Write(p)
+	//the handler must copy p
+	copy(p, "xxxxx\n")
+	h.Write([]byte("world\n"))
+
+	h.Close()
+
+	if buf.String() != "hello\nworld\n" {
+		t.Fatal(buf.String())
+	}
+
+	if _, err := h.Write(p); err == nil {
+		t.Fatal("must fail after close")
+	}
+}
+
+func TestAsyncHandlerDrop(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+
+	h, _ := NewAsyncHandler(b, 2, AsyncDropOldest)
+
+	for _, s := range []string{"1", "2", "3", "4", "5"} {
+		h.Write([]byte(s))
+	}
+
+	close(b.release)
+	h.Close()
+
+	//the first one may be taken by the writer goroutine before the others are dropped
+	if s := b.buf.String(); s != "45" && s != "145" {
+		t.Fatal(s)
+	} else if h.Dropped() != int64(5-len(s)) {
+		t.Fatal(h.Dropped())
+	}
+}
+
+func TestAsyncHandlerFatalSync(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewAsyncHandler(s, 16, AsyncBlock)
+
+	l := New(h, 0)
+	l.Info("hello")
+	l.Fatal("world")
+
+	//Fatal returns after the async handler drained
+	if buf.String() != "hello\nworld\n" {
+		t.Fatal(buf.String())
+	}
+
+	l.Close()
+}
+
+func TestAsyncHandlerBatch(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewAsyncHandler(s, 16, AsyncBlock)
+	h.Write([]byte("1\n"))
+	h.Write([]byte("2\n"))
+	h.Close()
+
+	if buf.String() != "1\n2\n" {
+		t.Fatal(buf.String())
+	}
+
+	//no BatchWriter, logs are written one by one
+	h, _ = NewAsyncHandler(b, 16, AsyncBlock)
+	h.Write([]byte("1\n"))
+	h.Write([]byte("2\n"))
+	close(b.release)
+	h.Close()
+
+	if b.buf.String() != "1\n2\n" {
+		t.Fatal(b.buf.String())
+	}
+}
+
+func TestAsyncHandlerOrder(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	//a small queue, so writers block and batches are coalesced
+	h, _ := NewAsyncHandler(s, 4, AsyncBlock)
+
+	const writers, n = 8, 500
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				h.Write([]byte(fmt.Sprintf("%d %d\n", w, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	h.Close()
+
+	//the logs of each writer are in order
+	next := make([]int, writers)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var w, i int
+		if _, err := fmt.Sscanf(line, "%d %d", &w, &i); err != nil {
+			t.Fatal(line, err)
+		}
+		if i != next[w] {
+			t.Fatalf("writer %d: got %d, want %d", w, i, next[w])
+		}
+		next[w]++
+	}
+
+	for w, i := range next {
+		if i != n {
+			t.Fatal(w, i)
+		}
+	}
+}
+
+func benchmarkAsyncHandler(b *testing.B, h Handler) {
+	a, _ := NewAsyncHandler(h, 1024, AsyncBlock)
+	p := []byte(strings.Repeat("hello world, this is a log line. ", 4) + "\n")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Write(p)
+	}
+	a.Close()
+}
+
+//onlyWriteHandler hides the WriteBatch of its handler.
+type onlyWriteHandler struct {
+	h Handler
+}
+
+func (h onlyWriteHandler) Write(p []byte) (int, error) {
+	return h.h.Write(p)
+}
+
+func (h onlyWriteHandler) Close() error {
+	return h.h.Close()
+}
+
+func BenchmarkAsyncFileHandler(b *testing.B) {
+	h, _ := NewFileHandler(os.DevNull, os.O_WRONLY)
+	benchmarkAsyncHandler(b, onlyWriteHandler{h})
+}
+
+func BenchmarkAsyncFileHandlerBatch(b *testing.B) {
+	h, _ := NewFileHandler(os.DevNull, os.O_WRONLY)
+	benchmarkAsyncHandler(b, h)
+}
+
+func TestAsyncHandlerShutdown(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+	defer close(b.release)
+
+	h, _ := NewAsyncHandler(b, 16, AsyncBlock)
+	h.Write([]byte("1\n"))
+	h.Write([]byte("2\n"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := h.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "2 logs lost") {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncHandlerShutdownBlockedWrite(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+	defer close(b.release)
+
+	h, _ := NewAsyncHandler(b, 1, AsyncBlock)
+	h.Write([]byte("1\n"))
+	time.Sleep(10 * time.Millisecond)
+	h.Write([]byte("2\n"))
+
+	//blocked on the full queue, as 1 is stuck in b
+	written := make(chan error, 1)
+	go func() {
+		_, err := h.Write([]byte("3\n"))
+		written <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Shutdown(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("must time out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown ignored ctx")
+	}
+	if err := <-written; err != errAsyncClosed {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncHandlerHighWaterMark(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+	h, _ := NewAsyncHandler(b, 4, AsyncBlock)
+
+	var calls []int
+	h.SetHighWaterMark(3, func(n int) {
+		calls = append(calls, n)
+	})
+
+	for i := 0; i < 4; i++ {
+		h.Write([]byte("hello\n"))
+	}
+
+	if len(calls) != 1 || calls[0] < 3 {
+		t.Fatal(calls)
+	}
+	if h.QueueLen() < 3 || h.QueueCap() != 4 {
+		t.Fatal(h.QueueLen(), h.QueueCap())
+	}
+
+	close(b.release)
+	h.Sync()
+	if h.QueueLen() != 0 {
+		t.Fatal(h.QueueLen())
+	}
+
+	h.Close()
+}
+
+//durableHandler counts the logs written when it is synced and closed.
+type durableHandler struct {
+	n      int
+	synced int
+	closed bool
+}
+
+func (h *durableHandler) Write(p []byte) (int, error) {
+	h.n++
+	return len(p), nil
+}
+
+func (h *durableHandler) Sync() error {
+	if h.closed {
+		return ErrHandlerClosed
+	}
+	h.synced = h.n
+	return nil
+}
+
+func (h *durableHandler) Close() error {
+	h.closed = true
+	return nil
+}
+
+func TestAsyncHandlerCloseSync(t *testing.T) {
+	d := new(durableHandler)
+	h, _ := NewAsyncHandler(d, 16, AsyncBlock)
+	for i := 0; i < 100; i++ {
+		h.Write([]byte("a\n"))
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.synced != 100 || !d.closed {
+		t.Fatal(d.synced, d.closed)
+	}
+
+	//the file read again, as after a restart, has every queued log
+	name := "./test_log_async_close"
+	os.Remove(name)
+	defer os.Remove(name)
+
+	f, err := NewFileHandler(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ = NewAsyncHandler(f, 16, AsyncBlock)
+	l := New(h, 0)
+	for i := 0; i < 1000; i++ {
+		l.Infof("%d", i)
+	}
+	l.Close()
+
+	b, _ := ioutil.ReadFile(name)
+	if lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); len(lines) != 1000 || lines[999] != "999" {
+		t.Fatal(len(lines))
+	}
+}
+
+//panicWriter panics writing logs with boom, and fails writing logs with fail.
+type panicWriter struct {
+	lockedBuffer
+}
+
+func (w *panicWriter) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("boom")) {
+		panic("bug")
+	} else if bytes.Contains(p, []byte("fail")) {
+		return 0, errors.New("failed")
+	}
+	return w.lockedBuffer.Write(p)
+}
+
+func TestAsyncHandlerPanic(t *testing.T) {
+	var fallback lockedBuffer
+	handlerPanicFallback = &fallback
+	defer func() {
+		handlerPanicFallback = os.Stderr
+	}()
+
+	w := new(panicWriter)
+	s, _ := NewStreamHandler(w)
+	h, _ := NewAsyncHandler(s, 16, AsyncBlock)
+	m := &testMetrics{levels: make(map[int]int)}
+	h.SetMetrics(m)
+
+	//flushed one by one, so the logs are not in the batch of another
+	for _, line := range []string{"a\n", "boom\n", "fail\n", "b\n"} {
+		h.Write([]byte(line))
+		h.Flush()
+	}
+	h.Close()
+
+	if w.String() != "a\nb\n" || !strings.Contains(fallback.String(), "bug") {
+		t.Fatal(w.String(), fallback.String())
+	}
+	if m.handlerPanics != 1 || m.dropped != 2 {
+		t.Fatal(m.handlerPanics, m.dropped)
+	}
+}
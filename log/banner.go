@@ -0,0 +1,94 @@
+package log
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+//messages of the banners, grep "=== start" to find where a process started logging
+const (
+	bannerStart = "=== start ==="
+	bannerStop  = "=== stop ==="
+)
+
+//processStart is when the process started, more exactly this package.
+var processStart = time.Now()
+
+//banner is written by the logger l with fields, started is set once its start is written.
+type banner struct {
+	l       *Logger
+	fields  map[string]interface{}
+	started bool
+}
+
+//Banner writes a start banner now, an Info log "=== start ===" with fields,
+//e.g. the app name and version, and the pid and start time of the process,
+//whatever the level is. Close writes a stop banner "=== stop ===" with the same
+//fields and the uptime, so log segments are easily matched with process lifecycles,
+//across rotations too. A banner set again replaces the former one.
+func (l *Logger) Banner(fields map[string]interface{}) {
+	b := &banner{l: l, fields: fields, started: true}
+	l.s.setBanner(b, false)
+	b.write(2, bannerStart)
+}
+
+//SetAutoBanner is like Banner, but the start banner is written right before the
+//first log written after, so it is formatted like the logs once the logger is configured.
+//Nothing is written on Close if no log was.
+func (l *Logger) SetAutoBanner(fields map[string]interface{}) {
+	l.s.setBanner(&banner{l: l, fields: fields}, true)
+}
+
+func (s *sink) setBanner(b *banner, pending bool) {
+	s.bannerMu.Lock()
+	s.banner = b
+	if pending {
+		atomic.StoreInt32(&s.bannerPending, 1)
+	} else {
+		atomic.StoreInt32(&s.bannerPending, 0)
+	}
+	s.bannerMu.Unlock()
+}
+
+//startBanner writes the pending start banner, before the log of callDepth.
+func (s *sink) startBanner(callDepth int) {
+	s.bannerMu.Lock()
+	b := s.banner
+	if b == nil || atomic.LoadInt32(&s.bannerPending) == 0 {
+		s.bannerMu.Unlock()
+		return
+	}
+	atomic.StoreInt32(&s.bannerPending, 0)
+	b.started = true
+	s.bannerMu.Unlock()
+
+	b.write(callDepth+1, bannerStart)
+}
+
+//stopBanner writes the stop banner once, if the start banner was written.
+func (s *sink) stopBanner() {
+	s.bannerMu.Lock()
+	b := s.banner
+	s.banner = nil
+	atomic.StoreInt32(&s.bannerPending, 0)
+	s.bannerMu.Unlock()
+
+	if b != nil && b.started {
+		b.write(3, bannerStop)
+	}
+}
+
+func (b *banner) write(callDepth int, msg string) {
+	m := make(map[string]interface{}, len(b.fields)+3)
+	for k, v := range b.fields {
+		m[k] = v
+	}
+	m["pid"] = os.Getpid()
+	m["start"] = processStart.Format(time.RFC3339)
+	if msg == bannerStop {
+		m["uptime"] = time.Since(processStart).Round(time.Millisecond)
+	}
+
+	b.l.WithFields(m).output(callDepth+1, LevelInfo, msg)
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoggerBanner(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	l := New(th, Lfile)
+	l.SetLevel(LevelError)
+	l.Banner(map[string]interface{}{"app": "test", "version": "1.0"})
+	l.Info("filtered")
+	l.Error("failed")
+	l.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal(buf.String())
+	}
+
+	start := fmt.Sprintf(`^banner_test.go:\d+ === start === app=test pid=%d start=\S+ version=1.0$`, os.Getpid())
+	if !regexp.MustCompile(start).MatchString(lines[0]) {
+		t.Fatal(lines[0])
+	}
+	stop := fmt.Sprintf(`^banner_test.go:\d+ === stop === app=test pid=%d start=\S+ uptime=\S+ version=1.0$`, os.Getpid())
+	if !regexp.MustCompile(stop).MatchString(lines[2]) {
+		t.Fatal(lines[2])
+	}
+}
+
+func TestLoggerAutoBanner(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	l := New(th, 0)
+	l.SetAutoBanner(map[string]interface{}{"app": "test"})
+	l.SetFlags(Ljson)
+	l.Info("hello")
+	l.Info("world")
+	l.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatal(buf.String())
+	}
+	if !strings.HasPrefix(lines[0], `{"msg":"=== start ===","app":"test","pid":`) ||
+		lines[1] != `{"msg":"hello"}` || !strings.HasPrefix(lines[3], `{"msg":"=== stop ===",`) {
+		t.Fatal(buf.String())
+	}
+
+	//no log, no banner
+	th, buf = NewTestHandler()
+	l = New(th, 0)
+	l.SetAutoBanner(nil)
+	l.Close()
+
+	if buf.Len() != 0 {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,289 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//BufferedHandler buffers logs in memory and writes them to another handler
+//when the buffer is full, on Flush, every flush interval, and every N records
+//if set by SetFlushEvery, whichever comes first.
+//
+//It is safe for concurrent use.
+type BufferedHandler struct {
+	mu sync.Mutex
+
+	h Handler
+	w *bufio.Writer
+
+	//buffer size bounds if adaptive, see SetAdaptive,
+	//written counts bytes written since last Flush
+	minSize int
+	maxSize int
+	written int
+
+	//logs with level >= flushLevel are flushed at once, see SetFlushLevel
+	flushLevel int
+
+	//flushed every flushEvery records, records counts them split on
+	//terminator since last Flush, see SetFlushEvery
+	flushEvery int
+	records    int
+	terminator []byte
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+	//set by Shutdown atomically, as h.mu may be held by a stuck flush
+	closed int32
+
+	metrics metricsValue
+}
+
+//NewBufferedHandler creates a BufferedHandler with a size bytes buffer writing to h,
+//the buffer is flushed every interval, an interval <= 0 disables periodic flush.
+func NewBufferedHandler(h Handler, size int, interval time.Duration) (*BufferedHandler, error) {
+	b := new(BufferedHandler)
+
+	b.h = h
+	b.w = bufio.NewWriterSize(h, size)
+	b.flushLevel = math.MaxInt32
+	b.terminator = []byte{'\n'}
+
+	b.quit = make(chan struct{})
+
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.run(interval)
+	}
+
+	return b, nil
+}
+
+func (h *BufferedHandler) run(interval time.Duration) {
+	defer h.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.periodicFlush()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+//periodicFlush flushes in run, a panic of the wrapped handler is recovered so the
+//periodic flush goes on, and the buffered logs are dropped, or it panics again.
+func (h *BufferedHandler) periodicFlush() {
+	defer func() {
+		if e := recover(); e != nil {
+			h.mu.Lock()
+			h.w.Reset(h.h)
+			h.mu.Unlock()
+
+			backgroundPanic(e, h.metrics.load())
+		}
+	}()
+
+	h.Flush()
+}
+
+//SetMetrics makes panics of the wrapped handler in the periodic flush be reported to
+//m.IncHandlerPanic if m is a HandlerPanicMetrics. Other flushes panic in the caller,
+//the Logger recovers them, see Logger.SetRecoverPanics. A nil m disables it.
+func (h *BufferedHandler) SetMetrics(m Metrics) {
+	h.metrics.store(m)
+}
+
+func (h *BufferedHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel buffers p, and flushes it with buffered logs before it if level >= the flush level.
+func (h *BufferedHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	var flush bool
+	if n, flush, err = h.buffer(level, p); err == nil && flush {
+		err = h.Flush()
+	}
+	return
+}
+
+//buffer buffers p, h.mu is unlocked even if the wrapped handler panics writing a full buffer.
+func (h *BufferedHandler) buffer(level int, p []byte) (n int, flush bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	flush = level >= h.flushLevel
+	if h.maxSize > 0 && len(p) > h.w.Available() && h.w.Size() < h.maxSize {
+		//the buffer is full before the flush interval, grow it for the burst
+		if err = h.resize(h.w.Size() * 2); err != nil {
+			return
+		}
+	}
+	n, err = h.w.Write(p)
+	h.written += n
+	if h.flushEvery > 0 {
+		h.records += bytes.Count(p, h.terminator)
+		flush = flush || h.records >= h.flushEvery
+	}
+	return
+}
+
+//SetFlushLevel makes logs with level >= level, e.g. LevelError, be flushed at once,
+//while lower levels stay buffered. It is disabled by default.
+func (h *BufferedHandler) SetFlushLevel(level int) {
+	h.mu.Lock()
+	h.flushLevel = level
+	h.mu.Unlock()
+}
+
+//SetFlushEvery makes buffered logs be flushed once n records are written since last
+//flush, so a log is visible after at most n-1 more, a record ends with the terminator.
+//An n <= 0, the default, disables it.
+func (h *BufferedHandler) SetFlushEvery(n int) {
+	h.mu.Lock()
+	h.flushEvery = n
+	h.records = 0
+	h.mu.Unlock()
+}
+
+//SetTerminator sets the terminator records are counted by, it must be the one set
+//with Logger.SetTerminator, default is a newline. An empty s is ignored.
+func (h *BufferedHandler) SetTerminator(s string) {
+	if s == "" {
+		return
+	}
+
+	h.mu.Lock()
+	h.terminator = []byte(s)
+	h.mu.Unlock()
+}
+
+//Flush writes all buffered logs to the wrapped handler, then flushes it.
+//If adaptive, the buffer shrinks if less than a quarter of it was written since last Flush.
+func (h *BufferedHandler) Flush() error {
+	if err := h.flush(); err != nil {
+		return err
+	}
+	return flushHandler(h.h)
+}
+
+//flush writes all buffered logs to the wrapped handler, h.mu is locked and
+//unlocked even if the wrapped handler panics.
+func (h *BufferedHandler) flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	written := h.written
+	h.written = 0
+	h.records = 0
+
+	if h.maxSize > 0 && written < h.w.Size()/4 && h.w.Size() > h.minSize {
+		return h.resize(h.w.Size() / 2)
+	}
+	return h.w.Flush()
+}
+
+//resize flushes the buffer and replaces it with one of size within the bounds.
+func (h *BufferedHandler) resize(size int) error {
+	if size > h.maxSize {
+		size = h.maxSize
+	} else if size < h.minSize {
+		size = h.minSize
+	}
+
+	if err := h.w.Flush(); err != nil {
+		return err
+	}
+
+	if size != h.w.Size() {
+		h.w = bufio.NewWriterSize(h.h, size)
+	}
+	return nil
+}
+
+//SetAdaptive makes the buffer size adapt to the load within [minSize, maxSize],
+//it doubles when the buffer fills up before a flush, and halves when less than
+//a quarter of it is written between flushes, e.g. when idle.
+func (h *BufferedHandler) SetAdaptive(minSize, maxSize int) error {
+	if minSize <= 0 || maxSize < minSize {
+		return fmt.Errorf("invalid buffer size bounds [%d, %d]", minSize, maxSize)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.minSize = minSize
+	h.maxSize = maxSize
+	return h.resize(h.w.Size())
+}
+
+//Size returns the current buffer size.
+func (h *BufferedHandler) Size() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.w.Size()
+}
+
+//Sync flushes buffered logs and syncs the wrapped handler.
+func (h *BufferedHandler) Sync() error {
+	if err := h.Flush(); err != nil {
+		return err
+	}
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *BufferedHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Close stops the periodic flush, flushes buffered logs and closes the wrapped handler,
+//closing it again does nothing.
+func (h *BufferedHandler) Close() error {
+	return h.Shutdown(context.Background())
+}
+
+//Shutdown is like Close, but if ctx is done before buffered logs are flushed,
+//it gives up and returns an error with the number of lost bytes, unknown if a flush
+//was stuck already, the wrapped handler is not closed then, as it may be stuck.
+func (h *BufferedHandler) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&h.closed, 0, 1) {
+		return nil
+	}
+	close(h.quit)
+
+	//h.mu is held by a flush stuck in the wrapped handler, ctx must still be honored
+	lost := "unknown"
+	if h.mu.TryLock() {
+		lost = strconv.Itoa(h.w.Buffered())
+		h.mu.Unlock()
+	}
+
+	flushed := make(chan error, 1)
+	go func() {
+		h.wg.Wait()
+		flushed <- h.Flush()
+	}()
+
+	select {
+	case err := <-flushed:
+		if e := h.h.Close(); err == nil {
+			err = e
+		}
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("buffered handler shutdown, %s bytes lost: %w", lost, ctx.Err())
+	}
+}
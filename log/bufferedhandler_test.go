@@ -0,0 +1,273 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestBufferedHandler(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewBufferedHandler(s, 1024, 10*time.Millisecond)
+
+	h.Write([]byte("hello\n"))
+	if buf.String() != "" {
+		t.Fatal(buf.String())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if buf.String() != "hello\n" {
+		t.Fatal(buf.String())
+	}
+
+	h.Write([]byte("world\n"))
+	h.Close()
+
+	if buf.String() != "hello\nworld\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestBufferedHandlerShutdown(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+
+	h, _ := NewBufferedHandler(b, 1024, 0)
+	h.Write([]byte("hello\n"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := h.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "6 bytes lost") {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the stuck handler is not closed when it comes back
+	close(b.release)
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&b.closed) != 0 {
+		t.Fatal("must not be closed")
+	}
+}
+
+func TestBufferedHandlerShutdownStuckFlush(t *testing.T) {
+	b := &blockHandler{release: make(chan struct{})}
+	defer close(b.release)
+
+	h, _ := NewBufferedHandler(b, 1024, time.Millisecond)
+	h.Write([]byte("hello\n"))
+	//the periodic flush is stuck in b with h.mu held
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Shutdown(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "unknown bytes lost") {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown ignored ctx")
+	}
+}
+
+func TestBufferedHandlerAdaptive(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewBufferedHandler(s, 16, 0)
+	if err := h.SetAdaptive(32, 128); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.Size(); n != 32 {
+		t.Fatal(n)
+	}
+
+	p := []byte(strings.Repeat("x", 15) + "\n")
+	for i := 0; i < 16; i++ {
+		h.Write(p)
+	}
+	if n := h.Size(); n != 128 {
+		t.Fatal(n)
+	}
+
+	//idle flushes shrink it
+	h.Flush()
+	h.Flush()
+	h.Flush()
+	if n := h.Size(); n != 32 {
+		t.Fatal(n)
+	}
+
+	h.Close()
+
+	if buf.String() != strings.Repeat(string(p), 16) {
+		t.Fatal(buf.String())
+	}
+
+	if err := h.SetAdaptive(0, 1); err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+//syscallHandler pretends every write is a syscall.
+type syscallHandler struct {
+}
+
+func (h syscallHandler) Write(p []byte) (int, error) {
+	time.Sleep(time.Microsecond)
+	return len(p), nil
+}
+
+func (h syscallHandler) Close() error {
+	return nil
+}
+
+func benchmarkBufferedHandlerBurst(b *testing.B, adaptive bool) {
+	h, _ := NewBufferedHandler(syscallHandler{}, 1024, 0)
+	if adaptive {
+		h.SetAdaptive(1024, 64<<10)
+	}
+
+	p := []byte(strings.Repeat("hello world, this is a log line. ", 4) + "\n")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		//bursts of 256 logs, then a flush
+		for j := 0; j < 256; j++ {
+			h.Write(p)
+		}
+		h.Flush()
+	}
+	h.Close()
+}
+
+func BenchmarkBufferedHandlerBurstFixed(b *testing.B) {
+	benchmarkBufferedHandlerBurst(b, false)
+}
+
+func BenchmarkBufferedHandlerBurstAdaptive(b *testing.B) {
+	benchmarkBufferedHandlerBurst(b, true)
+}
+
+func TestBufferedHandlerFlushLevel(t *testing.T) {
+	th, buf := NewTestHandler()
+	h, _ := NewBufferedHandler(th, 4096, 0)
+	h.SetFlushLevel(LevelError)
+
+	h.WriteLevel(LevelInfo, []byte("info\n"))
+	if th.String() != "" {
+		t.Fatal(th.String())
+	}
+
+	h.WriteLevel(LevelError, []byte("error\n"))
+	if th.String() != "info\nerror\n" {
+		t.Fatal(th.String())
+	}
+
+	h.Write([]byte("buffered\n"))
+	h.Close()
+
+	if buf.String() != "info\nerror\nbuffered\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestBufferedHandlerFlushEvery(t *testing.T) {
+	th, buf := NewTestHandler()
+	h, _ := NewBufferedHandler(th, 4096, 0)
+	h.SetFlushEvery(3)
+
+	h.Write([]byte("1\n"))
+	h.Write([]byte("2\n"))
+	if th.String() != "" {
+		t.Fatal(th.String())
+	}
+
+	//a batch of two records reaches the count
+	h.Write([]byte("3\n4\n"))
+	if th.String() != "1\n2\n3\n4\n" {
+		t.Fatal(th.String())
+	}
+
+	//the count starts again after a flush
+	h.SetTerminator("\r\n")
+	h.Write([]byte("5\r\n"))
+	h.Flush()
+	h.Write([]byte("6\r\n"))
+	h.Write([]byte("7\r\n"))
+	if th.String() != "1\n2\n3\n4\n5\r\n" {
+		t.Fatal(th.String())
+	}
+
+	h.Close()
+	if buf.String() != "1\n2\n3\n4\n5\r\n6\r\n7\r\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestBufferedHandlerPeriodicFlushPanic(t *testing.T) {
+	var fallback lockedBuffer
+	handlerPanicFallback = &fallback
+	defer func() {
+		handlerPanicFallback = os.Stderr
+	}()
+
+	w := new(panicWriter)
+	s, _ := NewStreamHandler(w)
+	h, _ := NewBufferedHandler(s, 1024, time.Millisecond)
+	m := &testMetrics{levels: make(map[int]int)}
+	h.SetMetrics(m)
+
+	h.Write([]byte("boom\n"))
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		m.mu.Lock()
+		n := m.handlerPanics
+		m.mu.Unlock()
+		if n > 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("periodic flush did not panic")
+		}
+	}
+
+	//the log it panicked on is dropped, so the periodic flush goes on
+	h.Write([]byte("a\n"))
+	h.Close()
+	if w.String() != "a\n" || !strings.Contains(fallback.String(), "bug") {
+		t.Fatal(w.String(), fallback.String())
+	}
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+//ChannelHandler sends every log to the channels of its subscribers,
+//e.g. to stream logs to a browser over a websocket.
+//
+//Logging never blocks: a log is dropped for a subscriber whose channel is full,
+//and counted in Dropped. Every subscriber gets its own copy of the log.
+//
+//It is safe for concurrent use.
+type ChannelHandler struct {
+	//first to be 64-bit aligned for atomic
+	dropped int64
+
+	mu sync.RWMutex
+
+	size   int
+	subs   map[<-chan []byte]chan []byte
+	closed bool
+}
+
+//NewChannelHandler creates a ChannelHandler whose subscriber channels buffer size logs.
+func NewChannelHandler(size int) (*ChannelHandler, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid channel size %d", size)
+	}
+
+	h := new(ChannelHandler)
+
+	h.size = size
+	h.subs = make(map[<-chan []byte]chan []byte)
+
+	return h, nil
+}
+
+//Subscribe returns a channel receiving the logs written from now on,
+//it is closed by Unsubscribe or Close. The channel is closed at once if h is closed.
+func (h *ChannelHandler) Subscribe() <-chan []byte {
+	ch := make(chan []byte, h.size)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch
+	}
+
+	h.subs[ch] = ch
+	return ch
+}
+
+//Unsubscribe stops sending logs to ch and closes it.
+func (h *ChannelHandler) Unsubscribe(ch <-chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(c)
+	}
+}
+
+func (h *ChannelHandler) Write(p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, ch := range h.subs {
+		//p is reused after Write returns
+		b := append([]byte(nil), p...)
+
+		select {
+		case ch <- b:
+		default:
+			atomic.AddInt64(&h.dropped, 1)
+		}
+	}
+
+	return len(p), nil
+}
+
+//Dropped returns the number of logs dropped because a subscriber channel was full,
+//a log dropped for two subscribers is counted twice.
+func (h *ChannelHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+//Close closes the channels of all subscribers.
+func (h *ChannelHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for k, ch := range h.subs {
+		delete(h.subs, k)
+		close(ch)
+	}
+	h.closed = true
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestChannelHandler(t *testing.T) {
+	h, err := NewChannelHandler(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := h.Subscribe()
+	c2 := h.Subscribe()
+
+	p := []byte("a\n")
+	h.Write(p)
+	p[0] = 'b'
+	h.Write(p)
+	h.Write(p)
+
+	for _, ch := range []<-chan []byte{c1, c2} {
+		if s := string(<-ch); s != "a\n" {
+			t.Fatal(s)
+		}
+		if s := string(<-ch); s != "b\n" {
+			t.Fatal(s)
+		}
+	}
+
+	//the third log was full for both
+	if n := h.Dropped(); n != 2 {
+		t.Fatal(n)
+	}
+
+	h.Unsubscribe(c1)
+	if _, ok := <-c1; ok {
+		t.Fatal("must be closed")
+	}
+
+	h.Write([]byte("c\n"))
+	if s := string(<-c2); s != "c\n" {
+		t.Fatal(s)
+	}
+
+	h.Close()
+	if _, ok := <-c2; ok {
+		t.Fatal("must be closed")
+	}
+	if _, ok := <-h.Subscribe(); ok {
+		t.Fatal("must be closed")
+	}
+	h.Write([]byte("d\n"))
+
+	if _, err := NewChannelHandler(0); err == nil {
+		t.Fatal("must fail")
+	}
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+//WithContextKey returns a logger whose Context methods log the value stored
+//under key in the context, e.g. a request id, as a field named fmt.Sprint(key).
+//
+//The returned logger shares the handler and level with l.
+func (l *Logger) WithContextKey(key interface{}) *Logger {
+	n := l.derive()
+	n.ctxKey = key
+	return n
+}
+
+//withContext returns a logger with the context key value as a field,
+//or l itself if ctx has no value for the key.
+func (l *Logger) withContext(ctx context.Context) *Logger {
+	if l.ctxKey == nil || ctx == nil {
+		return l
+	}
+
+	v := ctx.Value(l.ctxKey)
+	if v == nil {
+		return l
+	}
+
+	return l.WithFields(map[string]interface{}{fmt.Sprint(l.ctxKey): v})
+}
+
+//log with Trace level and the context key value
+func (l *Logger) TraceContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelTrace) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelTrace, fmt.Sprint(v...))
+}
+
+//log with Debug level and the context key value
+func (l *Logger) DebugContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelDebug) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelDebug, fmt.Sprint(v...))
+}
+
+//log with info level and the context key value
+func (l *Logger) InfoContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelInfo) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelInfo, fmt.Sprint(v...))
+}
+
+//log with warn level and the context key value
+func (l *Logger) WarnContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelWarn) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelWarn, fmt.Sprint(v...))
+}
+
+//log with error level and the context key value
+func (l *Logger) ErrorContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelError) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelError, fmt.Sprint(v...))
+}
+
+//log with fatal level and the context key value
+func (l *Logger) FatalContext(ctx context.Context, v ...interface{}) {
+	if !l.enabled(LevelFatal) {
+		return
+	}
+	l.withContext(ctx).Output(2, LevelFatal, fmt.Sprint(v...))
+}
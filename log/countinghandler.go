@@ -0,0 +1,153 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//CountingHandler counts the bytes and lines of logs written to another handler,
+//e.g. to export log volume as metrics. Lines are split on its terminator,
+//"\n" by default, see SetTerminator.
+//
+//RateBytesPerSec tells the rate logs are written at, over the last minute by default,
+//with maxBytes and backupCount of rotation it tells how long logs are kept.
+//
+//It is safe for concurrent use if the wrapped handler is.
+type CountingHandler struct {
+	//first to be 64-bit aligned for atomic
+	bytes int64
+	lines int64
+
+	h          Handler
+	terminator []byte
+
+	//bytes written in each second of the rate window, buckets[i] is
+	//for the second secs[i], start is when counting started
+	mu      sync.Mutex
+	buckets []int64
+	secs    []int64
+	start   int64
+	now     func() time.Time
+}
+
+func NewCountingHandler(h Handler) (*CountingHandler, error) {
+	c := new(CountingHandler)
+
+	c.h = h
+	c.terminator = []byte{'\n'}
+	c.now = time.Now
+	c.SetRateWindow(time.Minute)
+
+	return c, nil
+}
+
+//SetRateWindow sets the sliding window RateBytesPerSec is computed over,
+//in whole seconds, default is a minute. Counting starts again.
+func (h *CountingHandler) SetRateWindow(d time.Duration) error {
+	n := int(d / time.Second)
+	if n < 1 {
+		return fmt.Errorf("invalid rate window %v", d)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets = make([]int64, n)
+	h.secs = make([]int64, n)
+	h.start = h.now().Unix()
+	return nil
+}
+
+//addRate counts n bytes written now.
+func (h *CountingHandler) addRate(n int) {
+	sec := h.now().Unix()
+
+	h.mu.Lock()
+	i := int(sec % int64(len(h.buckets)))
+	if h.secs[i] != sec {
+		h.secs[i] = sec
+		h.buckets[i] = 0
+	}
+	h.buckets[i] += int64(n)
+	h.mu.Unlock()
+}
+
+//RateBytesPerSec returns the bytes written per second over the rate window,
+//or since counting started if it is shorter, see SetRateWindow.
+func (h *CountingHandler) RateBytesPerSec() float64 {
+	sec := h.now().Unix()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	window := int64(len(h.buckets))
+	var sum int64
+	for i, s := range h.secs {
+		if s > sec-window && s <= sec {
+			sum += h.buckets[i]
+		}
+	}
+
+	if elapsed := sec - h.start + 1; elapsed < window {
+		window = elapsed
+	}
+	return float64(sum) / float64(window)
+}
+
+//SetTerminator sets the terminator lines are counted by, it must be the one set
+//with Logger.SetTerminator, and must not be called concurrently with Write.
+//An empty s is ignored.
+func (h *CountingHandler) SetTerminator(s string) {
+	if s != "" {
+		h.terminator = []byte(s)
+	}
+}
+
+func (h *CountingHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p to the wrapped handler and counts the bytes written,
+//and the lines of p if the write succeeds.
+func (h *CountingHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	n, err = writeLevel(h.h, level, p)
+
+	atomic.AddInt64(&h.bytes, int64(n))
+	h.addRate(n)
+	if err == nil {
+		atomic.AddInt64(&h.lines, int64(bytes.Count(p, h.terminator)))
+	}
+	return
+}
+
+//Bytes returns the number of bytes written.
+func (h *CountingHandler) Bytes() int64 {
+	return atomic.LoadInt64(&h.bytes)
+}
+
+//Lines returns the number of lines written.
+func (h *CountingHandler) Lines() int64 {
+	return atomic.LoadInt64(&h.lines)
+}
+
+//Sync syncs the wrapped handler.
+func (h *CountingHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *CountingHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *CountingHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *CountingHandler) Close() error {
+	return h.h.Close()
+}
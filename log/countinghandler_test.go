@@ -0,0 +1,59 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountingHandler(t *testing.T) {
+	h, _ := NewCountingHandler(DiscardHandler())
+
+	h.Write([]byte("hello\nworld\n"))
+	h.Write([]byte("again\n"))
+
+	if h.Bytes() != 18 || h.Lines() != 3 {
+		t.Fatal(h.Bytes(), h.Lines())
+	}
+
+	h.SetTerminator("\x00")
+	h.Write([]byte("a\nb\x00"))
+	if h.Bytes() != 22 || h.Lines() != 4 {
+		t.Fatal(h.Bytes(), h.Lines())
+	}
+
+	h.Close()
+}
+
+func TestCountingHandlerRate(t *testing.T) {
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 0, 0, 0, time.Local)}
+
+	h, _ := NewCountingHandler(DiscardHandler())
+	h.now = c.Now
+	if err := h.SetRateWindow(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//100 bytes in each of the first 2 seconds
+	h.Write(make([]byte, 100))
+	c.Advance(time.Second)
+	h.Write(make([]byte, 100))
+	if r := h.RateBytesPerSec(); r != 100 {
+		t.Fatal(r)
+	}
+
+	//the first second leaves the window
+	c.Advance(9 * time.Second)
+	h.Write(make([]byte, 300))
+	if r := h.RateBytesPerSec(); r != 40 {
+		t.Fatal(r)
+	}
+
+	c.Advance(time.Hour)
+	if r := h.RateBytesPerSec(); r != 0 {
+		t.Fatal(r)
+	}
+
+	if err := h.SetRateWindow(time.Millisecond); err == nil {
+		t.Fatal("must fail")
+	}
+}
@@ -0,0 +1,178 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//DateFileHandler writes log to a file named with the current date,
+//e.g. app-2014-06-01.log for baseName app.log, a new file is opened every day.
+//
+//baseName is a symlink to the current file, it is updated atomically by
+//renaming a temporary symlink, so readers never see a dangling link.
+//
+//It is safe for concurrent use.
+type DateFileHandler struct {
+	mu sync.Mutex
+
+	fd *os.File
+
+	baseName   string
+	fileName   string
+	rolloverAt int64
+	utc        bool
+	closed     bool
+}
+
+func NewDateFileHandler(baseName string) (*DateFileHandler, error) {
+	baseName = logPath(baseName)
+	makeParentDir(baseName)
+
+	h := new(DateFileHandler)
+
+	h.baseName = baseName
+
+	if err := h.open(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+//dateFileName returns the file name for logs at t.
+func (h *DateFileHandler) dateFileName(t time.Time) string {
+	ext := filepath.Ext(h.baseName)
+	return h.baseName[0:len(h.baseName)-len(ext)] + "-" + t.Format("2006-01-02") + ext
+}
+
+//open opens the file for t and points baseName to it.
+func (h *DateFileHandler) open(t time.Time) error {
+	fileName := h.dateFileName(t)
+
+	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	//a relative target keeps the link valid if the directory is moved
+	tmp := h.baseName + ".tmp"
+	os.Remove(tmp)
+	if err = os.Symlink(filepath.Base(fileName), tmp); err == nil {
+		err = os.Rename(tmp, h.baseName)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fd.Close()
+		return err
+	}
+
+	if h.fd != nil {
+		h.fd.Close()
+	}
+	h.fd = fd
+	h.fileName = fileName
+
+	y, m, d := t.Date()
+	h.rolloverAt = time.Date(y, m, d, 0, 0, 0, 0, t.Location()).AddDate(0, 0, 1).Unix()
+
+	return nil
+}
+
+//Write writes b to the current file, the rollover error is returned
+//if the write itself succeeds.
+func (h *DateFileHandler) Write(b []byte) (n int, err error) {
+	return h.write(time.Now(), b)
+}
+
+//WriteTime is Write opening the file of the date of t, the time of the log, if it is due.
+func (h *DateFileHandler) WriteTime(level int, t time.Time, b []byte) (n int, err error) {
+	return h.write(t, b)
+}
+
+func (h *DateFileHandler) write(t time.Time, b []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+
+	var e error
+	if t = h.in(t); h.rolloverAt <= t.Unix() {
+		e = h.open(t)
+	}
+
+	n, err = writeFull(h.fd, b)
+	if err == nil {
+		err = e
+	}
+	return
+}
+
+//in returns t in UTC or local time, as set by SetUTC.
+func (h *DateFileHandler) in(t time.Time) time.Time {
+	if h.utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+//SetUTC makes file dates be in UTC, so a new file is opened at 00:00 UTC,
+//instead of local time, which is the default. The file for the current date is opened.
+func (h *DateFileHandler) SetUTC(utc bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.utc = utc
+	return h.open(h.in(time.Now()))
+}
+
+//Filename returns the path of the dated file being written, not the baseName symlink.
+func (h *DateFileHandler) Filename() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.fileName
+}
+
+//Name returns "file:" and baseName.
+func (h *DateFileHandler) Name() string {
+	return "file:" + h.baseName
+}
+
+//Sync commits the current file to stable storage.
+func (h *DateFileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.fd.Sync()
+}
+
+//Check checks the current file is writable and still linked by baseName, see checkFile.
+func (h *DateFileHandler) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return checkFile(h.fd, h.baseName)
+}
+
+//Close closes the current file, baseName still links to it.
+//Closing it again does nothing.
+func (h *DateFileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.fd.Close()
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDateFileHandler(t *testing.T) {
+	path := "./test_date_log"
+	os.RemoveAll(path)
+
+	baseName := path + "/app.log"
+
+	h, err := NewDateFileHandler(baseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("today\n"))
+
+	//pretend the day is over
+	day := time.Now().AddDate(0, 0, 1)
+	h.mu.Lock()
+	err = h.open(day)
+	h.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := h.Filename(); name != path+"/app-"+day.Format("2006-01-02")+".log" {
+		t.Fatal(name)
+	}
+
+	h.Write([]byte("tomorrow\n"))
+	h.Close()
+
+	if b, err := ioutil.ReadFile(path + "/app-" + time.Now().Format("2006-01-02") + ".log"); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "today\n" {
+		t.Fatal(string(b))
+	}
+
+	if b, err := ioutil.ReadFile(baseName); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "tomorrow\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestDateFileHandlerUTC(t *testing.T) {
+	path := "./test_date_log_utc"
+	os.RemoveAll(path)
+
+	h, err := NewDateFileHandler(path + "/app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.SetUTC(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := h.Filename(); name != path+"/app-"+time.Now().UTC().Format("2006-01-02")+".log" {
+		t.Fatal(name)
+	}
+
+	h.Close()
+	os.RemoveAll(path)
+}
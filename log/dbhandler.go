@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//DBArgsFunc maps a log to the args of the insert statement of a DBHandler.
+//record holds the keys of a json log, so the Logger should use Ljson,
+//other logs are passed as "msg" only.
+type DBArgsFunc func(level int, record map[string]interface{}) ([]interface{}, error)
+
+//DBHandler inserts logs into a database, e.g. for a queryable audit log.
+//Logs are buffered and inserted in a transaction when batchSize logs are buffered,
+//on Flush, and every flush interval.
+//
+//The SQL is supplied by the user, so any driver works, e.g.
+//
+//  h, _ := log.NewDBHandler(db, "INSERT INTO audit (time, level, msg) VALUES (?, ?, ?)",
+//      func(level int, r map[string]interface{}) ([]interface{}, error) {
+//          return []interface{}{r["time"], level, r["msg"]}, nil
+//      }, 100, time.Second)
+//
+//It is safe for concurrent use. The db is not closed by Close.
+type DBHandler struct {
+	mu sync.Mutex
+
+	db        *sql.DB
+	insertSQL string
+	args      DBArgsFunc
+	batchSize int
+
+	batch [][]interface{}
+
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+//NewDBHandler creates a DBHandler inserting logs with insertSQL, its args are made by args.
+//An interval <= 0 disables periodic flush.
+func NewDBHandler(db *sql.DB, insertSQL string, args DBArgsFunc, batchSize int, interval time.Duration) (*DBHandler, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("invalid db batch size %d", batchSize)
+	}
+
+	h := new(DBHandler)
+
+	h.db = db
+	h.insertSQL = insertSQL
+	h.args = args
+	h.batchSize = batchSize
+
+	h.quit = make(chan struct{})
+
+	if interval > 0 {
+		h.wg.Add(1)
+		go h.run(interval)
+	}
+
+	return h, nil
+}
+
+func (h *DBHandler) run(interval time.Duration) {
+	defer h.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.Flush()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+func (h *DBHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel buffers p, and inserts the batch if it is full.
+func (h *DBHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	line := bytes.TrimRight(p, "\r\n")
+
+	var record map[string]interface{}
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &record) != nil {
+		record = map[string]interface{}{"msg": string(line)}
+	}
+
+	args, err := h.args(level, record)
+	if err != nil {
+		return 0, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.batch = append(h.batch, args)
+	if len(h.batch) >= h.batchSize {
+		err = h.flush()
+	}
+	return len(p), err
+}
+
+//Flush inserts the buffered logs in a transaction.
+func (h *DBHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.flush()
+}
+
+//flush inserts the batch, h.mu must be held.
+//The batch is dropped if the transaction fails, so a bad log can not block others.
+func (h *DBHandler) flush() error {
+	if len(h.batch) == 0 {
+		return nil
+	}
+
+	batch := h.batch
+	h.batch = nil
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("db handler, %d logs lost: %w", len(batch), err)
+	}
+
+	stmt, err := tx.Prepare(h.insertSQL)
+	if err == nil {
+		for _, args := range batch {
+			if _, err = stmt.Exec(args...); err != nil {
+				break
+			}
+		}
+		stmt.Close()
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		return fmt.Errorf("db handler, %d logs lost: %w", len(batch), err)
+	}
+	return nil
+}
+
+//Close stops the periodic flush and inserts the buffered logs,
+//closing it again does nothing.
+func (h *DBHandler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.quit)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+
+	return h.Flush()
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+)
+
+//testDB is a driver recording committed rows, pending rows of a transaction are
+//discarded on rollback.
+type testDB struct {
+	mu      sync.Mutex
+	pending [][]driver.Value
+	rows    [][]driver.Value
+	commits int
+}
+
+var testDBDriver = new(testDB)
+
+func init() {
+	sql.Register("logtest", testDBDriver)
+}
+
+func (d *testDB) Open(name string) (driver.Conn, error)     { return d, nil }
+func (d *testDB) Prepare(query string) (driver.Stmt, error) { return d, nil }
+func (d *testDB) Begin() (driver.Tx, error)                 { return d, nil }
+func (d *testDB) NumInput() int                             { return -1 }
+
+func (d *testDB) Close() error {
+	return nil
+}
+
+func (d *testDB) Exec(args []driver.Value) (driver.Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(args) > 0 && args[0] == "bad" {
+		return nil, errors.New("bad row")
+	}
+	d.pending = append(d.pending, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (d *testDB) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (d *testDB) Commit() error {
+	d.mu.Lock()
+	d.rows = append(d.rows, d.pending...)
+	d.pending = nil
+	d.commits++
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *testDB) Rollback() error {
+	d.mu.Lock()
+	d.pending = nil
+	d.mu.Unlock()
+	return nil
+}
+
+//reset discards the rows of earlier tests, the driver is registered once.
+func (d *testDB) reset() {
+	d.mu.Lock()
+	d.pending, d.rows, d.commits = nil, nil, 0
+	d.mu.Unlock()
+}
+
+func (d *testDB) committed() (int, [][]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commits, d.rows
+}
+
+func TestDBHandler(t *testing.T) {
+	testDBDriver.reset()
+
+	db, err := sql.Open("logtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	h, err := NewDBHandler(db, "INSERT INTO audit (level, msg) VALUES (?, ?)",
+		func(level int, r map[string]interface{}) ([]interface{}, error) {
+			return []interface{}{LevelName[level], r["msg"]}, nil
+		}, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, Ljson)
+	l.Info("a")
+	l.Warn("b")
+	l.Error("c")
+	l.Sync()
+
+	if commits, rows := testDBDriver.committed(); commits != 1 || len(rows) != 2 {
+		t.Fatal(commits, rows)
+	}
+
+	l.Close()
+
+	if commits, rows := testDBDriver.committed(); commits != 2 || len(rows) != 3 || rows[1][0] != "Warn" || rows[2][1] != "c" {
+		t.Fatal(commits, rows)
+	}
+
+	//a failed batch is rolled back
+	h, _ = NewDBHandler(db, "INSERT", func(level int, r map[string]interface{}) ([]interface{}, error) {
+		return []interface{}{r["msg"]}, nil
+	}, 10, 0)
+	h.Write([]byte("ok\n"))
+	h.Write([]byte("bad\n"))
+
+	if err := h.Close(); err == nil {
+		t.Fatal("must fail")
+	} else if _, rows := testDBDriver.committed(); len(rows) != 3 {
+		t.Fatal(rows)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
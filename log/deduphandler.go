@@ -0,0 +1,151 @@
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+//DedupHandler suppresses a log identical to the one before it, like syslog,
+//e.g. for a flapping error. A "last message repeated N times" summary is written
+//for the suppressed logs when a different log arrives, and every interval while
+//they keep coming.
+//
+//Logs are compared by a hash of the log without its leading timestamp,
+//see SamplingHandler.
+//
+//It is safe for concurrent use.
+type DedupHandler struct {
+	mu sync.Mutex
+
+	h     Handler
+	last  uint64
+	level int
+	seen  bool
+	count int
+
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+//NewDedupHandler creates a DedupHandler writing to h, summaries of a run
+//of identical logs are written at least every interval.
+func NewDedupHandler(h Handler, interval time.Duration) (*DedupHandler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid dedup interval %v", interval)
+	}
+
+	d := new(DedupHandler)
+
+	d.h = h
+
+	d.quit = make(chan struct{})
+
+	d.wg.Add(1)
+	go d.run(interval)
+
+	return d, nil
+}
+
+func (h *DedupHandler) run(interval time.Duration) {
+	defer h.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.mu.Lock()
+			h.summary()
+			h.mu.Unlock()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+//summary writes the summary of the suppressed logs, mu must be held.
+func (h *DedupHandler) summary() error {
+	if h.count == 0 {
+		return nil
+	}
+
+	line := fmt.Sprintf("last message repeated %d times\n", h.count)
+	h.count = 0
+
+	_, err := writeLevel(h.h, h.level, []byte(line))
+	return err
+}
+
+func (h *DedupHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *DedupHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	f := fnv.New64a()
+	f.Write(sampleKey(p))
+	sum := f.Sum64()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.seen && sum == h.last && level == h.level {
+		h.count++
+		return len(p), nil
+	}
+
+	//the summary goes before the different log
+	serr := h.summary()
+
+	h.seen = true
+	h.last = sum
+	h.level = level
+
+	if n, err = writeLevel(h.h, level, p); err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+//Sync syncs the wrapped handler.
+func (h *DedupHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *DedupHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler, a summary is still written at the next
+//different log or interval.
+func (h *DedupHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+//Close writes the summary of suppressed logs and closes the wrapped handler,
+//closing it again does nothing.
+func (h *DedupHandler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.quit)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+
+	h.mu.Lock()
+	err := h.summary()
+	h.mu.Unlock()
+
+	if e := h.h.Close(); err == nil {
+		err = e
+	}
+	return err
+}
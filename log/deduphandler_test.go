@@ -0,0 +1,68 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupHandler(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	h, err := NewDedupHandler(th, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, Llevel)
+	for i := 0; i < 4; i++ {
+		l.Error("storm")
+	}
+	l.Info("calm")
+	l.Error("storm")
+	l.Error("storm")
+	l.Warn("storm")
+	l.Close()
+
+	s := "[Error] storm\n" +
+		"last message repeated 3 times\n" +
+		"[Info] calm\n" +
+		"[Error] storm\n" +
+		"last message repeated 1 times\n" +
+		"[Warn] storm\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+
+	//closed by the logger already
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDedupHandlerInterval(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewDedupHandler(s, 10*time.Millisecond)
+	defer h.Close()
+
+	//the timestamps differ
+	h.Write([]byte("[2014/06/01 10:00:00] a\n"))
+	h.Write([]byte("[2014/06/01 10:00:01] a\n"))
+
+	time.Sleep(100 * time.Millisecond)
+	h.Write([]byte("[2014/06/01 10:00:02] a\n"))
+	h.Write([]byte("[2014/06/01 10:00:03] b\n"))
+
+	want := "[2014/06/01 10:00:00] a\n" +
+		"last message repeated 1 times\n" +
+		"last message repeated 1 times\n" +
+		"[2014/06/01 10:00:03] b\n"
+	if buf.String() != want {
+		t.Fatal(buf.String())
+	}
+
+	if _, err := NewDedupHandler(DiscardHandler(), 0); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -18,4 +18,16 @@
 //  l.Info("hello world")
 //  l.Infof("%s %d", "hello", 123)
 //
+//  //json lines to a file rotated every day, the Logger writes each log with one Write,
+//  //and rotating handlers only roll between writes, so every file holds whole objects
+//  h, _ := log.NewTimeRotatingFileHandler("app.log", log.WhenDay, 1)
+//  l := log.New(h, log.Ltime|log.Lfile|log.Llevel|log.Ljson)
+//  l.WithField("id", 1).Info("hello world")
+//
+// Build tags
+//
+// Built with -tags logdebug, e.g. go test -tags logdebug ./..., writing to a nil
+// handler or a closed file handler panics instead of returning ErrNilHandler or
+// ErrHandlerClosed, so tests catch lifecycle bugs. Production builds only pay a nil check.
+//
 package log
@@ -0,0 +1,122 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//FailoverHandler writes logs to a primary handler, and to a secondary one, e.g. stderr,
+//while the primary fails, e.g. the disk is full, so logs are not lost.
+//
+//After a failure the primary is tried again every retry interval, one second by default,
+//logging goes back to it once a write succeeds.
+//
+//It is safe for concurrent use if both handlers are.
+type FailoverHandler struct {
+	primary   Handler
+	secondary Handler
+
+	mu        sync.Mutex
+	failed    bool
+	retry     time.Duration
+	nextRetry time.Time
+}
+
+func NewFailoverHandler(primary Handler, secondary Handler) (*FailoverHandler, error) {
+	h := new(FailoverHandler)
+
+	h.primary = primary
+	h.secondary = secondary
+	h.retry = time.Second
+
+	return h, nil
+}
+
+//SetRetryInterval sets how often the primary is tried again after a failure,
+//0 tries it on every write.
+func (h *FailoverHandler) SetRetryInterval(d time.Duration) {
+	h.mu.Lock()
+	h.retry = d
+	h.mu.Unlock()
+}
+
+//Active returns the handler logs are being written to.
+func (h *FailoverHandler) Active() Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.failed {
+		return h.secondary
+	}
+	return h.primary
+}
+
+func (h *FailoverHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p to the primary, or to the secondary if the primary fails
+//or is waiting for the next retry. The error of the secondary is returned.
+func (h *FailoverHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	h.mu.Lock()
+	try := !h.failed || !time.Now().Before(h.nextRetry)
+	h.mu.Unlock()
+
+	if try {
+		n, err = writeLevel(h.primary, level, p)
+
+		h.mu.Lock()
+		h.failed = err != nil
+		if h.failed {
+			h.nextRetry = time.Now().Add(h.retry)
+		}
+		h.mu.Unlock()
+
+		if err == nil {
+			return
+		}
+	}
+
+	return writeLevel(h.secondary, level, p)
+}
+
+//Sync syncs both handlers, the first error is returned.
+func (h *FailoverHandler) Sync() error {
+	err := syncHandler(h.primary)
+	if e := syncHandler(h.secondary); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+//Check fails only if both handlers fail, as logs are written while one of them works,
+//a failing primary is reported with the error of the secondary.
+func (h *FailoverHandler) Check() error {
+	err := checkHandler(h.primary)
+	if err == nil {
+		return nil
+	}
+	if e := checkHandler(h.secondary); e != nil {
+		return fmt.Errorf("primary: %v, secondary: %w", err, e)
+	}
+	return nil
+}
+
+//Flush flushes both handlers, the first error is returned.
+func (h *FailoverHandler) Flush() error {
+	err := flushHandler(h.primary)
+	if e := flushHandler(h.secondary); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+//Close closes both handlers, the first error is returned.
+func (h *FailoverHandler) Close() error {
+	err := h.primary.Close()
+	if e := h.secondary.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+//toggleHandler fails writes while fail is set.
+type toggleHandler struct {
+	fail bool
+	n    int
+}
+
+func (h *toggleHandler) Write(p []byte) (int, error) {
+	if h.fail {
+		return 0, errors.New("disk full")
+	}
+	h.n++
+	return len(p), nil
+}
+
+func (h *toggleHandler) Close() error {
+	return nil
+}
+
+func TestFailoverHandler(t *testing.T) {
+	p := new(toggleHandler)
+	s, buf := NewTestHandler()
+
+	h, _ := NewFailoverHandler(p, s)
+	h.SetRetryInterval(0)
+
+	h.Write([]byte("a\n"))
+
+	p.fail = true
+	if _, err := h.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	} else if h.Active() != s {
+		t.Fatal("must fail over to secondary")
+	}
+
+	p.fail = false
+	h.Write([]byte("c\n"))
+	if h.Active() != p {
+		t.Fatal("must recover to primary")
+	}
+	h.Close()
+
+	if buf.String() != "b\n" || p.n != 2 {
+		t.Fatal(buf.String(), p.n)
+	}
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"strconv"
+	"time"
+)
+
+//Field is a key and value added to logs by With.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//Duration returns a field rendered like 1.5s in text, and as nanoseconds with json.
+func Duration(key string, d time.Duration) Field {
+	//Duration implements Stringer and is encoded as a number
+	return Field{key, d}
+}
+
+//Bytes returns a field rendered like 3.2MB in text, in units of 1024,
+//and as the number of bytes with json.
+func Bytes(key string, n int64) Field {
+	return Field{key, byteSize(n)}
+}
+
+type byteSize int64
+
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+func (n byteSize) String() string {
+	v := float64(n)
+	if v < 0 {
+		v = -v
+	}
+
+	i := 0
+	for v >= 1024 && i < len(byteUnits)-1 {
+		v /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return strconv.FormatInt(int64(n), 10) + "B"
+	}
+
+	if n < 0 {
+		v = -v
+	}
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	if len(s) > 2 && s[len(s)-2:] == ".0" {
+		s = s[:len(s)-2]
+	}
+	return s + byteUnits[i]
+}
+
+//With returns a logger which adds fields to every log, like WithFields,
+//e.g. l.With(Duration("took", d), Bytes("size", n)).Info("done").
+func (l *Logger) With(fields ...Field) *Logger {
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	return l.WithFields(m)
+}
@@ -0,0 +1,29 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldHelpers(t *testing.T) {
+	th, buf := NewTestHandler()
+	l := New(th, 0)
+
+	l.With(Duration("took", 1500*time.Millisecond), Bytes("size", 3355443)).Info("done")
+
+	l.SetFlags(Ljson)
+	l.With(Duration("took", 1500*time.Millisecond), Bytes("size", 3355443)).Info("done")
+	l.Close()
+
+	s := "done size=3.2MB took=1.5s\n" +
+		`{"msg":"done","size":3355443,"took":1500000000}` + "\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+
+	for n, s := range map[int64]string{0: "0B", 1023: "1023B", 1024: "1KB", 1536: "1.5KB", -2048: "-2KB", 5 << 30: "5GB"} {
+		if v := byteSize(n).String(); v != s {
+			t.Fatal(n, v)
+		}
+	}
+}
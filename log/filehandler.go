@@ -1,22 +1,254 @@
 package log
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/siddontang/go/filelock"
+)
+
+var (
+	logDirMu   sync.RWMutex
+	logDir     string
+	logDirPerm os.FileMode = 0755
 )
 
+//SetLogDir sets the directory relative file names of file handlers created after
+//the call are resolved under, absolute names are not affected.
+//The directory is created if missing, an empty dir restores the working directory.
+func SetLogDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, dirPerm()); err != nil {
+			return err
+		}
+	}
+
+	logDirMu.Lock()
+	logDir = dir
+	logDirMu.Unlock()
+	return nil
+}
+
+//logPath resolves name under the directory set by SetLogDir.
+func logPath(name string) string {
+	logDirMu.RLock()
+	dir := logDir
+	logDirMu.RUnlock()
+
+	if dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+//SetLogDirPerm sets the mode of directories created for log files, default is 0755.
+func SetLogDirPerm(perm os.FileMode) {
+	logDirMu.Lock()
+	logDirPerm = perm
+	logDirMu.Unlock()
+}
+
+func dirPerm() os.FileMode {
+	logDirMu.RLock()
+	defer logDirMu.RUnlock()
+	return logDirPerm
+}
+
+//makeParentDir creates the parent directories of a log file name,
+//errors are left to opening the file.
+func makeParentDir(name string) {
+	os.MkdirAll(filepath.Dir(name), dirPerm())
+}
+
+//lockFile takes an advisory lock of name on name.lock, as the lock file is truncated.
+//It is flock on unix, so two handlers of a process exclude each other too, but fcntl
+//on solaris, which is per process, so only other processes are excluded there.
+//
+//Close removes the lock file, then releases the lock, so a process which opened it
+//before may lock a removed file, lockFile checks for it and locks the new one instead.
+func lockFile(name string) (io.Closer, error) {
+	p := name + ".lock"
+	for i := 0; ; i++ {
+		l, err := filelock.Lock(p)
+		if err != nil {
+			return nil, fmt.Errorf("lock %s, maybe used by another process: %w", name, err)
+		}
+
+		f, ok := l.(interface{ Stat() (os.FileInfo, error) })
+		if !ok {
+			return lockedFile{l, p}, nil
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("lock %s: %w", name, err)
+		}
+		if ni, err := os.Stat(p); err == nil && os.SameFile(fi, ni) {
+			return lockedFile{l, p}, nil
+		}
+
+		l.Close()
+		if i == maxLockRetries {
+			return nil, fmt.Errorf("lock %s: lock file keeps being removed", name)
+		}
+	}
+}
+
+//maxLockRetries bounds the retries of lockFile when the lock file it locked was removed.
+const maxLockRetries = 3
+
+//lockedFile is a lock taken by lockFile, Close removes the lock file and releases the lock.
+type lockedFile struct {
+	l    io.Closer
+	name string
+}
+
+func (l lockedFile) Close() error {
+	err := os.Remove(l.name)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if e := l.l.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+//pidFile is the path of a file holding the pid of the process, Close removes it.
+type pidFile string
+
+//writePIDFile writes the pid of the process to name.pid, e.g. for logrotate postrotate
+//to signal it. It is written to a temporary file renamed then, so it is never seen partly.
+func writePIDFile(name string) (io.Closer, error) {
+	p := name + ".pid"
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return pidFile(p), nil
+}
+
+func (p pidFile) Close() error {
+	if err := os.Remove(string(p)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//closeFile closes fd if not nil, then closes the lock and the pid file, if any.
+func closeFile(fd *os.File, closers ...io.Closer) error {
+	var err error
+	if fd != nil {
+		err = fd.Close()
+	}
+	for _, c := range closers {
+		if c == nil {
+			continue
+		}
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//syncPolicy syncs a file after writes every interval or every bytes written,
+//set by SetSyncPolicy of file handlers. Syncs are triggered by writes, so
+//logs written before an idle period are synced on next write or Sync.
+type syncPolicy struct {
+	mu       sync.Mutex
+	interval time.Duration
+	bytes    int64
+	unsynced int64
+	last     time.Time
+}
+
+func (p *syncPolicy) set(interval time.Duration, bytes int64) {
+	p.mu.Lock()
+	p.interval = interval
+	p.bytes = bytes
+	p.unsynced = 0
+	p.last = time.Now()
+	p.mu.Unlock()
+}
+
+//afterWrite syncs fd if due after n bytes written, err is the write error,
+//the sync error is returned if the write succeeds.
+func (p *syncPolicy) afterWrite(fd *os.File, n int, err error) error {
+	p.mu.Lock()
+	if p.interval <= 0 && p.bytes <= 0 {
+		p.mu.Unlock()
+		return err
+	}
+
+	p.unsynced += int64(n)
+	due := (p.bytes > 0 && p.unsynced >= p.bytes) || (p.interval > 0 && time.Since(p.last) >= p.interval)
+	if due {
+		p.unsynced = 0
+		p.last = time.Now()
+	}
+	p.mu.Unlock()
+
+	if due {
+		if e := fd.Sync(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 //FileHandler writes log to a file.
+//
+//It is safe for concurrent use, each Write is a single write to the
+//underlying file, so lines are not interleaved when opened with os.O_APPEND.
 type FileHandler struct {
+	//offset in the file of the next write, see Offset, first to be 64-bit aligned for atomic
+	offset int64
+
+	mu sync.RWMutex
+
 	fd *os.File
+
+	fileName string
+	flag     int
+	perm     os.FileMode
+
+	lock   io.Closer
+	pid    io.Closer
+	sync   syncPolicy
+	closed bool
 }
 
+//NewFileHandler opens fileName with flag, if flag has os.O_CREATE,
+//the file is created with mode 0666 before umask, like the other file handlers.
 func NewFileHandler(fileName string, flag int) (*FileHandler, error) {
-	dir := path.Dir(fileName)
-	os.Mkdir(dir, 0777)
+	return NewFileHandlerPerm(fileName, flag, 0666)
+}
+
+//NewFileHandlerPerm is like NewFileHandler, but creates the file with perm,
+//perm is ignored if flag has no os.O_CREATE or the file exists.
+func NewFileHandlerPerm(fileName string, flag int, perm os.FileMode) (*FileHandler, error) {
+	fileName = logPath(fileName)
+	makeParentDir(fileName)
 
-	f, err := os.OpenFile(fileName, flag, 0)
+	f, err := os.OpenFile(fileName, flag, perm)
 	if err != nil {
 		return nil, err
 	}
@@ -24,33 +256,308 @@ func NewFileHandler(fileName string, flag int) (*FileHandler, error) {
 	h := new(FileHandler)
 
 	h.fd = f
+	h.fileName = fileName
+	h.flag = flag
+	h.perm = perm
+	h.offset = startOffset(f, flag)
 
 	return h, nil
 }
 
+//startOffset returns the offset of the first write to fd opened with flag,
+//the end of the file with os.O_APPEND, else the current offset.
+func startOffset(fd *os.File, flag int) int64 {
+	if flag&os.O_APPEND > 0 {
+		if f, err := fd.Stat(); err == nil {
+			return f.Size()
+		}
+		return 0
+	}
+
+	off, _ := fd.Seek(0, io.SeekCurrent)
+	return off
+}
+
 func (h *FileHandler) Write(b []byte) (n int, err error) {
-	return h.fd.Write(b)
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		return 0, misuse(ErrHandlerClosed)
+	}
+	fd := h.fd
+	n, err = writeFull(fd, b)
+	if isBadFD(err) {
+		h.mu.RUnlock()
+		return h.rewrite(fd, b, n, err)
+	}
+	atomic.AddInt64(&h.offset, int64(n))
+	err = h.sync.afterWrite(fd, n, err)
+	h.mu.RUnlock()
+	return
+}
+
+//WriteString writes s without converting it to a byte slice.
+func (h *FileHandler) WriteString(s string) (n int, err error) {
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		return 0, misuse(ErrHandlerClosed)
+	}
+	fd := h.fd
+	n, err = writeStringFull(fd, s)
+	if isBadFD(err) {
+		h.mu.RUnlock()
+		return h.rewrite(fd, []byte(s), n, err)
+	}
+	atomic.AddInt64(&h.offset, int64(n))
+	err = h.sync.afterWrite(fd, n, err)
+	h.mu.RUnlock()
+	return
+}
+
+//WriteBatch writes records with a single write to the file,
+//so they are not interleaved with other writes when opened with os.O_APPEND.
+func (h *FileHandler) WriteBatch(records [][]byte) (n int, err error) {
+	buf := joinRecords(records)
+
+	h.mu.RLock()
+	if h.closed {
+		h.mu.RUnlock()
+		return 0, misuse(ErrHandlerClosed)
+	}
+	fd := h.fd
+	n, err = writeFull(fd, buf)
+	if isBadFD(err) {
+		h.mu.RUnlock()
+		return h.rewrite(fd, buf, n, err)
+	}
+	atomic.AddInt64(&h.offset, int64(n))
+	err = h.sync.afterWrite(fd, n, err)
+	h.mu.RUnlock()
+	return
+}
+
+//rewrite reopens the file after writing b to fd failed with err as fd is bad,
+//see reopenBadFD, and writes the rest of b, from n, to the new file.
+//fd is not reopened if another write did already.
+func (h *FileHandler) rewrite(fd *os.File, b []byte, n int, err error) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return n, err
+	}
+	return h.rewriteLocked(fd, b, n, err)
+}
+
+//rewriteLocked is rewrite with h.mu held.
+func (h *FileHandler) rewriteLocked(fd *os.File, b []byte, n int, err error) (int, error) {
+	if h.fd == fd {
+		nfd := reopenBadFD(fd, h.fileName, err, func() (*os.File, error) {
+			return os.OpenFile(h.fileName, (h.flag|os.O_CREATE)&^os.O_TRUNC, h.perm)
+		})
+		if nfd == nil {
+			return n, err
+		}
+		h.fd = nfd
+		atomic.StoreInt64(&h.offset, startOffset(nfd, h.flag))
+	}
+
+	m, err := writeFull(h.fd, b[n:])
+	atomic.AddInt64(&h.offset, int64(m))
+	//only the bytes written to the new file count towards the next sync
+	return n + m, h.sync.afterWrite(h.fd, m, err)
+}
+
+//WriteRecord writes p like Write, and returns the offset in the file p starts at,
+//e.g. for an index of records to seek to. Other writes wait for it, so the offset
+//is exact if only h writes the file, see Offset.
+func (h *FileHandler) WriteRecord(p []byte) (offset int64, n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, 0, misuse(ErrHandlerClosed)
+	}
+
+	offset = atomic.LoadInt64(&h.offset)
+	fd := h.fd
+	n, err = writeFull(fd, p)
+	if isBadFD(err) {
+		//p is written to the reopened file from n on
+		before := n
+		n, err = h.rewriteLocked(fd, p, n, err)
+		offset = atomic.LoadInt64(&h.offset) - int64(n-before)
+		return
+	}
+	atomic.AddInt64(&h.offset, int64(n))
+	err = h.sync.afterWrite(fd, n, err)
+	return
+}
+
+//Offset returns the offset in the file of the next write: the size of the file when
+//opened with os.O_APPEND, or 0, plus the bytes written by h. It is not exact if
+//another process writes the file too, or it is truncated, e.g. by logrotate copytruncate.
+func (h *FileHandler) Offset() int64 {
+	return atomic.LoadInt64(&h.offset)
+}
+
+//Check checks the file is writable and still the file at its path, see checkFile.
+func (h *FileHandler) Check() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return checkFile(h.fd, h.fileName)
+}
+
+//SetSyncPolicy makes the file be synced after writes at most every interval,
+//or every bytes written, whichever comes first, a value <= 0 disables it.
+//The file is never synced by writes by default.
+func (h *FileHandler) SetSyncPolicy(interval time.Duration, bytes int64) {
+	h.sync.set(interval, bytes)
+}
+
+//Sync commits the file to stable storage.
+func (h *FileHandler) Sync() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.fd.Sync()
+}
+
+//Filename returns the path of the file being written.
+func (h *FileHandler) Filename() string {
+	return h.fileName
+}
+
+//Name returns "file:" and the file name.
+func (h *FileHandler) Name() string {
+	return "file:" + h.fileName
+}
+
+//Reopen closes and reopens the file, e.g. on SIGHUP after logrotate renamed it,
+//the file is created if missing and never truncated.
+func (h *FileHandler) Reopen() error {
+	fd, err := os.OpenFile(h.fileName, (h.flag|os.O_CREATE)&^os.O_TRUNC, h.perm)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		fd.Close()
+		return misuse(ErrHandlerClosed)
+	}
+	h.fd, fd = fd, h.fd
+	atomic.StoreInt64(&h.offset, startOffset(h.fd, h.flag))
+	h.mu.Unlock()
+
+	return fd.Close()
+}
+
+//WritePIDFile writes the pid of the process to the file name with .pid appended,
+//so external tools, e.g. logrotate postrotate, can signal the process.
+//Close removes it. Writing it again does nothing.
+func (h *FileHandler) WritePIDFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.pid != nil {
+		return nil
+	}
+
+	p, err := writePIDFile(h.fileName)
+	if err != nil {
+		return err
+	}
+	h.pid = p
+	return nil
+}
+
+//LockFile takes an advisory lock of the file, so another process using it with
+//LockFile fails, instead of interleaving lines. It is opt-in, so files may still be
+//shared by processes appending to them. The lock is held until Close, see lockFile.
+func (h *FileHandler) LockFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.lock != nil {
+		return nil
+	}
+
+	l, err := lockFile(h.fileName)
+	if err != nil {
+		return err
+	}
+	h.lock = l
+	return nil
 }
 
+//Close closes the file, closing it again does nothing.
 func (h *FileHandler) Close() error {
-	return h.fd.Close()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return closeFile(h.fd, h.lock, h.pid)
 }
 
 //RotatingFileHandler writes log a file, if file size exceeds maxBytes, 
 //it will backup current file and open a new one.
 //
 //max backup file number is set by backupCount, it will delete oldest if backups too many.
+//
+//fileName is always the active file, backups are numbered from the newest:
+//on rollover fileName.1 is renamed to fileName.2 and so on, and fileName becomes
+//fileName.1, so fileName.<backupCount> is the oldest. See CurrentBackups.
+//
+//Rollover only happens before a Write, so a log written by a Logger is never split
+//across files, whatever its Formatter is.
+//
+//It is safe for concurrent use.
 type RotatingFileHandler struct {
+	mu sync.Mutex
+
 	fd *os.File
 
 	fileName    string
-	maxBytes    int
+	maxBytes    int64
+	curBytes    int64
 	backupCount int
+	//disk budget set by SetMaxTotalBytes, 0 is none
+	maxTotalBytes int64
+	//backups were shifted by a rollover which failed to roll the file
+	shifted bool
+
+	markers rotationMarkers
+
+	lock   io.Closer
+	pid    io.Closer
+	sync   syncPolicy
+	closed bool
 }
 
-func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int) (*RotatingFileHandler, error) {
-	dir := path.Dir(fileName)
-	os.Mkdir(dir, 0777)
+//NewRotatingFileHandler opens or creates fileName, the size of an existing file
+//counts towards maxBytes, so rollover is accurate across restarts.
+func NewRotatingFileHandler(fileName string, maxBytes int64, backupCount int) (*RotatingFileHandler, error) {
+	fileName = logPath(fileName)
+	makeParentDir(fileName)
 
 	h := new(RotatingFileHandler)
 
@@ -68,133 +575,1420 @@ func NewRotatingFileHandler(fileName string, maxBytes int, backupCount int) (*Ro
 		return nil, err
 	}
 
+	f, err := h.fd.Stat()
+	if err != nil {
+		h.fd.Close()
+		return nil, err
+	}
+	h.curBytes = f.Size()
+
 	return h, nil
 }
 
 func (h *RotatingFileHandler) Write(p []byte) (n int, err error) {
-	h.doRollover()
-	return h.fd.Write(p)
-}
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-func (h *RotatingFileHandler) Close() error {
-	if h.fd != nil {
-		return h.fd.Close()
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
 	}
-	return nil
+
+	e := h.doRollover(int64(len(p)))
+
+	n, err = writeFull(h.fd, p)
+	if fd := reopenBadFD(h.fd, h.fileName, err, h.reopen); fd != nil {
+		h.fd = fd
+		var m int
+		m, err = writeFull(h.fd, p[n:])
+		n += m
+	}
+	if err == nil {
+		err = e
+	}
+	h.curBytes += int64(n)
+	err = h.sync.afterWrite(h.fd, n, err)
+	return
 }
 
-func (h *RotatingFileHandler) doRollover() {
-	f, err := h.fd.Stat()
-	if err != nil {
-		return
+//WriteString writes s without converting it to a byte slice.
+func (h *RotatingFileHandler) WriteString(s string) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
 	}
 
-	if h.maxBytes <= 0 {
-		return
-	} else if f.Size() < int64(h.maxBytes) {
-		return
+	e := h.doRollover(int64(len(s)))
+
+	n, err = writeStringFull(h.fd, s)
+	if fd := reopenBadFD(h.fd, h.fileName, err, h.reopen); fd != nil {
+		h.fd = fd
+		var m int
+		m, err = writeStringFull(h.fd, s[n:])
+		n += m
 	}
+	if err == nil {
+		err = e
+	}
+	h.curBytes += int64(n)
+	err = h.sync.afterWrite(h.fd, n, err)
+	return
+}
 
-	if h.backupCount > 0 {
-		h.fd.Close()
+//SetSyncPolicy makes the file be synced like FileHandler.SetSyncPolicy.
+func (h *RotatingFileHandler) SetSyncPolicy(interval time.Duration, bytes int64) {
+	h.sync.set(interval, bytes)
+}
 
-		for i := h.backupCount - 1; i > 0; i-- {
-			sfn := fmt.Sprintf("%s.%d", h.fileName, i)
-			dfn := fmt.Sprintf("%s.%d", h.fileName, i+1)
+//Sync commits the current file to stable storage.
+func (h *RotatingFileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-			os.Rename(sfn, dfn)
-		}
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.fd.Sync()
+}
 
-		dfn := fmt.Sprintf("%s.1", h.fileName)
-		os.Rename(h.fileName, dfn)
+//Check checks the current file is writable and still the file at its path, see checkFile.
+func (h *RotatingFileHandler) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-		h.fd, _ = os.OpenFile(h.fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if h.closed {
+		return misuse(ErrHandlerClosed)
 	}
+	return checkFile(h.fd, h.fileName)
 }
 
-//TimeRotatingFileHandler writes log to a file, 
-//it will backup current and open a new one, with a period time you sepecified.
-//
-//refer: http://docs.python.org/2/library/logging.handlers.html.
-//same like python TimedRotatingFileHandler.
-type TimeRotatingFileHandler struct {
-	fd *os.File
+//Rotate rolls the file over now whatever its size, e.g. from an admin endpoint.
+//It fails if backupCount is 0, as the file would never be rotated.
+func (h *RotatingFileHandler) Rotate() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	baseName   string
-	interval   int64
-	suffix     string
-	rolloverAt int64
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	} else if h.backupCount <= 0 {
+		return fmt.Errorf("no backups to rotate %s to", h.fileName)
+	}
+	return h.rollover()
 }
 
-const (
-	WhenSecond = iota
-	WhenMinute
-	WhenHour
-	WhenDay
-)
+//Filename returns the path of the file being written, backups are Filename.1 to Filename.N.
+func (h *RotatingFileHandler) Filename() string {
+	return h.fileName
+}
 
-func NewTimeRotatingFileHandler(baseName string, when int8, interval int) (*TimeRotatingFileHandler, error) {
-	dir := path.Dir(baseName)
-	os.Mkdir(dir, 0777)
+//Name returns "file:" and the file name.
+func (h *RotatingFileHandler) Name() string {
+	return "file:" + h.fileName
+}
 
-	h := new(TimeRotatingFileHandler)
+//WritePIDFile writes the pid of the process to fileName.pid like FileHandler.WritePIDFile.
+func (h *RotatingFileHandler) WritePIDFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	h.baseName = baseName
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.pid != nil {
+		return nil
+	}
 
-	switch when {
-	case WhenSecond:
-		h.interval = 1
-		h.suffix = "2006-01-02_15-04-05"
-	case WhenMinute:
-		h.interval = 60
-		h.suffix = "2006-01-02_15-04"
-	case WhenHour:
-		h.interval = 3600
-		h.suffix = "2006-01-02_15"
-	case WhenDay:
-		h.interval = 3600 * 24
-		h.suffix = "2006-01-02"
-	default:
-		return nil, fmt.Errorf("invalid when_rotate: %d", when)
+	p, err := writePIDFile(h.fileName)
+	if err != nil {
+		return err
 	}
+	h.pid = p
+	return nil
+}
 
-	h.interval = h.interval * int64(interval)
+//LockFile takes an advisory lock of the file like FileHandler.LockFile,
+//so two processes never rotate the same file.
+func (h *RotatingFileHandler) LockFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	var err error
-	h.fd, err = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.lock != nil {
+		return nil
+	}
+
+	l, err := lockFile(h.fileName)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	h.lock = l
+	return nil
+}
 
-	fInfo, _ := h.fd.Stat()
-	h.rolloverAt = fInfo.ModTime().Unix() + h.interval
+//reopen opens the file again, see reopenBadFD.
+func (h *RotatingFileHandler) reopen() (*os.File, error) {
+	return openAppend(h.fileName)
+}
 
-	return h, nil
+//SetRotationMarkers makes a rollover write footer as the last line of the rotated
+//file and header as the first line of the new one, so readers tailing across
+//rotations can tell where a file ends, {time} in either is replaced by the
+//rollover time, e.g. DefaultRotationMarker. An empty marker is not written,
+//both are empty by default.
+func (h *RotatingFileHandler) SetRotationMarkers(footer string, header string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.markers.set(footer, header)
 }
 
-func (h *TimeRotatingFileHandler) doRollover() {
-	//refer http://hg.python.org/cpython/file/2.7/Lib/logging/handlers.py
-	now := time.Now()
+//CurrentBackups returns the existing backups, oldest first,
+//from fileName.<backupCount> to fileName.1, the active file is not included.
+func (h *RotatingFileHandler) CurrentBackups() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	if h.rolloverAt <= now.Unix() {
-		fName := h.baseName + now.Format(h.suffix)
-		h.fd.Close()
-		e := os.Rename(h.baseName, fName)
-		if e != nil {
-			panic(e)
+	var files []string
+	for i := h.backupCount; i > 0; i-- {
+		if name := fmt.Sprintf("%s.%d", h.fileName, i); fileExists(name) {
+			files = append(files, name)
 		}
+	}
+	return files
+}
 
-		h.fd, _ = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+//Close closes the file, closing it again does nothing.
+func (h *RotatingFileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-		h.rolloverAt = time.Now().Unix() + h.interval
+	if h.closed {
+		return nil
 	}
+	h.closed = true
+	return closeFile(h.fd, h.lock, h.pid)
 }
 
-func (h *TimeRotatingFileHandler) Write(b []byte) (n int, err error) {
-	h.doRollover()
-	return h.fd.Write(b)
-}
+//renameFile and openAppend are used by rollover, syncFile by the periodic sync,
+//tests replace them to inject failures.
+var (
+	syncFile   = (*os.File).Sync
+	renameFile = renameNoReplace
+	openAppend = func(name string) (*os.File, error) {
+		return os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	}
+)
 
-func (h *TimeRotatingFileHandler) Close() error {
-	return h.fd.Close()
+//renameNoReplace renames src to dst like os.Rename, but fails with an error
+//satisfying os.IsExist if dst exists, so a file rotated by another process
+//to the same name is never replaced. On unix it links dst then removes src,
+//which can not replace dst, elsewhere dst is checked before the rename, which is racy.
+func renameNoReplace(src string, dst string) error {
+	if runtime.GOOS != "windows" {
+		err := os.Link(src, dst)
+		if err == nil {
+			return os.Remove(src)
+		} else if os.IsExist(err) || os.IsNotExist(err) {
+			return err
+		}
+		//the file system may not support hard links
+	}
+
+	if fileExists(dst) {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+	}
+	return os.Rename(src, dst)
+}
+
+//rollFile renames name, open as fd, to dst and returns the new file opened as name.
+//
+//fd is renamed while open and only closed once the new file is open, so there is
+//always a file to write: if the rename fails fd is returned still as name, if the
+//open fails fd is returned as dst, with the error, and no log is lost.
+//Windows can not rename an open file, there fd is closed first.
+//A missing name, e.g. removed by others, is not an error, a fresh one is opened.
+func rollFile(fd *os.File, name string, dst string) (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		fd.Close()
+		e := renameFile(name, dst)
+		if os.IsNotExist(e) {
+			e = nil
+		}
+
+		nfd, err := openAppend(name)
+		if err != nil {
+			return nil, err
+		}
+		return nfd, e
+	}
+
+	if err := renameFile(name, dst); err != nil && !os.IsNotExist(err) {
+		return fd, err
+	}
+
+	nfd, err := openAppend(name)
+	if err != nil {
+		return fd, err
+	}
+
+	fd.Close()
+	return nfd, nil
+}
+
+//checkFile checks fd is writable with a zero-byte write, and is still the file
+//at name, not removed or replaced, e.g. by logrotate without a reopen.
+func checkFile(fd *os.File, name string) error {
+	if _, err := fd.Write(nil); err != nil {
+		return fmt.Errorf("check %s: %w", name, err)
+	}
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return fmt.Errorf("check %s: %w", name, err)
+	}
+	ni, err := os.Stat(name)
+	if err != nil {
+		return fmt.Errorf("check %s: %w", name, err)
+	}
+	if !os.SameFile(fi, ni) {
+		return fmt.Errorf("check %s: file was replaced", name)
+	}
+	return nil
+}
+
+//isBadFD reports whether err tells the file descriptor is no longer valid,
+//e.g. it was closed by mistake, or the file system was remounted.
+func isBadFD(err error) bool {
+	return errors.Is(err, syscall.EBADF) || errors.Is(err, os.ErrClosed)
+}
+
+//reopenBadFD reopens the file name with open if writing fd failed with err as
+//fd is bad, so logging goes on instead of failing until a restart, the reopen
+//is printed to stderr. It returns the new file, or nil if err is not about a bad fd,
+//or the reopen failed too.
+func reopenBadFD(fd *os.File, name string, err error, open func() (*os.File, error)) *os.File {
+	if !isBadFD(err) {
+		return nil
+	}
+
+	nfd, e := open()
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "log: reopen %s after %v failed: %v\n", name, err, e)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "log: reopened %s after %v\n", name, err)
+	fd.Close()
+	return nfd
+}
+
+//DefaultRotationMarker is a rotation marker, see SetRotationMarkers.
+const DefaultRotationMarker = "--- rotated at {time} ---"
+
+//rotationMarkers are the lines written around a rollover, see SetRotationMarkers.
+type rotationMarkers struct {
+	footer string
+	header string
+	//footed is set once the footer is written, so a failed rollover
+	//tried again on next write does not write it twice
+	footed bool
+}
+
+func (m *rotationMarkers) set(footer string, header string) {
+	m.footer = footer
+	m.header = header
+	m.footed = false
+}
+
+func (m *rotationMarkers) writeFooter(fd *os.File, t time.Time) {
+	if len(m.footer) > 0 && !m.footed {
+		writeStringFull(fd, formatMarker(m.footer, t))
+		m.footed = true
+	}
+}
+
+//writeHeader writes the header to the new file, returning the bytes written.
+func (m *rotationMarkers) writeHeader(fd *os.File, t time.Time) int64 {
+	m.footed = false
+	if len(m.header) == 0 {
+		return 0
+	}
+	n, _ := writeStringFull(fd, formatMarker(m.header, t))
+	return int64(n)
+}
+
+//formatMarker replaces {time} in marker with t in RFC 3339 and ends it with a newline.
+func formatMarker(marker string, t time.Time) string {
+	s := strings.Replace(marker, "{time}", t.Format(time.RFC3339), -1)
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return s
+}
+
+//doRollover rolls the file if writing size more bytes would exceed maxBytes.
+//An empty file is never rolled, so a single write larger than maxBytes
+//still goes to a fresh file instead of rolling forever.
+func (h *RotatingFileHandler) doRollover(size int64) error {
+	if h.backupCount <= 0 {
+		return nil
+	} else if h.curBytes == 0 || h.curBytes+size <= h.maxBytes {
+		return nil
+	}
+	return h.rollover()
+}
+
+//rollover shifts the backups and renames fileName to fileName.1, h.mu must be held.
+func (h *RotatingFileHandler) rollover() error {
+	//if fileName is missing, moved away by others or by a rollover which failed to open
+	//the new file, the current file may be a backup already, so only a fresh one is opened,
+	//and backups are shifted once for all retries of a failed rollover, or one is lost each time
+	if _, err := os.Stat(h.fileName); !os.IsNotExist(err) && !h.shifted {
+		os.Remove(fmt.Sprintf("%s.%d", h.fileName, h.backupCount))
+
+		for i := h.backupCount - 1; i > 0; i-- {
+			sfn := fmt.Sprintf("%s.%d", h.fileName, i)
+			dfn := fmt.Sprintf("%s.%d", h.fileName, i+1)
+
+			os.Rename(sfn, dfn)
+		}
+		h.shifted = true
+	}
+
+	now := time.Now()
+	h.markers.writeFooter(h.fd, now)
+
+	var err error
+	h.fd, err = rollFile(h.fd, h.fileName, fmt.Sprintf("%s.1", h.fileName))
+	if err != nil {
+		return err
+	}
+	h.shifted = false
+	h.markers.writeHeader(h.fd, now)
+
+	f, err := h.fd.Stat()
+	if err != nil {
+		return err
+	}
+	h.curBytes = f.Size()
+
+	return h.deleteOverBudget()
+}
+
+//deleteOverBudget deletes the oldest backups until the active file and the backups
+//fit in maxTotalBytes, fileName.1 is always kept, h.mu must be held.
+func (h *RotatingFileHandler) deleteOverBudget() error {
+	if h.maxTotalBytes <= 0 {
+		return nil
+	}
+
+	total := h.curBytes
+	for i := 1; i <= h.backupCount; i++ {
+		total += fileSize(fmt.Sprintf("%s.%d", h.fileName, i))
+	}
+
+	for i := h.backupCount; i > 1 && total > h.maxTotalBytes; i-- {
+		name := fmt.Sprintf("%s.%d", h.fileName, i)
+		n := fileSize(name)
+		if n == 0 {
+			continue
+		}
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= n
+	}
+	return nil
+}
+
+//SetMaxTotalBytes sets the disk budget of the active file and its backups:
+//after a rollover the oldest backups are deleted until they fit in n bytes,
+//along with backupCount. The newest backup is always kept. 0 means no budget.
+//The active file grows up to maxBytes after, so n - maxBytes is left for backups.
+func (h *RotatingFileHandler) SetMaxTotalBytes(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid max total bytes %d", n)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.maxTotalBytes = n
+	return nil
+}
+
+//fileSize returns the size of name, or name.gz if name is missing, 0 if neither exists.
+func fileSize(name string) int64 {
+	f, err := os.Lstat(name)
+	if os.IsNotExist(err) {
+		f, err = os.Lstat(name + ".gz")
+	}
+	if err != nil {
+		return 0
+	}
+	return f.Size()
+}
+
+//Clock tells the current time, TimeRotatingFileHandler uses it to decide rollover.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+//TimeRotatingFileHandler writes log to a file, 
+//it will backup current and open a new one, with a period time you sepecified.
+//
+//refer: http://docs.python.org/2/library/logging.handlers.html.
+//same like python TimedRotatingFileHandler.
+//
+//Rollover only happens before a Write, so a log is never split across files.
+//
+//It is safe for concurrent use, Write, Close and rollover are serialized.
+//
+//Rotating the same file from two processes is racy by nature: a rotated file
+//is never replaced, it gets the suffix .1, .2 and so on if the name is taken,
+//but a process may go on writing a file the other one rotated, use LockFile
+//so only one process rotates a file.
+type TimeRotatingFileHandler struct {
+	mu sync.Mutex
+
+	fd *os.File
+
+	baseName   string
+	when       int8
+	interval   int64
+	suffix     string
+	rolloverAt int64
+	align      bool
+	weekday    time.Weekday
+	utc        bool
+	clock      Clock
+
+	lastRollover time.Time
+
+	//size cap set by SetMaxBytes, 0 is none
+	maxBytes int64
+	curBytes int64
+
+	backupCount int
+	//disk budget set by SetMaxTotalBytes, 0 is none
+	maxTotalBytes int64
+	//rotated files, oldest first, listed from the directory on the first
+	//rollover, then kept up to date so a rollover needs no directory scan
+	rotated []string
+	listed  bool
+
+	compress  bool
+	skipEmpty bool
+	hook      RolloverHook
+	nameFunc  NameFunc
+	markers   rotationMarkers
+	wg        sync.WaitGroup
+
+	lock   io.Closer
+	pid    io.Closer
+	sync   syncPolicy
+	closed bool
+
+	//periodic sync set by SetSyncInterval, stopped by closing syncQuit
+	syncInterval time.Duration
+	syncQuit     chan struct{}
+}
+
+const (
+	WhenSecond = iota
+	WhenMinute
+	WhenHour
+	WhenDay
+	//WhenMidnight rolls at midnight, local or UTC as set by SetUTC, whatever the start time is.
+	WhenMidnight
+	//WhenWeek rolls at midnight on the weekday set by SetWeekday, Monday by default.
+	//The suffix of rotated files also carries the ISO week, e.g. 2014-06-02_W23.
+	WhenWeek
+)
+
+func NewTimeRotatingFileHandler(baseName string, when int8, interval int) (*TimeRotatingFileHandler, error) {
+	if len(baseName) == 0 {
+		return nil, fmt.Errorf("empty base name for time rotating file")
+	} else if interval < 1 {
+		//a zero or negative interval would roll over on every write
+		return nil, fmt.Errorf("invalid interval: %d, must be >= 1", interval)
+	}
+
+	baseName = logPath(baseName)
+	makeParentDir(baseName)
+
+	h := new(TimeRotatingFileHandler)
+
+	h.baseName = baseName
+	h.when = when
+	h.clock = realClock{}
+
+	switch when {
+	case WhenSecond:
+		h.interval = 1
+		h.suffix = "2006-01-02_15-04-05"
+	case WhenMinute:
+		h.interval = 60
+		h.suffix = "2006-01-02_15-04"
+	case WhenHour:
+		h.interval = 3600
+		h.suffix = "2006-01-02_15"
+	case WhenDay:
+		h.interval = 3600 * 24
+		h.suffix = "2006-01-02"
+	case WhenMidnight:
+		h.interval = 3600 * 24
+		h.suffix = "2006-01-02"
+		h.align = true
+	case WhenWeek:
+		h.interval = 3600 * 24 * 7
+		h.suffix = "2006-01-02"
+		h.align = true
+		h.weekday = time.Monday
+	default:
+		return nil, fmt.Errorf("invalid when_rotate: %d", when)
+	}
+
+	h.interval = h.interval * int64(interval)
+
+	var err error
+	h.fd, err = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	fInfo, _ := h.fd.Stat()
+	h.rolloverAt = h.computeRollover(fInfo.ModTime())
+	h.curBytes = fInfo.Size()
+
+	return h, nil
+}
+
+//setClock sets the clock deciding rollover, and the next rollover from its time,
+//tests use it to run rollover with a fake time.
+func (h *TimeRotatingFileHandler) setClock(c Clock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clock = c
+	h.rolloverAt = h.computeRollover(c.Now())
+}
+
+//in returns t in UTC or local time, as set by SetUTC.
+func (h *TimeRotatingFileHandler) in(t time.Time) time.Time {
+	if h.utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+//doRollover renames the current file and opens a new one if the rollover time has come
+//at t, or writing size more bytes would exceed maxBytes, h.mu must be held.
+//If baseName is missing, e.g. removed by an external tool, a fresh one is opened.
+//If rename or open fails, logging goes on with the current file, see rollFile,
+//and the error is returned, rollover will be tried again on next write.
+func (h *TimeRotatingFileHandler) doRollover(t time.Time, size int64) error {
+	//refer http://hg.python.org/cpython/file/2.7/Lib/logging/handlers.py
+	now := h.in(t)
+
+	due := h.rolloverAt <= now.Unix()
+	if !due && (h.maxBytes <= 0 || h.curBytes == 0 || h.curBytes+size <= h.maxBytes) {
+		return nil
+	}
+
+	//an empty file is kept, only the next rollover time is set
+	if due && h.skipEmpty {
+		if f, err := h.fd.Stat(); err == nil && f.Size() == 0 {
+			h.rolloverAt = h.computeRollover(t)
+			return nil
+		}
+	}
+	return h.rollover(t, due)
+}
+
+//rollover renames the current file and opens a new one, the next rollover time is
+//set from t if due, h.mu must be held.
+func (h *TimeRotatingFileHandler) rollover(t time.Time, due bool) error {
+	now := h.in(t)
+
+	//files rolled by size in one period get .1, .2 and so on
+	fName := h.rotatedName(now)
+	if h.nameFunc != nil {
+		makeParentDir(fName)
+	}
+
+	h.markers.writeFooter(h.fd, now)
+
+	//the rotated file is complete on disk before it is archived
+	if h.syncInterval > 0 {
+		syncFile(h.fd)
+	}
+
+	var err error
+	h.fd, err = rollFile(h.fd, h.baseName, fName)
+	for os.IsExist(err) {
+		//taken since checked, e.g. by another process rotating the same file
+		fName = h.rotatedName(now)
+		h.fd, err = rollFile(h.fd, h.baseName, fName)
+	}
+	if err != nil {
+		return err
+	}
+
+	//a roll by size keeps the time boundary
+	if due {
+		h.rolloverAt = h.computeRollover(t)
+	}
+	h.lastRollover = now
+	h.curBytes = h.markers.writeHeader(h.fd, now)
+
+	if h.compress || h.hook != nil {
+		compress, hook := h.compress, h.hook
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+
+			name := fName
+			if compress && compressFile(fName) == nil {
+				name = fName + ".gz"
+			}
+
+			if hook != nil {
+				if err := hook(name); err != nil {
+					fmt.Fprintf(os.Stderr, "log: rollover hook for %s failed: %v\n", name, err)
+				}
+			}
+		}()
+	}
+
+	return h.deleteOldBackups(fName)
+}
+
+//uniqueName returns name, or name.1, name.2 and so on if a rotated file named name
+//exists, e.g. after a restart in the same period, so rollover never replaces a backup,
+//renaming over an existing file also fails on windows.
+func uniqueName(name string) string {
+	n := name
+	for i := 1; fileExists(n) || fileExists(n+".gz"); i++ {
+		n = fmt.Sprintf("%s.%d", name, i)
+	}
+	return n
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+//formatSuffix returns the suffix of the file rotated at t.
+//A wall clock repeated when daylight saving time ends has its zone offset appended
+//the second time, e.g. 2014-11-02_01-0500 after 2014-11-02_01, so the names do not collide.
+func (h *TimeRotatingFileHandler) formatSuffix(t time.Time) string {
+	switch h.when {
+	case WhenWeek:
+		_, week := t.ISOWeek()
+		return t.Format(h.suffix) + fmt.Sprintf("_W%02d", week)
+	case WhenSecond, WhenMinute, WhenHour:
+		if repeatedClock(t) {
+			return t.Format(h.suffix + "-0700")
+		}
+	}
+	return t.Format(h.suffix)
+}
+
+//repeatedClock reports whether the wall clock of t already happened earlier,
+//as the clock is turned back when daylight saving time ends.
+func repeatedClock(t time.Time) bool {
+	_, off := t.Zone()
+	_, before := t.Add(-2 * time.Hour).Zone()
+	if before <= off {
+		return false
+	}
+
+	//the same wall clock at the offset before the transition
+	_, o := t.Add(-time.Duration(before-off) * time.Second).Zone()
+	return o == before
+}
+
+//RotatedName returns the name of the file a rollover at t would rotate baseName to,
+//without the .1, .2 suffix added if the name is taken, see uniqueName,
+//or the name by the NameFunc with seq 0 if set.
+func (h *TimeRotatingFileHandler) RotatedName(t time.Time) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.nameFunc != nil {
+		return h.nameFunc(h.baseName, h.in(t), 0)
+	}
+	return h.baseName + h.formatSuffix(h.in(t))
+}
+
+//rotatedName returns the name of a free file to rotate baseName to at t, h.mu must be held.
+func (h *TimeRotatingFileHandler) rotatedName(t time.Time) string {
+	if h.nameFunc == nil {
+		return uniqueName(h.baseName + h.formatSuffix(t))
+	}
+
+	var prev string
+	for seq := 0; ; seq++ {
+		n := h.nameFunc(h.baseName, t, seq)
+		if !fileExists(n) && !fileExists(n+".gz") {
+			return n
+		}
+
+		//a NameFunc ignoring seq would never return a free name
+		if n == prev || seq >= maxNameSeq {
+			return uniqueName(n)
+		}
+		prev = n
+	}
+}
+
+//maxNameSeq is the last seq a NameFunc is called with for a free name,
+//then .1, .2 and so on are appended to its name, see uniqueName.
+const maxNameSeq = 1000
+
+//parseSuffix parses a suffix made by formatSuffix, maybe with a uniqueName counter.
+func (h *TimeRotatingFileHandler) parseSuffix(s string) (time.Time, error) {
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		if _, err := strconv.Atoi(s[i+1:]); err == nil {
+			s = s[:i]
+		}
+	}
+
+	if h.when == WhenWeek {
+		if i := strings.LastIndex(s, "_W"); i >= 0 {
+			s = s[:i]
+		}
+	}
+	loc := time.Local
+	if h.utc {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(h.suffix, s, loc)
+	if err != nil && (h.when == WhenSecond || h.when == WhenMinute || h.when == WhenHour) {
+		//a repeated time with its zone offset
+		if t1, err1 := time.ParseInLocation(h.suffix+"-0700", s, loc); err1 == nil {
+			return t1, nil
+		}
+	}
+	return t, err
+}
+
+//computeRollover returns the rollover time for logs written at t.
+func (h *TimeRotatingFileHandler) computeRollover(t time.Time) int64 {
+	if !h.align {
+		return t.Unix() + h.interval
+	}
+
+	t = h.in(t)
+
+	y, m, d := t.Date()
+	hour, min, _ := t.Clock()
+
+	var b time.Time
+	switch h.when {
+	case WhenWeek:
+		b = time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		days := (int(h.weekday) - int(t.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return b.AddDate(0, 0, days+int(h.interval/(3600*24*7)-1)*7).Unix()
+	case WhenSecond:
+		b = t.Truncate(time.Second)
+	case WhenMinute:
+		b = time.Date(y, m, d, hour, min, 0, 0, t.Location())
+	case WhenHour:
+		b = time.Date(y, m, d, hour, 0, 0, 0, t.Location())
+	case WhenDay, WhenMidnight:
+		//AddDate keeps midnight across daylight saving changes
+		b = time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return b.AddDate(0, 0, int(h.interval/(3600*24))).Unix()
+	}
+
+	return b.Unix() + h.interval
+}
+
+//SetAlignToBoundary makes rollover happen at natural time boundaries,
+//e.g. WhenDay rolls at 00:00 and WhenHour at the top of the hour,
+//instead of an interval after the file was last modified.
+//
+//If the file was last modified before the current boundary, e.g. the
+//process starts after midnight with yesterday's log, the next write rolls it.
+//WhenMidnight and WhenWeek are always aligned.
+func (h *TimeRotatingFileHandler) SetAlignToBoundary(align bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := h.fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	h.align = align || h.when == WhenMidnight || h.when == WhenWeek
+	h.rolloverAt = h.computeRollover(f.ModTime())
+	return nil
+}
+
+//SetUTC makes rotated file suffixes and aligned rollover boundaries be in UTC,
+//e.g. daily files roll at 00:00 UTC, instead of local time, which is the default.
+func (h *TimeRotatingFileHandler) SetUTC(utc bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := h.fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	h.utc = utc
+	h.rolloverAt = h.computeRollover(f.ModTime())
+	return nil
+}
+
+//SetMaxBytes makes the file also roll if writing a log would make it exceed n bytes,
+//whichever of the time and the size comes first, e.g. daily files capped at 1GB.
+//Files rolled more than once in a period get the suffix .1, .2 and so on,
+//e.g. app.log2014-06-01, app.log2014-06-01.1. An empty file is never rolled by size.
+//0, the default, disables it.
+func (h *TimeRotatingFileHandler) SetMaxBytes(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid max bytes: %d", n)
+	}
+
+	h.mu.Lock()
+	h.maxBytes = n
+	h.mu.Unlock()
+	return nil
+}
+
+//SetWeekday sets the weekday WhenWeek rolls on, it has no effect for other whens.
+func (h *TimeRotatingFileHandler) SetWeekday(day time.Weekday) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if day < time.Sunday || day > time.Saturday {
+		return fmt.Errorf("invalid weekday: %d", day)
+	}
+
+	f, err := h.fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	h.weekday = day
+	h.rolloverAt = h.computeRollover(f.ModTime())
+	return nil
+}
+
+//CurrentBackups returns the rotated files, oldest first, the active baseName is not included.
+func (h *TimeRotatingFileHandler) CurrentBackups() ([]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.nameFunc != nil {
+		return append([]string(nil), h.rotated...), nil
+	}
+	return h.backups()
+}
+
+//SetBackupCount sets the max number of rotated files to keep,
+//the oldest will be deleted after rollover. 0 means keep all.
+func (h *TimeRotatingFileHandler) SetBackupCount(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.backupCount = n
+}
+
+//SetMaxTotalBytes sets the disk budget of baseName and its rotated files:
+//after a rollover the oldest rotated files are deleted until they fit in n bytes,
+//with or without backupCount. The file just rotated is always kept, as it
+//may still be compressed. 0 means no budget.
+func (h *TimeRotatingFileHandler) SetMaxTotalBytes(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("invalid max total bytes %d", n)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.maxTotalBytes = n
+	return nil
+}
+
+//SetCompress makes rotated files be compressed with gzip to baseName.<suffix>.gz
+//in another goroutine, off the write path.
+func (h *TimeRotatingFileHandler) SetCompress(compress bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.compress = compress
+}
+
+//SetSkipEmptyRollover makes a rollover be skipped if the file is empty, e.g. for
+//an idle service, so no zero-byte files are rotated, the next rollover time is set
+//as if rotated. A file with a header of SetRotationMarkers is not empty.
+func (h *TimeRotatingFileHandler) SetSkipEmptyRollover(skip bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.skipEmpty = skip
+}
+
+//RolloverHook is called with the path of a rotated file, e.g. to upload it
+//to an object store and remove it.
+type RolloverHook func(rotatedPath string) error
+
+//NameFunc returns the name of the file base is rotated to at t, seq is 0 for
+//the first rollover in a period and counts up while the returned name exists.
+//If the name does not change with seq, .1, .2 and so on are appended like uniqueName,
+//e.g. a name like app.log.20140601, or app/2014/06/01.log, directories are created.
+type NameFunc func(base string, t time.Time, seq int) string
+
+//SetNameFunc makes rotated files be named by f instead of baseName and the time suffix.
+//Rotated files can not be told from other files in the directory then, so
+//with a backup count set, only the files rotated since the call are counted,
+//and returned by CurrentBackups.
+//A nil f restores the time suffix.
+func (h *TimeRotatingFileHandler) SetNameFunc(f NameFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nameFunc = f
+	h.rotated = nil
+	h.listed = f != nil
+}
+
+//reopen opens the file again, see reopenBadFD.
+func (h *TimeRotatingFileHandler) reopen() (*os.File, error) {
+	return openAppend(h.baseName)
+}
+
+//SetRotationMarkers writes marker lines around a rollover like
+//RotatingFileHandler.SetRotationMarkers, {time} is in UTC or local time as set by SetUTC.
+func (h *TimeRotatingFileHandler) SetRotationMarkers(footer string, header string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.markers.set(footer, header)
+}
+
+//SetRolloverHook sets a hook called in another goroutine after a file is rotated,
+//with the .gz path if compressed. An error of the hook is printed to stderr,
+//logging goes on. Close waits for running hooks.
+func (h *TimeRotatingFileHandler) SetRolloverHook(hook RolloverHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hook = hook
+}
+
+//compressFile compresses name to name.gz and removes name.
+//It writes a temporary file then renames it, so a crash leaves
+//either name or a complete name.gz, never a truncated one.
+func compressFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tmp := name + ".gz.tmp"
+	t, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	w := gzip.NewWriter(t)
+	if _, err = io.Copy(w, f); err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = t.Sync()
+	}
+	if e := t.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	f.Close()
+	return os.Remove(name)
+}
+
+//backups returns the rotated files of this handler, oldest first.
+//Only files named baseName + a valid time suffix, maybe with .gz, are returned.
+func (h *TimeRotatingFileHandler) backups() ([]string, error) {
+	dir, prefix := filepath.Split(h.baseName)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name string
+		t    time.Time
+	}
+
+	var bs []backup
+	for _, name := range names {
+		if len(name) <= len(prefix) || name[:len(prefix)] != prefix {
+			continue
+		}
+
+		t, err := h.parseSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"))
+		if err != nil {
+			continue
+		}
+
+		bs = append(bs, backup{filepath.Join(dir, name), t})
+	}
+
+	sort.Slice(bs, func(i, j int) bool {
+		if bs[i].t.Equal(bs[j].t) {
+			//name.1 is rotated after name
+			return len(bs[i].name) < len(bs[j].name) || len(bs[i].name) == len(bs[j].name) && bs[i].name < bs[j].name
+		}
+		return bs[i].t.Before(bs[j].t)
+	})
+
+	files := make([]string, len(bs))
+	for i, b := range bs {
+		files[i] = b.name
+	}
+	return files, nil
+}
+
+//deleteOldBackups deletes the oldest rotated files over backupCount or maxTotalBytes,
+//fName is the file just rotated. The directory is only scanned on the first call.
+func (h *TimeRotatingFileHandler) deleteOldBackups(fName string) error {
+	if h.backupCount <= 0 && h.maxTotalBytes <= 0 {
+		return nil
+	}
+
+	if !h.listed {
+		files, err := h.backups()
+		if err != nil {
+			return err
+		}
+		h.rotated = files
+		h.listed = true
+	} else {
+		h.rotated = append(h.rotated, fName)
+	}
+
+	var total int64
+	if h.maxTotalBytes > 0 {
+		total = h.curBytes
+		for _, name := range h.rotated {
+			total += fileSize(name)
+		}
+	}
+
+	for len(h.rotated) > 0 {
+		overCount := h.backupCount > 0 && len(h.rotated) > h.backupCount
+		overBytes := h.maxTotalBytes > 0 && total > h.maxTotalBytes && len(h.rotated) > 1
+		if !overCount && !overBytes {
+			break
+		}
+
+		//a file may be compressed, or removed by a rollover hook, since it was listed
+		name := h.rotated[0]
+		total -= fileSize(name)
+		err := os.Remove(name)
+		if os.IsNotExist(err) && !strings.HasSuffix(name, ".gz") {
+			err = os.Remove(name + ".gz")
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		h.rotated[0] = ""
+		h.rotated = h.rotated[1:]
+	}
+
+	return nil
+}
+
+//Write writes b to the current file even if rollover fails,
+//the rollover error is returned if the write itself succeeds.
+func (h *TimeRotatingFileHandler) Write(b []byte) (n int, err error) {
+	return h.write(h.clock.Now(), b)
+}
+
+//WriteTime is Write deciding rollover with t, the time of the log, instead of the clock.
+func (h *TimeRotatingFileHandler) WriteTime(level int, t time.Time, b []byte) (n int, err error) {
+	return h.write(t, b)
+}
+
+func (h *TimeRotatingFileHandler) write(t time.Time, b []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+
+	e := h.doRollover(t, int64(len(b)))
+	n, err = writeFull(h.fd, b)
+	if fd := reopenBadFD(h.fd, h.baseName, err, h.reopen); fd != nil {
+		h.fd = fd
+		var m int
+		m, err = writeFull(h.fd, b[n:])
+		n += m
+	}
+	h.curBytes += int64(n)
+	if err == nil {
+		err = e
+	}
+	err = h.sync.afterWrite(h.fd, n, err)
+	return
+}
+
+//WriteString writes s without converting it to a byte slice.
+func (h *TimeRotatingFileHandler) WriteString(s string) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+
+	e := h.doRollover(h.clock.Now(), int64(len(s)))
+	n, err = writeStringFull(h.fd, s)
+	if fd := reopenBadFD(h.fd, h.baseName, err, h.reopen); fd != nil {
+		h.fd = fd
+		var m int
+		m, err = writeStringFull(h.fd, s[n:])
+		n += m
+	}
+	h.curBytes += int64(n)
+	if err == nil {
+		err = e
+	}
+	err = h.sync.afterWrite(h.fd, n, err)
+	return
+}
+
+//SetSyncPolicy makes the file be synced like FileHandler.SetSyncPolicy.
+func (h *TimeRotatingFileHandler) SetSyncPolicy(interval time.Duration, bytes int64) {
+	h.sync.set(interval, bytes)
+}
+
+//SetSyncInterval makes the file be synced every interval in a background goroutine,
+//whether written or not, and before every rollover, so a crash loses at most
+//interval of logs and rotated files are complete on disk. 0, the default, disables it.
+func (h *TimeRotatingFileHandler) SetSyncInterval(interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopSync()
+	h.syncInterval = interval
+
+	if interval > 0 && !h.closed {
+		h.syncQuit = make(chan struct{})
+		h.wg.Add(1)
+		go h.runSync(interval, h.syncQuit)
+	}
+}
+
+//stopSync stops the periodic sync, h.mu must be held.
+func (h *TimeRotatingFileHandler) stopSync() {
+	if h.syncQuit != nil {
+		close(h.syncQuit)
+		h.syncQuit = nil
+	}
+}
+
+func (h *TimeRotatingFileHandler) runSync(interval time.Duration, quit chan struct{}) {
+	defer h.wg.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.mu.Lock()
+			//quit is closed with h.mu held, so no sync happens once stopped
+			select {
+			case <-quit:
+			default:
+				syncFile(h.fd)
+			}
+			h.mu.Unlock()
+		case <-quit:
+			return
+		}
+	}
+}
+
+//RolloverAt returns the time of the next rollover, it happens on the first write after.
+func (h *TimeRotatingFileHandler) RolloverAt() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return time.Unix(h.rolloverAt, 0)
+}
+
+//LastRollover returns the time of the last rollover, zero if not rolled yet.
+func (h *TimeRotatingFileHandler) LastRollover() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.lastRollover
+}
+
+//Filename returns the path of the file being written, it is always baseName.
+func (h *TimeRotatingFileHandler) Filename() string {
+	return h.baseName
+}
+
+//Name returns "file:" and baseName.
+func (h *TimeRotatingFileHandler) Name() string {
+	return "file:" + h.baseName
+}
+
+//Rotate rolls the file over now, e.g. from an admin endpoint or on a signal,
+//and the next rollover happens an interval later. A file rotated again in the
+//same period gets .1, .2 and so on, see uniqueName.
+func (h *TimeRotatingFileHandler) Rotate() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.rollover(h.clock.Now(), true)
+}
+
+//Reopen closes and reopens baseName, e.g. on SIGHUP after logrotate renamed it.
+func (h *TimeRotatingFileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	fd, err := os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	h.fd, fd = fd, h.fd
+	if f, err := h.fd.Stat(); err == nil {
+		h.curBytes = f.Size()
+	}
+
+	return fd.Close()
+}
+
+//Sync commits the current file to stable storage.
+func (h *TimeRotatingFileHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.fd.Sync()
+}
+
+//Check checks the current file is writable and still the file at baseName, see checkFile.
+func (h *TimeRotatingFileHandler) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return checkFile(h.fd, h.baseName)
+}
+
+//WritePIDFile writes the pid of the process to baseName.pid like FileHandler.WritePIDFile.
+func (h *TimeRotatingFileHandler) WritePIDFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.pid != nil {
+		return nil
+	}
+
+	p, err := writePIDFile(h.baseName)
+	if err != nil {
+		return err
+	}
+	h.pid = p
+	return nil
+}
+
+//LockFile takes an advisory lock of baseName like FileHandler.LockFile,
+//so two processes never rotate the same file.
+func (h *TimeRotatingFileHandler) LockFile() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	if h.lock != nil {
+		return nil
+	}
+
+	l, err := lockFile(h.baseName)
+	if err != nil {
+		return err
+	}
+	h.lock = l
+	return nil
+}
+
+//Close stops the periodic sync and closes the file after pending compressions finish,
+//closing it again does nothing.
+func (h *TimeRotatingFileHandler) Close() error {
+	//closed first, so no write rolls over and starts a hook while waiting
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.stopSync()
+	h.mu.Unlock()
+
+	h.wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return closeFile(h.fd, h.lock, h.pid)
 }
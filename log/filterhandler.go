@@ -0,0 +1,50 @@
+package log
+
+//FilterHandler drops logs with level below its minimum level, and writes others
+//to another handler, so handlers sharing a Logger can take different levels.
+//
+//The level comes from the Logger through LevelWriter,
+//a Write without level, e.g. not from a Logger, is treated as LevelInfo.
+type FilterHandler struct {
+	h        Handler
+	minLevel int
+}
+
+func NewFilterHandler(h Handler, minLevel int) (*FilterHandler, error) {
+	f := new(FilterHandler)
+
+	f.h = h
+	f.minLevel = minLevel
+
+	return f, nil
+}
+
+func (h *FilterHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *FilterHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	if level < h.minLevel {
+		return len(p), nil
+	}
+	return writeLevel(h.h, level, p)
+}
+
+//Sync syncs the wrapped handler.
+func (h *FilterHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *FilterHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *FilterHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *FilterHandler) Close() error {
+	return h.h.Close()
+}
@@ -0,0 +1,28 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilterHandler(t *testing.T) {
+	var all, warn bytes.Buffer
+	h1, _ := NewStreamHandler(&all)
+	h2, _ := NewStreamHandler(&warn)
+
+	f, _ := NewFilterHandler(h2, LevelWarn)
+	h, _ := NewMultiHandler(h1, f)
+
+	l := New(h, Llevel)
+	l.Info("hello")
+	l.Error("world")
+	l.Close()
+
+	if all.String() != "[Info] hello\n[Error] world\n" {
+		t.Fatal(all.String())
+	}
+
+	if warn.String() != "[Error] world\n" {
+		t.Fatal(warn.String())
+	}
+}
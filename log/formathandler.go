@@ -0,0 +1,99 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+//FormatHandler formats logs with its own Formatter before writing them to another handler,
+//so one Logger can write json to a file and colored text to the console, e.g.
+//
+//  file, _ := log.NewFormatHandler(fh, &log.JSONFormatter{Flag: log.Ltime | log.Llevel, TimeFormat: time.RFC3339})
+//  console, _ := log.NewFormatHandler(sh, &log.TextFormatter{Flag: log.Ltime | log.Llevel, TimeFormat: log.TimeFormat})
+//  h, _ := log.NewMultiHandler(file, console)
+//
+//It formats the Record of a log, which only reaches it through handlers implementing
+//RecordHandler, e.g. MultiHandler and LevelRouterHandler, it may wrap any handler.
+//A log without record, e.g. written with Write or through an AsyncHandler, is written
+//as formatted by the Logger. The caller is not known here, Lfile is ignored.
+//
+//It is safe for concurrent use if the wrapped handler is, formatting is serialized.
+type FormatHandler struct {
+	h Handler
+	f Formatter
+
+	//reused by HandleRecord, so formatting allocates nothing with the built-in formatters
+	mu  sync.Mutex
+	buf []byte
+	rec Record
+}
+
+func NewFormatHandler(h Handler, f Formatter) (*FormatHandler, error) {
+	s := new(FormatHandler)
+
+	s.h = h
+	s.f = f
+
+	return s, nil
+}
+
+func (h *FormatHandler) Write(p []byte) (n int, err error) {
+	return writeLevel(h.h, LevelInfo, p)
+}
+
+//WriteLevel writes p as is, it was not formatted by the formatter of h.
+func (h *FormatHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	return writeLevel(h.h, level, p)
+}
+
+//WriteTime writes p as is, see WriteLevel.
+func (h *FormatHandler) WriteTime(level int, t time.Time, p []byte) (n int, err error) {
+	return writeTime(h.h, level, t, p)
+}
+
+//HandleRecord formats r and passes it on with the new line, see RecordHandler.
+//If the formatter panics, the line formatted by the Logger is written instead.
+func (h *FormatHandler) HandleRecord(r *Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.rec = *r
+	h.rec.Line = h.format(r)
+	err := handleRecord(h.h, &h.rec)
+	h.rec = Record{}
+	return err
+}
+
+//format formats r into h.buf, h.mu must be held.
+func (h *FormatHandler) format(r *Record) (line []byte) {
+	defer func() {
+		if e := recover(); e != nil {
+			line = r.Line
+		}
+	}()
+
+	if f, ok := h.f.(appendFormatter); ok {
+		h.buf = f.appendFormat(h.buf[0:0], "", 0, r.Level, r.Time, r.Msg, r.Fields)
+		return h.buf
+	}
+	return h.f.Format(r.Level, r.Time, r.Msg, r.Fields)
+}
+
+//Sync syncs the wrapped handler.
+func (h *FormatHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *FormatHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *FormatHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *FormatHandler) Close() error {
+	return h.h.Close()
+}
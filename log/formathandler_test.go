@@ -0,0 +1,35 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestFormatHandler(t *testing.T) {
+	jh, jbuf := NewTestHandler()
+	th, tbuf := NewTestHandler()
+	ph, pbuf := NewTestHandler()
+
+	js, _ := NewFormatHandler(jh, &JSONFormatter{Flag: Llevel})
+	text, _ := NewFormatHandler(th, &TextFormatter{Flag: Llevel, LevelWidth: -5})
+	panics, _ := NewFormatHandler(ph, panicFormatter{})
+	h, _ := NewMultiHandler(js, text, panics)
+
+	l := New(h, 0)
+	l.WithField("user", "a").Info("hello")
+	l.Warn("world")
+	l.Sync()
+
+	//written as formatted by the logger
+	h.Write([]byte("raw\n"))
+	l.Close()
+
+	if s := jbuf.String(); s != `{"level":"Info","msg":"hello","user":"a"}`+"\n"+`{"level":"Warn","msg":"world"}`+"\nraw\n" {
+		t.Fatal(s)
+	}
+	if s := tbuf.String(); s != "[Info ] hello user=a\n[Warn ] world\nraw\n" {
+		t.Fatal(s)
+	}
+	if s := pbuf.String(); s != "hello user=a\nworld\nraw\n" {
+		t.Fatal(s)
+	}
+}
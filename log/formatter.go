@@ -0,0 +1,371 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Formatter formats a log to bytes, then the handler writes them,
+//fields are the fields added with WithFields, maybe nil.
+type Formatter interface {
+	Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte
+}
+
+//appendFormatter is implemented by the built-in formatters, so the Logger
+//can format into its pooled buffers and render the caller with Lfile.
+type appendFormatter interface {
+	flags() int
+	appendFormat(buf []byte, file string, line int, level int, t time.Time, msg string, fields map[string]interface{}) []byte
+}
+
+//TextFormatter formats a log as "[time] file:line [level] msg key=value...",
+//Flag selects the parts with Ltime, Lfile and Llevel, TimeFormat is a layout or a preset
+//like TimeFormatEpochMillis, an empty TimeFormat disables the time.
+//
+//LevelWidth pads the level name with spaces to the width, like fmt, so a negative
+//width pads on the right, e.g. -5 renders "[Info ]" and "[Error]", aligning columns.
+//ShortLevel renders the first letter of the level only, e.g. "[W]".
+//
+//With Lescape, newlines and carriage returns in the message and field values are
+//written as \n and \r, so line-oriented handlers see one log per line.
+//Multi-line logs are written as is by default.
+//
+//The caller is only known when used by a Logger, Format skips it.
+type TextFormatter struct {
+	Flag       int
+	TimeFormat string
+	LevelWidth int
+	ShortLevel bool
+}
+
+func (f *TextFormatter) Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	return f.appendFormat(nil, "", 0, level, t, msg, fields)
+}
+
+func (f *TextFormatter) flags() int {
+	return f.Flag
+}
+
+func (f *TextFormatter) appendFormat(buf []byte, file string, line int, level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	if f.Flag&Ltime > 0 && len(f.TimeFormat) > 0 {
+		buf = append(buf, '[')
+		buf = appendTime(buf, t, f.TimeFormat)
+		buf = append(buf, "] "...)
+	}
+
+	if f.Flag&Lfile > 0 && len(file) > 0 {
+		buf = append(buf, file...)
+		buf = append(buf, ':')
+
+		buf = strconv.AppendInt(buf, int64(line), 10)
+		buf = append(buf, ' ')
+	}
+
+	if f.Flag&Llevel > 0 {
+		buf = append(buf, '[')
+		buf = f.appendLevel(buf, level)
+		buf = append(buf, "] "...)
+	}
+
+	escape := f.Flag&Lescape > 0
+	if escape {
+		buf = appendEscaped(buf, strings.TrimSuffix(msg, "\n"))
+	} else {
+		buf = append(buf, msg...)
+	}
+
+	if len(fields) > 0 {
+		if !escape && len(msg) > 0 && msg[len(msg)-1] == '\n' {
+			buf = buf[0 : len(buf)-1]
+		}
+
+		for _, k := range sortedKeys(fields) {
+			buf = append(buf, ' ')
+			buf = append(buf, k...)
+			buf = append(buf, '=')
+			if escape {
+				buf = appendEscaped(buf, fmt.Sprint(fields[k]))
+			} else {
+				buf = append(buf, fmt.Sprint(fields[k])...)
+			}
+		}
+	}
+
+	if len(buf) == 0 || buf[len(buf)-1] != '\n' {
+		buf = append(buf, '\n')
+	}
+
+	return buf
+}
+
+func (f *TextFormatter) appendLevel(buf []byte, level int) []byte {
+	name := levelName(level)
+	if f.ShortLevel {
+		name = name[:1]
+	}
+
+	pad := f.LevelWidth
+	if pad < 0 {
+		pad = -pad
+	}
+	pad -= len(name)
+
+	if f.LevelWidth > 0 {
+		buf = appendSpaces(buf, pad)
+	}
+	buf = append(buf, name...)
+	if f.LevelWidth < 0 {
+		buf = appendSpaces(buf, pad)
+	}
+	return buf
+}
+
+//appendTime appends t formatted with layout, or as an integer for the epoch presets.
+func appendTime(buf []byte, t time.Time, layout string) []byte {
+	switch layout {
+	case TimeFormatEpochMillis:
+		return strconv.AppendInt(buf, t.UnixNano()/int64(time.Millisecond), 10)
+	case TimeFormatEpochNanos:
+		return strconv.AppendInt(buf, t.UnixNano(), 10)
+	}
+	return t.AppendFormat(buf, layout)
+}
+
+func isEpochFormat(layout string) bool {
+	return layout == TimeFormatEpochMillis || layout == TimeFormatEpochNanos
+}
+
+//appendEscaped appends s with newlines and carriage returns escaped.
+func appendEscaped(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		default:
+			buf = append(buf, s[i])
+		}
+	}
+	return buf
+}
+
+func appendSpaces(buf []byte, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+//JSONFormatter formats a log as a json object in one line, with keys time, file, level
+//selected by Flag like TextFormatter, msg, and the fields.
+//
+//A field value which can not be encoded is rendered with %v, an error is rendered
+//as its message, or as an array of the messages of its chain if it wraps others.
+//
+//Every key appears once: a field set twice with WithFields keeps the last value,
+//and a field named like an output key, e.g. msg, is renamed fields.msg.
+type JSONFormatter struct {
+	Flag       int
+	TimeFormat string
+}
+
+func (f *JSONFormatter) Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	return f.appendFormat(nil, "", 0, level, t, msg, fields)
+}
+
+func (f *JSONFormatter) flags() int {
+	return f.Flag
+}
+
+func (f *JSONFormatter) appendFormat(buf []byte, file string, line int, level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	buf = append(buf, '{')
+
+	hasTime := f.Flag&Ltime > 0 && len(f.TimeFormat) > 0
+	hasFile := f.Flag&Lfile > 0 && len(file) > 0
+	hasLevel := f.Flag&Llevel > 0
+
+	if hasTime {
+		buf = appendJSONKey(buf, "time")
+		if isEpochFormat(f.TimeFormat) {
+			buf = appendTime(buf, t, f.TimeFormat)
+		} else {
+			buf = appendJSONValue(buf, t.Format(f.TimeFormat))
+		}
+	}
+
+	if hasFile {
+		buf = appendJSONKey(buf, "file")
+		buf = appendJSONValue(buf, file+":"+strconv.Itoa(line))
+	}
+
+	if hasLevel {
+		buf = appendJSONKey(buf, "level")
+		buf = appendJSONValue(buf, levelName(level))
+	}
+
+	if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[0 : len(msg)-1]
+	}
+
+	buf = appendJSONKey(buf, "msg")
+	buf = appendJSONValue(buf, msg)
+
+	for _, k := range sortedKeys(fields) {
+		key := k
+		if k == "msg" || k == "time" && hasTime || k == "file" && hasFile || k == "level" && hasLevel {
+			key = "fields." + k
+		}
+
+		buf = appendJSONKey(buf, key)
+		buf = appendJSONValue(buf, fields[k])
+	}
+
+	buf = append(buf, "}\n"...)
+
+	return buf
+}
+
+func appendJSONKey(buf []byte, key string) []byte {
+	if buf[len(buf)-1] != '{' {
+		buf = append(buf, ',')
+	}
+	buf = appendJSONValue(buf, key)
+	return append(buf, ':')
+}
+
+//appendJSONValue appends v as json, v is rendered with %v if it can not be encoded.
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	if e, ok := v.(error); ok {
+		return appendJSONError(buf, e)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+//appendJSONError appends the message of e, or the messages of its unwrap chain.
+func appendJSONError(buf []byte, e error) []byte {
+	if errors.Unwrap(e) == nil {
+		return appendJSONValue(buf, e.Error())
+	}
+
+	var msgs []string
+	for ; e != nil; e = errors.Unwrap(e) {
+		msgs = append(msgs, e.Error())
+	}
+	return appendJSONValue(buf, msgs)
+}
+
+//LogfmtFormatter formats a log as logfmt, key=value pairs in one line, e.g.
+//time=2014-06-01T10:00:00Z level=info msg="hello world" user=a, with keys
+//time, file, level selected by Flag like TextFormatter, msg, and the fields.
+//
+//A value with spaces, = or quotes, or an empty one, is quoted like a Go string,
+//so quotes, backslashes and newlines in it are escaped. Levels are lower case,
+//and a field named like an output key is renamed like JSONFormatter does.
+type LogfmtFormatter struct {
+	Flag       int
+	TimeFormat string
+}
+
+func (f *LogfmtFormatter) Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	return f.appendFormat(nil, "", 0, level, t, msg, fields)
+}
+
+func (f *LogfmtFormatter) flags() int {
+	return f.Flag
+}
+
+func (f *LogfmtFormatter) appendFormat(buf []byte, file string, line int, level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	start := len(buf)
+
+	hasTime := f.Flag&Ltime > 0 && len(f.TimeFormat) > 0
+	hasFile := f.Flag&Lfile > 0 && len(file) > 0
+	hasLevel := f.Flag&Llevel > 0
+
+	if hasTime {
+		buf = appendLogfmtPair(buf, start, "time", string(appendTime(nil, t, f.TimeFormat)))
+	}
+
+	if hasFile {
+		buf = appendLogfmtPair(buf, start, "file", file+":"+strconv.Itoa(line))
+	}
+
+	if hasLevel {
+		buf = appendLogfmtPair(buf, start, "level", strings.ToLower(levelName(level)))
+	}
+
+	buf = appendLogfmtPair(buf, start, "msg", strings.TrimSuffix(msg, "\n"))
+
+	for _, k := range sortedKeys(fields) {
+		key := k
+		if k == "msg" || k == "time" && hasTime || k == "file" && hasFile || k == "level" && hasLevel {
+			key = "fields." + k
+		}
+
+		var v string
+		switch x := fields[k].(type) {
+		case nil:
+			v = "null"
+		case error:
+			v = x.Error()
+		default:
+			v = fmt.Sprint(x)
+		}
+		buf = appendLogfmtPair(buf, start, key, v)
+	}
+
+	return append(buf, '\n')
+}
+
+//appendLogfmtPair appends key=value, after a space unless it is the first pair
+//of the log starting at start. Invalid bytes of key are replaced with _.
+func appendLogfmtPair(buf []byte, start int, key string, value string) []byte {
+	if len(buf) > start {
+		buf = append(buf, ' ')
+	}
+
+	if len(key) == 0 {
+		buf = append(buf, '_')
+	}
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; logfmtNeedsQuote(c) {
+			buf = append(buf, '_')
+		} else {
+			buf = append(buf, c)
+		}
+	}
+	buf = append(buf, '=')
+
+	quote := len(value) == 0
+	for i := 0; i < len(value) && !quote; i++ {
+		quote = logfmtNeedsQuote(value[i])
+	}
+	if quote {
+		return strconv.AppendQuote(buf, value)
+	}
+	return append(buf, value...)
+}
+
+//logfmtNeedsQuote returns whether c can not be in a bare logfmt key or value.
+func logfmtNeedsQuote(c byte) bool {
+	return c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
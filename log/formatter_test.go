@@ -0,0 +1,228 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type upperFormatter struct {
+}
+
+func (f *upperFormatter) Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	return []byte(fmt.Sprintf("%s|%s|%v\n", LevelName[level], msg, fields["a"]))
+}
+
+func TestSetFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Ltime|Llevel)
+
+	l.SetFormatter(&JSONFormatter{Flag: Llevel})
+	l.Info("hello")
+
+	l.SetFormatter(new(upperFormatter))
+	l.WithFields(map[string]interface{}{"a": 1}).Warn("world")
+
+	l.SetFormatter(nil)
+	l.SetTimeFormat("")
+	l.Error("default")
+
+	l.Close()
+
+	if s := "{\"level\":\"Info\",\"msg\":\"hello\"}\nWarn|world|1\n[Error] default\n"; buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestTextFormatter(t *testing.T) {
+	f := &TextFormatter{Flag: Ltime | Lfile | Llevel, TimeFormat: "2006"}
+
+	tm := time.Date(2014, 1, 1, 0, 0, 0, 0, time.Local)
+	if s := string(f.Format(LevelWarn, tm, "hello", nil)); s != "[2014] [Warn] hello\n" {
+		t.Fatal(s)
+	}
+}
+
+func TestTextFormatterLevelWidth(t *testing.T) {
+	tm := time.Date(2014, 1, 1, 0, 0, 0, 0, time.Local)
+
+	for _, c := range []struct {
+		f     TextFormatter
+		level int
+		s     string
+	}{
+		{TextFormatter{Flag: Llevel, LevelWidth: -5}, LevelInfo, "[Info ] hello\n"},
+		{TextFormatter{Flag: Llevel, LevelWidth: -5}, LevelError, "[Error] hello\n"},
+		{TextFormatter{Flag: Llevel, LevelWidth: 5}, LevelWarn, "[ Warn] hello\n"},
+		{TextFormatter{Flag: Llevel, LevelWidth: 3}, LevelError, "[Error] hello\n"},
+		{TextFormatter{Flag: Llevel, ShortLevel: true}, LevelWarn, "[W] hello\n"},
+		{TextFormatter{Flag: Llevel, ShortLevel: true, LevelWidth: -2}, LevelError, "[E ] hello\n"},
+	} {
+		if s := string(c.f.Format(c.level, tm, "hello", nil)); s != c.s {
+			t.Fatal(s)
+		}
+	}
+}
+
+type panicFormatter struct{}
+
+func (panicFormatter) Format(level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	if len(fields) > 0 {
+		panic("bad field")
+	}
+	return []byte(msg + "\n")
+}
+
+func TestFormatterPanic(t *testing.T) {
+	m := &testMetrics{levels: make(map[int]int)}
+
+	th, buf := NewTestHandler()
+	l := New(th, 0)
+	l.SetMetrics(m)
+	l.SetFormatter(panicFormatter{})
+
+	l.Info("a")
+	l.WithField("k", 1).Info("b\n")
+	l.Info("c")
+	l.Close()
+
+	if buf.String() != "a\n[logformat error] b\nc\n" {
+		t.Fatal(buf.String())
+	}
+	if m.formatErrors != 1 {
+		t.Fatal(m.formatErrors)
+	}
+}
+
+func TestTextFormatterEscape(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel|Lescape)
+
+	l.WithField("k", "a\r\nb").Info("line 1\nline 2\n")
+	l.Info("one\n")
+
+	//multi-line logs are kept by default
+	l.SetFlags(Llevel)
+	l.Info("line 1\nline 2")
+	l.Close()
+
+	s := "[Info] line 1\\nline 2 k=a\\r\\nb\n" +
+		"[Info] one\n" +
+		"[Info] line 1\nline 2\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel)
+
+	inner := errors.New("inner")
+	l.WithError(inner).Info("hello")
+
+	l.SetFlags(Llevel | Ljson)
+	l.WithError(inner).Info("hello")
+	l.WithError(fmt.Errorf("outer: %w", inner)).Info("hello")
+	l.Close()
+
+	s := "[Info] hello error=inner\n" +
+		"{\"level\":\"Info\",\"msg\":\"hello\",\"error\":\"inner\"}\n" +
+		"{\"level\":\"Info\",\"msg\":\"hello\",\"error\":[\"outer: inner\",\"inner\"]}\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestJSONFormatterDuplicateKeys(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel|Ljson)
+
+	l.WithField("k", 1).WithField("k", 2).Info("a")
+	l.WithField("level", "x").WithField("msg", "y").WithField("time", "z").Info("b")
+	l.Close()
+
+	s := `{"level":"Info","msg":"a","k":2}` + "\n" +
+		`{"level":"Info","msg":"b","fields.level":"x","fields.msg":"y","time":"z"}` + "\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	f := &LogfmtFormatter{Flag: Ltime | Llevel, TimeFormat: time.RFC3339}
+
+	tm := time.Date(2014, 6, 1, 10, 0, 0, 0, time.UTC)
+	fields := map[string]interface{}{
+		"user":  "a",
+		"query": `name="x y"`,
+		"empty": "",
+		"err":   errors.New("failed"),
+		"nil":   nil,
+		"level": 1,
+		"a b":   `c:\d`,
+	}
+
+	s := `time=2014-06-01T10:00:00Z level=warn msg="hello world" a_b="c:\\d" empty="" err=failed ` +
+		`fields.level=1 nil=null query="name=\"x y\"" user=a` + "\n"
+	if b := string(f.Format(LevelWarn, tm, "hello world\n", fields)); b != s {
+		t.Fatal(b)
+	}
+
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, 0)
+	l.SetFormatter(&LogfmtFormatter{Flag: Lfile})
+	l.Info("multi\nline")
+	l.Close()
+
+	if !regexp.MustCompile(`^file=formatter_test.go:\d+ msg="multi\\nline"\n$`).MatchString(buf.String()) {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestTimeFormatPresets(t *testing.T) {
+	tm := time.Date(2014, 6, 1, 10, 0, 0, 123456789, time.UTC)
+
+	for _, c := range []struct {
+		f Formatter
+		s string
+	}{
+		{&TextFormatter{Flag: Ltime, TimeFormat: TimeFormatISO8601}, "[2014-06-01T10:00:00.123Z] hello\n"},
+		{&TextFormatter{Flag: Ltime, TimeFormat: TimeFormatRFC3339Nano}, "[2014-06-01T10:00:00.123456789Z] hello\n"},
+		{&TextFormatter{Flag: Ltime, TimeFormat: TimeFormatEpochMillis}, "[1401616800123] hello\n"},
+		{&JSONFormatter{Flag: Ltime, TimeFormat: TimeFormatEpochNanos}, `{"time":1401616800123456789,"msg":"hello"}` + "\n"},
+		{&JSONFormatter{Flag: Ltime, TimeFormat: TimeFormatISO8601}, `{"time":"2014-06-01T10:00:00.123Z","msg":"hello"}` + "\n"},
+		{&LogfmtFormatter{Flag: Ltime, TimeFormat: TimeFormatEpochMillis}, "time=1401616800123 msg=hello\n"},
+	} {
+		if s := string(c.f.Format(LevelInfo, tm, "hello", nil)); s != c.s {
+			t.Fatal(s)
+		}
+	}
+
+	th, buf := NewTestHandler()
+	l := New(th, Ltime|Ljson)
+	if err := l.SetTimeFormatPreset("epoch_micros"); err == nil {
+		t.Fatal("must fail")
+	}
+	if err := l.SetTimeFormatPreset(TimeFormatEpochMillis); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("hello")
+	l.Close()
+
+	if !regexp.MustCompile(`^\{"time":\d{13},"msg":"hello"\}\n$`).MatchString(buf.String()) {
+		t.Fatal(buf.String())
+	}
+
+	if s := string(sampleKey([]byte(`{"time":1401616800123,"msg":"hello"}`))); s != `"msg":"hello"}` {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+//FrameHandler writes every log as a frame, a 4-byte big-endian length followed by
+//the log, to another handler, e.g. to feed a binary protocol.
+//
+//It assumes one Write is one log, which holds for a Logger, so it must not wrap
+//handlers writing partial logs. A frame is written with a single Write to the
+//wrapped handler, so frames are not interleaved if its writes are not.
+//
+//It is safe for concurrent use if the wrapped handler is.
+type FrameHandler struct {
+	h Handler
+}
+
+func NewFrameHandler(h Handler) (*FrameHandler, error) {
+	f := new(FrameHandler)
+
+	f.h = h
+
+	return f, nil
+}
+
+func (h *FrameHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p as a frame, n is len(p) if the frame is written.
+func (h *FrameHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	if uint64(len(p)) > math.MaxUint32 {
+		return 0, fmt.Errorf("log of %d bytes is too large for a frame", len(p))
+	}
+
+	frame := make([]byte, 4+len(p))
+	binary.BigEndian.PutUint32(frame, uint32(len(p)))
+	copy(frame[4:], p)
+
+	if _, err = writeLevel(h.h, level, frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Sync syncs the wrapped handler.
+func (h *FrameHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *FrameHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *FrameHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *FrameHandler) Close() error {
+	return h.h.Close()
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestFrameHandler(t *testing.T) {
+	th, buf := NewTestHandler()
+	h, _ := NewFrameHandler(th)
+
+	l := New(h, 0)
+	l.Info("hello")
+	l.Info("")
+	l.Info("world")
+	l.Close()
+
+	b := buf.Bytes()
+	for _, s := range []string{"hello\n", "\n", "world\n"} {
+		if len(b) < 4 {
+			t.Fatal(len(b))
+		}
+		n := int(binary.BigEndian.Uint32(b))
+		if string(b[4:4+n]) != s {
+			t.Fatal(string(b[4 : 4+n]))
+		}
+		b = b[4+n:]
+	}
+	if len(b) != 0 {
+		t.Fatal(len(b))
+	}
+}
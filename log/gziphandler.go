@@ -0,0 +1,96 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"sync"
+)
+
+//GzipHandler compresses logs with gzip then writes them to another handler.
+//
+//It is safe for concurrent use, so Flush can be called periodically
+//from another goroutine.
+type GzipHandler struct {
+	mu sync.Mutex
+
+	h Handler
+	w *gzip.Writer
+}
+
+//NewGzipHandler creates a GzipHandler writing to h, level is the gzip compression level,
+//gzip.BestSpeed to gzip.BestCompression, gzip.DefaultCompression, gzip.NoCompression
+//or gzip.HuffmanOnly, an error is returned for others.
+func NewGzipHandler(h Handler, level int) (*GzipHandler, error) {
+	if err := checkGzipLevel(level); err != nil {
+		return nil, err
+	}
+
+	w, err := gzip.NewWriterLevel(h, level)
+	if err != nil {
+		return nil, err
+	}
+
+	g := new(GzipHandler)
+
+	g.h = h
+	g.w = w
+
+	return g, nil
+}
+
+//checkGzipLevel returns an error if level is not a gzip compression level.
+func checkGzipLevel(level int) error {
+	switch {
+	case level >= gzip.BestSpeed && level <= gzip.BestCompression:
+	case level == gzip.DefaultCompression, level == gzip.NoCompression, level == gzip.HuffmanOnly:
+	default:
+		return fmt.Errorf("invalid gzip level %d, must be %d (fastest) to %d (smallest), %d (default), %d (none) or %d (huffman only)",
+			level, gzip.BestSpeed, gzip.BestCompression, gzip.DefaultCompression, gzip.NoCompression, gzip.HuffmanOnly)
+	}
+	return nil
+}
+
+func (h *GzipHandler) Write(p []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.w.Write(p)
+}
+
+//Flush writes all pending compressed data to the wrapped handler, then flushes it,
+//everything written before can then be decompressed even if the stream is not closed.
+func (h *GzipHandler) Flush() error {
+	h.mu.Lock()
+	err := h.w.Flush()
+	h.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return flushHandler(h.h)
+}
+
+//Sync flushes pending compressed data and syncs the wrapped handler.
+func (h *GzipHandler) Sync() error {
+	if err := h.Flush(); err != nil {
+		return err
+	}
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *GzipHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Close flushes the gzip stream, writes the gzip footer and closes the wrapped handler.
+func (h *GzipHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.w.Close()
+	if e := h.h.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGzipHandler(t *testing.T) {
+	var buf bytes.Buffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, err := NewGzipHandler(s, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("hello world\n"))
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("tail\n"))
+	h.Close()
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello world\ntail\n" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestGzipHandlerLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly, gzip.DefaultCompression, gzip.NoCompression, gzip.BestSpeed, gzip.BestCompression} {
+		h, err := NewGzipHandler(DiscardHandler(), level)
+		if err != nil {
+			t.Fatal(level, err)
+		}
+		h.Close()
+	}
+
+	for _, level := range []int{-3, 10, 42} {
+		if _, err := NewGzipHandler(DiscardHandler(), level); err == nil {
+			t.Fatal(level, "must error")
+		}
+	}
+}
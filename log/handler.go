@@ -1,20 +1,286 @@
 package log
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 //Handler writes logs to somewhere
+//
+//The Logger reuses p after Write returns, a handler must copy p to retain it.
 type Handler interface {
 	Write(p []byte) (n int, err error)
 	Close() error
 }
 
+//ErrHandlerClosed is returned by writes to a file handler after Close,
+//closing it again is a no-op returning nil.
+var ErrHandlerClosed = errors.New("log: handler closed")
+
+//ErrNilHandler is returned by writes to a nil handler, e.g. by a Logger
+//created with a nil handler.
+//
+//Built with -tags logdebug, writing to a nil or closed handler panics instead
+//of returning ErrNilHandler or ErrHandlerClosed, so tests catch lifecycle bugs,
+//e.g. go test -tags logdebug ./...
+var ErrNilHandler = errors.New("log: nil handler")
+
+//handlers are io.WriteCloser, they can be used wherever one is expected
+var (
+	_ io.WriteCloser = (*AsyncHandler)(nil)
+	_ io.WriteCloser = (*BufferedHandler)(nil)
+	_ io.WriteCloser = (*ChannelHandler)(nil)
+	_ io.WriteCloser = (*CountingHandler)(nil)
+	_ io.WriteCloser = (*DBHandler)(nil)
+	_ io.WriteCloser = (*DateFileHandler)(nil)
+	_ io.WriteCloser = (*DedupHandler)(nil)
+	_ io.WriteCloser = (*FailoverHandler)(nil)
+	_ io.WriteCloser = (*FileHandler)(nil)
+	_ io.WriteCloser = (*FilterHandler)(nil)
+	_ io.WriteCloser = (*FormatHandler)(nil)
+	_ io.WriteCloser = (*FrameHandler)(nil)
+	_ io.WriteCloser = (*GzipHandler)(nil)
+	_ io.WriteCloser = (*HookHandler)(nil)
+	_ io.WriteCloser = (*LevelRouterHandler)(nil)
+	_ io.WriteCloser = (*MultiHandler)(nil)
+	_ io.WriteCloser = (*NullHandler)(nil)
+	_ io.WriteCloser = (*RateLimitHandler)(nil)
+	_ io.WriteCloser = (*RedactHandler)(nil)
+	_ io.WriteCloser = (*RingBufferHandler)(nil)
+	_ io.WriteCloser = (*RotatingFileHandler)(nil)
+	_ io.WriteCloser = (*SamplingHandler)(nil)
+	_ io.WriteCloser = (*ShardHandler)(nil)
+	_ io.WriteCloser = (*SocketHandler)(nil)
+	_ io.WriteCloser = (*SplitHandler)(nil)
+	_ io.WriteCloser = (*StreamHandler)(nil)
+	_ io.WriteCloser = (*TCPHandler)(nil)
+	_ io.WriteCloser = (*TeeOnLevelHandler)(nil)
+	_ io.WriteCloser = (*TestHandler)(nil)
+	_ io.WriteCloser = (*TestTap)(nil)
+	_ io.WriteCloser = (*TimeRotatingFileHandler)(nil)
+	_ io.WriteCloser = (*UnsafeFileHandler)(nil)
+)
+
+//HandlerFromWriteCloser returns wc as a Handler, e.g. a pipe or a connection,
+//the Logger writes a whole log with each Write and closes wc on Close.
+//The method sets of Handler and io.WriteCloser are the same, so no adapter is needed.
+func HandlerFromWriteCloser(wc io.WriteCloser) Handler {
+	return wc
+}
+
+//Named is implemented by handlers which have a name for diagnostics,
+//e.g. "file:/var/log/app.log" or "stream:stderr".
+type Named interface {
+	Name() string
+}
+
+//handlerName returns the name of h if h implements Named, else its type.
+func handlerName(h Handler) string {
+	if n, ok := h.(Named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", h)
+}
+
+//LevelWriter is implemented by handlers which need the level of logs,
+//the Logger calls WriteLevel instead of Write if its handler implements it.
+type LevelWriter interface {
+	WriteLevel(level int, p []byte) (n int, err error)
+}
+
+//writeLevel writes p with level to h if h implements LevelWriter, else writes p.
+func writeLevel(h Handler, level int, p []byte) (int, error) {
+	if h == nil {
+		return 0, misuse(ErrNilHandler)
+	}
+	if w, ok := h.(LevelWriter); ok {
+		return w.WriteLevel(level, p)
+	}
+	return h.Write(p)
+}
+
+//TimeWriter is implemented by handlers deciding on the time of logs, e.g. rotation,
+//the Logger calls WriteTime with the time formatted in the log, instead of WriteLevel,
+//so the log and the rollover never disagree around a boundary.
+//MultiHandler and LevelRouterHandler pass the time on, other wrapping handlers
+//call WriteLevel, then the wrapped handler reads its own clock.
+type TimeWriter interface {
+	WriteTime(level int, t time.Time, p []byte) (n int, err error)
+}
+
+//writeTime writes p with level and t to h if h implements TimeWriter, else like writeLevel.
+func writeTime(h Handler, level int, t time.Time, p []byte) (int, error) {
+	if w, ok := h.(TimeWriter); ok {
+		return w.WriteTime(level, t, p)
+	}
+	return writeLevel(h, level, p)
+}
+
+//BatchWriter is implemented by handlers which can write many logs at once,
+//e.g. with a single syscall, AsyncHandler writes queued logs in batches.
+type BatchWriter interface {
+	WriteBatch(records [][]byte) (n int, err error)
+}
+
+//writeBatch writes records to h with WriteBatch if h implements BatchWriter,
+//else with a Write for each record, n is the number of bytes written.
+func writeBatch(h Handler, records [][]byte) (n int, err error) {
+	if w, ok := h.(BatchWriter); ok {
+		return w.WriteBatch(records)
+	}
+
+	for _, p := range records {
+		m, e := h.Write(p)
+		n += m
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+//writeFull writes all of p to w, writing the rest again after a short write,
+//which is legal for pipes and sockets, so a log is only cut by a real error.
+//A short write without error returns io.ErrShortWrite.
+func writeFull(w io.Writer, p []byte) (n int, err error) {
+	for n < len(p) {
+		var m int
+		m, err = w.Write(p[n:])
+		n += m
+		if err != nil {
+			return
+		} else if m == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return
+}
+
+//writeStringFull is writeFull for a string, without converting it if w implements io.StringWriter.
+func writeStringFull(w io.Writer, s string) (n int, err error) {
+	for n < len(s) {
+		var m int
+		m, err = io.WriteString(w, s[n:])
+		n += m
+		if err != nil {
+			return
+		} else if m == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return
+}
+
+//joinRecords returns records joined into one buffer.
+func joinRecords(records [][]byte) []byte {
+	size := 0
+	for _, p := range records {
+		size += len(p)
+	}
+
+	buf := make([]byte, 0, size)
+	for _, p := range records {
+		buf = append(buf, p...)
+	}
+	return buf
+}
+
+//Syncer is implemented by handlers which can commit written logs to stable storage,
+//the Logger syncs its handler after a fatal log and on Logger.Sync.
+type Syncer interface {
+	Sync() error
+}
+
+//syncHandler syncs h if it implements Syncer.
+func syncHandler(h Handler) error {
+	if s, ok := h.(Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+//Flusher is implemented by handlers buffering logs, e.g. in memory or for a
+//disconnected socket, Flush writes them on without closing or syncing the handler.
+//Handlers wrapping others flush them too, so flushing the head flushes the chain.
+type Flusher interface {
+	Flush() error
+}
+
+//flushHandler flushes h if it implements Flusher.
+func flushHandler(h Handler) error {
+	if f, ok := h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+//HealthChecker is implemented by handlers which can tell if logs can be written,
+//e.g. the file is writable or the connection is up, see Logger.HealthCheck.
+//Handlers wrapping others check them too, so checking the head checks the chain.
+type HealthChecker interface {
+	Check() error
+}
+
+//checkHandler checks h if it implements HealthChecker, else h is taken as healthy.
+func checkHandler(h Handler) error {
+	if c, ok := h.(HealthChecker); ok {
+		return c.Check()
+	}
+	return nil
+}
+
+//color modes of StreamHandler
+const (
+	ColorNever  = iota //plain text, the default
+	ColorAuto          //color if the writer is a terminal
+	ColorAlways        //color even if the writer is not a terminal
+)
+
+//ANSI colors of levels
+var levelColor = [6]string{"\x1b[90m", "\x1b[36m", "\x1b[32m", "\x1b[33m", "\x1b[31m", "\x1b[35m"}
+
+const colorReset = "\x1b[0m"
+
 //StreamHandler writes logs to a specified io Writer, maybe stdout, stderr, etc...
+//
+//It is safe for concurrent use only if the writer is.
 type StreamHandler struct {
+	//logs dropped by the write timeout, first to be 64-bit aligned for atomic
+	dropped int64
+
 	w io.Writer
+
+	color bool
+
+	//partial line buffered if lineBuffered, see SetLineBuffered
+	mu           sync.Mutex
+	lineBuffered bool
+	pending      []byte
+
+	//writes are queued to a writer goroutine if timeout is set, see SetWriteTimeout
+	timeout time.Duration
+	queue   chan streamWrite
+	quit    chan struct{}
+}
+
+//streamWrite is a write queued to the writer goroutine of a StreamHandler,
+//done is closed when the writes queued before are written.
+type streamWrite struct {
+	b    []byte
+	done chan struct{}
 }
 
+//streamQueueSize is the number of writes queued to a StreamHandler with a write timeout.
+const streamQueueSize = 1024
+
+var errWriteTimeout = errors.New("log: stream write timed out")
+
 func NewStreamHandler(w io.Writer) (*StreamHandler, error) {
 	h := new(StreamHandler)
 
@@ -24,15 +290,272 @@ func NewStreamHandler(w io.Writer) (*StreamHandler, error) {
 }
 
 func (h *StreamHandler) Write(b []byte) (n int, err error) {
-	return h.w.Write(b)
+	return h.write(b)
 }
 
-func (h *StreamHandler) Close() error {
+//WriteBatch writes records with a single write to the writer.
+func (h *StreamHandler) WriteBatch(records [][]byte) (n int, err error) {
+	return h.write(joinRecords(records))
+}
+
+func (h *StreamHandler) write(b []byte) (n int, err error) {
+	if !h.lineBuffered {
+		return h.out(b)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := bytes.LastIndexByte(b, '\n')
+	if i < 0 {
+		h.pending = append(h.pending, b...)
+		return len(b), nil
+	}
+
+	if len(h.pending) == 0 {
+		_, err = h.out(b[0 : i+1])
+	} else {
+		h.pending = append(h.pending, b[0:i+1]...)
+		_, err = h.out(h.pending)
+		h.pending = h.pending[0:0]
+	}
+	h.pending = append(h.pending, b[i+1:]...)
+
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+//SetLineBuffered makes writes be buffered until a newline, and complete lines
+//be written with a single Write to the writer, so they are not interleaved
+//with other writers of it, e.g. fmt.Println to os.Stdout, even if a log
+//is written in pieces, e.g. through the io.Writer of the handler.
+//A partial line is written by Flush, Sync and Close.
+//It must be set before logging, it is off by default.
+func (h *StreamHandler) SetLineBuffered(on bool) {
+	h.lineBuffered = on
+}
+
+//Flush writes the buffered partial line if line buffered, see SetLineBuffered.
+func (h *StreamHandler) Flush() error {
+	if !h.lineBuffered {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.pending) == 0 {
+		return nil
+	}
+	_, err := h.out(h.pending)
+	h.pending = h.pending[0:0]
+	return err
+}
+
+//SetWriteTimeout makes writes be queued to a goroutine writing the writer, and
+//a log be dropped if it can not be queued within d, e.g. as the reader of a stdout
+//pipe stalled, like a paused less, so a stuck writer never freezes the application.
+//1024 writes are queued, dropped logs are counted by Dropped, and the write
+//returns an error, so Metrics of a Logger count them too.
+//Sync and Close wait at most d for the queued writes.
+//It must be set before logging, writes block by default.
+func (h *StreamHandler) SetWriteTimeout(d time.Duration) {
+	h.timeout = d
+	if d <= 0 || h.queue != nil {
+		return
+	}
+
+	h.queue = make(chan streamWrite, streamQueueSize)
+	h.quit = make(chan struct{})
+	go h.run(h.queue, h.quit)
+}
+
+func (h *StreamHandler) run(queue chan streamWrite, quit chan struct{}) {
+	for {
+		select {
+		case w := <-queue:
+			if w.done != nil {
+				close(w.done)
+			} else if _, err := writeFull(h.w, w.b); err != nil {
+				atomic.AddInt64(&h.dropped, 1)
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+//Dropped returns the number of logs dropped by the write timeout, or failed
+//to be written by the writer goroutine, see SetWriteTimeout.
+func (h *StreamHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+//out writes b to the writer, or queues it if a write timeout is set.
+func (h *StreamHandler) out(b []byte) (int, error) {
+	if h.queue == nil {
+		return writeFull(h.w, b)
+	}
+
+	//b is reused after Write returns
+	if err := h.enqueue(streamWrite{b: append([]byte(nil), b...)}); err != nil {
+		atomic.AddInt64(&h.dropped, 1)
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (h *StreamHandler) enqueue(w streamWrite) error {
+	select {
+	case h.queue <- w:
+		return nil
+	default:
+	}
+
+	t := time.NewTimer(h.timeout)
+	defer t.Stop()
+
+	select {
+	case h.queue <- w:
+		return nil
+	case <-t.C:
+		return errWriteTimeout
+	}
+}
+
+//drain waits at most the write timeout until the queued writes are written.
+func (h *StreamHandler) drain() error {
+	if h.queue == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	if err := h.enqueue(streamWrite{done: done}); err != nil {
+		return err
+	}
+
+	t := time.NewTimer(h.timeout)
+	defer t.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-t.C:
+		return errWriteTimeout
+	}
+}
+
+//WriteLevel writes b colored by level if color is enabled with SetColor.
+func (h *StreamHandler) WriteLevel(level int, b []byte) (n int, err error) {
+	if !h.color || level < 0 || level >= len(levelColor) {
+		return h.write(b)
+	}
+
+	line := b
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[0 : len(line)-1]
+	}
+
+	buf := make([]byte, 0, len(b)+len(levelColor[level])+len(colorReset))
+	buf = append(buf, levelColor[level]...)
+	buf = append(buf, line...)
+	buf = append(buf, colorReset...)
+	buf = append(buf, b[len(line):]...)
+
+	if _, err = h.write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+//SetColor sets the color mode, one of ColorNever, ColorAuto and ColorAlways.
+//Files and pipes are never colored with ColorAuto,
+//so logs are not polluted with escape sequences, see isTerminal.
+func (h *StreamHandler) SetColor(mode int) {
+	switch mode {
+	case ColorAuto:
+		h.color = isTerminal(h.w)
+	case ColorAlways:
+		h.color = true
+	default:
+		h.color = false
+	}
+}
+
+//WriteString writes s without converting it to a byte slice if the writer
+//implements io.StringWriter, like *os.File and *bytes.Buffer.
+func (h *StreamHandler) WriteString(s string) (n int, err error) {
+	if h.lineBuffered || h.queue != nil {
+		return h.write([]byte(s))
+	}
+	return writeStringFull(h.w, s)
+}
+
+//Sync commits the writer to stable storage if it implements Syncer, like *os.File,
+//else it does nothing. Terminals and pipes can not be synced and are skipped.
+func (h *StreamHandler) Sync() error {
+	if err := h.Flush(); err != nil {
+		return err
+	}
+	if err := h.drain(); err != nil {
+		return err
+	}
+
+	switch w := h.w.(type) {
+	case *os.File:
+		if fi, err := w.Stat(); err == nil && !fi.Mode().IsRegular() {
+			return nil
+		}
+		return w.Sync()
+	case Syncer:
+		return w.Sync()
+	}
 	return nil
 }
 
+//Name returns "stream:" and stdout, stderr, the file name or the writer type.
+func (h *StreamHandler) Name() string {
+	switch h.w {
+	case os.Stdout:
+		return "stream:stdout"
+	case os.Stderr:
+		return "stream:stderr"
+	}
+
+	if f, ok := h.w.(*os.File); ok {
+		return "stream:" + f.Name()
+	}
+	return fmt.Sprintf("stream:%T", h.w)
+}
+
+//Close writes the buffered partial line if line buffered, and waits for queued
+//writes if a write timeout is set, the writer is not closed.
+func (h *StreamHandler) Close() error {
+	h.mu.Lock()
+	closed := h.queue != nil && h.quit == nil
+	h.mu.Unlock()
+	if closed {
+		return nil
+	}
+
+	err := h.Flush()
+	if e := h.drain(); err == nil {
+		err = e
+	}
+
+	h.mu.Lock()
+	if h.quit != nil {
+		close(h.quit)
+		h.quit = nil
+	}
+	h.mu.Unlock()
+	return err
+}
+
 
-//NullHandler does nothing, it discards anything. 
+//NullHandler does nothing, it discards anything.
 type NullHandler struct {
 }
 
@@ -40,10 +563,18 @@ func NewNullHandler() (*NullHandler, error) {
 	return new(NullHandler), nil
 }
 
+var discardHandler = new(NullHandler)
+
+//DiscardHandler returns a shared NullHandler, for disabling logging
+//without nil checks.
+func DiscardHandler() *NullHandler {
+	return discardHandler
+}
+
 func (h *NullHandler) Write(b []byte) (n int, err error) {
 	return len(b), nil
 }
 
-func (h *NullHandler) Close() {
-
+func (h *NullHandler) Close() error {
+	return nil
 }
@@ -0,0 +1,216 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamHandlerColor(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+
+	h.SetColor(ColorAuto)
+	h.WriteLevel(LevelError, []byte("plain\n"))
+
+	h.SetColor(ColorAlways)
+	h.WriteLevel(LevelError, []byte("red\n"))
+
+	if buf.String() != "plain\n\x1b[31mred\x1b[0m\n" {
+		t.Fatalf("%q", buf.String())
+	}
+
+	f, err := os.Create("./test_color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("./test_color")
+	defer f.Close()
+
+	if isTerminal(f) {
+		t.Fatal("a file is not a terminal")
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	h, _ := NewStreamHandler(os.Stderr)
+	if n := handlerName(h); n != "stream:stderr" {
+		t.Fatal(n)
+	}
+
+	h, _ = NewStreamHandler(new(bytes.Buffer))
+	if n := handlerName(h); n != "stream:*bytes.Buffer" {
+		t.Fatal(n)
+	}
+
+	f, _ := NewFileHandler("./test_name.log", os.O_CREATE|os.O_WRONLY)
+	defer os.Remove("./test_name.log")
+	f.Close()
+	if n := handlerName(f); n != "file:./test_name.log" {
+		t.Fatal(n)
+	}
+}
+
+func TestHandlerFromWriteCloser(t *testing.T) {
+	r, w := io.Pipe()
+
+	//ReadAll returns on EOF, so only if Close reached w
+	read := make(chan string, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		read <- string(b)
+	}()
+
+	l := New(HandlerFromWriteCloser(w), 0)
+	l.Info("a")
+	l.Info("b")
+	l.Close()
+
+	select {
+	case s := <-read:
+		if s != "a\nb\n" {
+			t.Fatal(s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not closed")
+	}
+}
+
+func TestStreamHandlerSync(t *testing.T) {
+	f, err := os.Create("./test_stream_sync.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("./test_stream_sync.log")
+
+	h, _ := NewStreamHandler(f)
+	if err := h.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a closed file can not be synced
+	f.Close()
+	if err := h.Sync(); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h, _ = NewStreamHandler(new(bytes.Buffer))
+	if err := h.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//shortWriter writes at most max bytes a call, like a pipe under pressure.
+type shortWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		p = p[0:w.max]
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestStreamHandlerShortWrite(t *testing.T) {
+	w := &shortWriter{max: 3}
+	h, _ := NewStreamHandler(w)
+
+	if n, err := h.Write([]byte("hello world\n")); err != nil || n != 12 {
+		t.Fatal(n, err)
+	}
+	if n, err := h.WriteBatch([][]byte{[]byte("a\n"), []byte("bcde\n")}); err != nil || n != 7 {
+		t.Fatal(n, err)
+	}
+
+	if w.String() != "hello world\na\nbcde\n" {
+		t.Fatal(w.String())
+	}
+
+	w.max = 0
+	if n, err := h.Write([]byte("lost\n")); err != io.ErrShortWrite || n != 0 {
+		t.Fatal(n, err)
+	}
+}
+
+//callWriter records every Write call.
+type callWriter struct {
+	calls []string
+}
+
+func (w *callWriter) Write(p []byte) (int, error) {
+	w.calls = append(w.calls, string(p))
+	return len(p), nil
+}
+
+func TestStreamHandlerLineBuffered(t *testing.T) {
+	w := new(callWriter)
+	h, _ := NewStreamHandler(w)
+	h.SetLineBuffered(true)
+
+	h.Write([]byte("hel"))
+	h.WriteString("lo\nwor")
+	h.Write([]byte("ld\nab\ncd\n"))
+	h.Write([]byte("tail"))
+
+	if len(w.calls) != 2 || w.calls[0] != "hello\n" || w.calls[1] != "world\nab\ncd\n" {
+		t.Fatalf("%q", w.calls)
+	}
+
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if len(w.calls) != 3 || w.calls[2] != "tail" {
+		t.Fatalf("%q", w.calls)
+	}
+}
+
+//blockWriter blocks writes until release is closed.
+type blockWriter struct {
+	release chan struct{}
+	buf     lockedBuffer
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.buf.Write(p)
+}
+
+func TestStreamHandlerWriteTimeout(t *testing.T) {
+	w := &blockWriter{release: make(chan struct{})}
+	h, _ := NewStreamHandler(w)
+	h.SetWriteTimeout(10 * time.Millisecond)
+
+	//the writer goroutine blocks on the first write, so the queue fills up
+	for i := 0; i < streamQueueSize+1; i++ {
+		if _, err := h.Write([]byte("a\n")); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if _, err := h.Write([]byte("lost\n")); err != errWriteTimeout {
+		t.Fatal(err)
+	}
+	if n := h.Dropped(); n != 1 {
+		t.Fatal(n)
+	}
+	if err := h.Sync(); err != errWriteTimeout {
+		t.Fatal(err)
+	}
+
+	close(w.release)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(w.buf.String(), "a\n"); n != streamQueueSize+1 {
+		t.Fatal(n)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"sync"
+)
+
+//HookFunc is called with the level and line of a log by HookHandler,
+//line must not be retained after the call returns.
+type HookFunc func(level int, line []byte)
+
+type hookEntry struct {
+	level int
+	line  []byte
+}
+
+//HookHandler writes logs to another handler, and calls a hook for logs
+//with level >= its threshold, e.g. to send a metric or alert on errors.
+//
+//A synchronous hook is called in Write after the wrapped handler writes the log,
+//so a slow hook slows logging down. An asynchronous hook is called in another
+//goroutine, logs are dropped for the hook if it can not keep up.
+//A Write without level, e.g. not from a Logger, is treated as LevelInfo.
+type HookHandler struct {
+	h     Handler
+	level int
+	hook  HookFunc
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan hookEntry
+	wg     sync.WaitGroup
+
+	metrics metricsValue
+}
+
+const hookQueueSize = 128
+
+func NewHookHandler(h Handler, level int, hook HookFunc, async bool) (*HookHandler, error) {
+	k := new(HookHandler)
+
+	k.h = h
+	k.level = level
+	k.hook = hook
+
+	if async {
+		k.queue = make(chan hookEntry, hookQueueSize)
+
+		k.wg.Add(1)
+		go k.run()
+	}
+
+	return k, nil
+}
+
+func (h *HookHandler) run() {
+	defer h.wg.Done()
+
+	for e := range h.queue {
+		h.callHook(e)
+	}
+}
+
+//callHook calls the hook in run, a panic is recovered so later logs are still hooked.
+func (h *HookHandler) callHook(e hookEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			backgroundPanic(r, h.metrics.load())
+		}
+	}()
+
+	h.hook(e.level, e.line)
+}
+
+//SetMetrics makes panics of an asynchronous hook be reported to m.IncHandlerPanic
+//if m is a HandlerPanicMetrics. A synchronous hook panics in Write, which
+//the Logger recovers, see Logger.SetRecoverPanics. A nil m disables it.
+func (h *HookHandler) SetMetrics(m Metrics) {
+	h.metrics.store(m)
+}
+
+func (h *HookHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *HookHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	n, err = writeLevel(h.h, level, p)
+
+	if level < h.level {
+		return
+	}
+
+	if h.queue == nil {
+		h.hook(level, p)
+		return
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	h.mu.RLock()
+	if !h.closed {
+		select {
+		case h.queue <- hookEntry{level, line}:
+		default:
+		}
+	}
+	h.mu.RUnlock()
+
+	return
+}
+
+//Flush flushes the wrapped handler.
+func (h *HookHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+//Sync syncs the wrapped handler, queued hooks are not waited for.
+func (h *HookHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *HookHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Close waits for queued hooks to be called, then closes the wrapped handler.
+func (h *HookHandler) Close() error {
+	if h.queue != nil {
+		h.mu.Lock()
+		if !h.closed {
+			h.closed = true
+			close(h.queue)
+		}
+		h.mu.Unlock()
+
+		h.wg.Wait()
+	}
+
+	return h.h.Close()
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHookHandler(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		var lines []string
+		hook := func(level int, line []byte) {
+			lines = append(lines, string(line))
+		}
+
+		h, _ := NewHookHandler(DiscardHandler(), LevelError, hook, async)
+
+		l := New(h, 0)
+		l.Info("hello")
+		l.Error("failed")
+		l.Close()
+
+		if len(lines) != 1 || lines[0] != "failed\n" {
+			t.Fatal(async, lines)
+		}
+	}
+}
+
+func TestHookHandlerSync(t *testing.T) {
+	var buf bytes.Buffer
+	s := new(syncCountHandler)
+	s.w = &buf
+
+	h, _ := NewHookHandler(s, LevelError, func(int, []byte) {}, true)
+	l := New(h, 0)
+	l.Fatal("fatal")
+
+	//the fatal log is synced through the hook handler
+	if buf.String() != "fatal\n" || s.syncs != 1 {
+		t.Fatal(buf.String(), s.syncs)
+	}
+	l.Close()
+}
+
+func TestHookHandlerCheck(t *testing.T) {
+	path := "./test_log_hook_check"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	fh, _ := NewFileHandler(path+"/file", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	h, _ := NewHookHandler(fh, LevelError, func(int, []byte) {}, true)
+	l := New(h, 0)
+
+	if err := l.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the file is checked through the hook handler
+	os.Remove(path + "/file")
+	if err := l.HealthCheck(); err == nil {
+		t.Fatal("must fail")
+	}
+	l.Close()
+}
+
+func TestHookHandlerPanic(t *testing.T) {
+	var fallback lockedBuffer
+	handlerPanicFallback = &fallback
+	defer func() {
+		handlerPanicFallback = os.Stderr
+	}()
+
+	var lines []string
+	hook := func(level int, line []byte) {
+		if bytes.Contains(line, []byte("boom")) {
+			panic("bug")
+		}
+		lines = append(lines, string(line))
+	}
+
+	h, _ := NewHookHandler(DiscardHandler(), LevelError, hook, true)
+	m := &testMetrics{levels: make(map[int]int)}
+	h.SetMetrics(m)
+
+	l := New(h, 0)
+	l.Error("boom")
+	l.Error("a")
+	l.Close()
+
+	if len(lines) != 1 || lines[0] != "a\n" || m.handlerPanics != 1 {
+		t.Fatal(lines, m.handlerPanics)
+	}
+	if !strings.Contains(fallback.String(), "bug") {
+		t.Fatal(fallback.String())
+	}
+}
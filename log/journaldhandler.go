@@ -0,0 +1,182 @@
+// +build linux
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+var _ io.WriteCloser = (*JournaldHandler)(nil)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+//JournaldHandler sends logs to journald with its native protocol, a datagram per log,
+//with MESSAGE, PRIORITY mapped from the level and SYSLOG_IDENTIFIER, the program name.
+//
+//If a log is a json object, e.g. with Ljson, its keys are sent as fields, upper cased
+//with invalid characters replaced by '_', msg is sent as MESSAGE, and time and level
+//are skipped as journald has its own, so fields can be queried with journalctl.
+//Logs too large for a datagram are not supported.
+type JournaldHandler struct {
+	c          *net.UnixConn
+	socket     string
+	identifier string
+
+	closed int32
+}
+
+//NewJournaldHandler connects to the journald socket, an error is returned if it is missing.
+func NewJournaldHandler() (*JournaldHandler, error) {
+	return newJournaldHandler(journaldSocket)
+}
+
+func newJournaldHandler(socket string) (*JournaldHandler, error) {
+	if _, err := os.Stat(socket); err != nil {
+		return nil, err
+	}
+
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(JournaldHandler)
+
+	h.c = c
+	h.socket = socket
+	h.identifier = filepath.Base(os.Args[0])
+
+	return h, nil
+}
+
+//journaldPriority returns the syslog priority of level.
+func journaldPriority(level int) int {
+	switch {
+	case level <= LevelDebug:
+		return 7
+	case level == LevelInfo:
+		return 6
+	case level == LevelWarn:
+		return 4
+	case level == LevelError:
+		return 3
+	default:
+		return 2
+	}
+}
+
+//journaldFieldName makes key a valid journald field name.
+func journaldFieldName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+
+	//leading '_' is reserved for trusted fields
+	name := strings.TrimLeft(string(b), "_")
+	if len(name) == 0 {
+		return "FIELD"
+	} else if name[0] >= '0' && name[0] <= '9' {
+		return "F" + name
+	}
+	return name
+}
+
+//appendJournaldField appends a field, values with newlines are sent length prefixed.
+func appendJournaldField(buf []byte, name string, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+
+	buf = append(buf, '\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf = append(buf, size[:]...)
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+func (h *JournaldHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *JournaldHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+
+	var buf []byte
+	buf = appendJournaldField(buf, "PRIORITY", fmt.Sprint(journaldPriority(level)))
+	buf = appendJournaldField(buf, "SYSLOG_IDENTIFIER", h.identifier)
+
+	var fields map[string]interface{}
+	if len(msg) > 0 && msg[0] == '{' && json.Unmarshal([]byte(msg), &fields) == nil {
+		if m, ok := fields["msg"].(string); ok {
+			msg = m
+		}
+
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			switch k {
+			case "msg", "time", "level":
+				continue
+			}
+
+			v, ok := fields[k].(string)
+			if !ok {
+				b, _ := json.Marshal(fields[k])
+				v = string(b)
+			}
+			buf = appendJournaldField(buf, journaldFieldName(k), v)
+		}
+	}
+
+	buf = appendJournaldField(buf, "MESSAGE", msg)
+
+	if _, err = h.c.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Name returns "journald".
+func (h *JournaldHandler) Name() string {
+	return "journald"
+}
+
+//Check connects to the journald socket again, an error is returned if journald
+//is not listening on it any more, e.g. it was stopped.
+func (h *JournaldHandler) Check() error {
+	if atomic.LoadInt32(&h.closed) == 1 {
+		return misuse(ErrHandlerClosed)
+	}
+
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: h.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+func (h *JournaldHandler) Close() error {
+	atomic.StoreInt32(&h.closed, 1)
+	return h.c.Close()
+}
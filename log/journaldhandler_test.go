@@ -0,0 +1,96 @@
+// +build linux
+
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournaldHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	h, err := newJournaldHandler(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, Ltime|Llevel|Ljson)
+	l.WithField("request-id", 1).Error("hello\nworld")
+	l.Close()
+
+	buf := make([]byte, 4096)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(buf[0:n])
+	if !strings.HasPrefix(s, "PRIORITY=3\n") || !strings.Contains(s, "\nREQUEST_ID=1\n") {
+		t.Fatal(s)
+	}
+	if !strings.HasSuffix(s, "MESSAGE\n\x0b\x00\x00\x00\x00\x00\x00\x00hello\nworld\n") {
+		t.Fatalf("%q", s)
+	}
+	if strings.Contains(s, "TIME") {
+		t.Fatal(s)
+	}
+}
+
+func TestJournaldHandlerCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := newJournaldHandler(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	//journald stopped
+	c.Close()
+	if err := h.Check(); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h.Close()
+	if err := misused(h.Check); !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal(err)
+	}
+}
+
+func TestJournaldHandlerMissing(t *testing.T) {
+	if _, err := newJournaldHandler("./test_journald_missing"); err == nil {
+		t.Fatal("must fail")
+	}
+}
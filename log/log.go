@@ -1,13 +1,20 @@
 package log
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 //log level, from low to high, more high means more serious
@@ -21,16 +28,134 @@ const (
 )
 
 const (
-	Ltime  = 1 << iota //time format "2006/01/02 15:04:05"
-	Lfile              //file.go:123
-	Llevel             //[Trace|Debug|Info...]
+	Ltime      = 1 << iota //time format "2006/01/02 15:04:05", see SetTimeFormat
+	Lfile                  //file.go:123
+	Llevel                 //[Trace|Debug|Info...]
+	Ljson                  //a json object per line, Ltime, Lfile and Llevel select its keys
+	Lmsgprefix             //put the prefix set by SetPrefix before the message instead of the line
+	Lescape                //escape newlines in text logs as \n, so a log is always one line
 )
 
+//LevelName are the names of the standard levels, RegisterLevel renames them.
 var LevelName [6]string = [6]string{"Trace", "Debug", "Info", "Warn", "Error", "Fatal"}
 
-const TimeFormat = "2006/01/02 15:04:05"
+//EnvLevel is the environment variable the level of the default logger is read from
+//at init, e.g. LOG_LEVEL=debug, SetLevel overrides it.
+const EnvLevel = "LOG_LEVEL"
 
-const maxBufPoolSize = 16
+//levelTable holds the levels registered by RegisterLevel, it is never modified
+//but replaced, so formatting reads it without a lock.
+type levelTable struct {
+	names  map[int]string
+	values map[string]int
+}
+
+var (
+	levelsMu sync.Mutex
+	levels   atomic.Value
+)
+
+//RegisterLevel names the level value name, e.g. RegisterLevel(LevelWarn, "WARNING")
+//to match an existing log schema, or a custom level, e.g. RegisterLevel(LevelFatal+1, "Audit"),
+//logged with Output. Formatters render a level with its registered name instead of
+//LevelName, ParseLevel and LevelFromEnv accept both names.
+//
+//Levels are ordered by value, the standard levels are consecutive, so a custom
+//level is either below LevelTrace or above LevelFatal.
+func RegisterLevel(value int, name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("empty name of level %d", value)
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+
+	t := &levelTable{names: make(map[int]string), values: make(map[string]int)}
+	if old, _ := levels.Load().(*levelTable); old != nil {
+		for k, v := range old.names {
+			t.names[k] = v
+		}
+		for k, v := range old.values {
+			t.values[k] = v
+		}
+	}
+
+	t.names[value] = name
+	t.values[strings.ToLower(name)] = value
+	levels.Store(t)
+	return nil
+}
+
+//levelName returns the name of level, registered by RegisterLevel or in LevelName.
+func levelName(level int) string {
+	if t, _ := levels.Load().(*levelTable); t != nil {
+		if n, ok := t.names[level]; ok {
+			return n
+		}
+	}
+
+	if level >= 0 && level < len(LevelName) {
+		return LevelName[level]
+	}
+	return "Level" + strconv.Itoa(level)
+}
+
+//ParseLevel returns the level named name, e.g. "debug" or "WARN", case is ignored,
+//names registered by RegisterLevel are accepted too.
+func ParseLevel(name string) (int, error) {
+	if t, _ := levels.Load().(*levelTable); t != nil {
+		if level, ok := t.values[strings.ToLower(name)]; ok {
+			return level, nil
+		}
+	}
+
+	for level, n := range LevelName {
+		if strings.EqualFold(n, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %q", name)
+}
+
+//LevelFromEnv returns the level named by the environment variable varName, see ParseLevel.
+//It fails if the variable is not set.
+func LevelFromEnv(varName string) (int, error) {
+	name, ok := os.LookupEnv(varName)
+	if !ok {
+		return 0, fmt.Errorf("%s is not set", varName)
+	}
+	return ParseLevel(strings.TrimSpace(name))
+}
+
+//time layouts for SetTimeFormat, the fractional ones suit high frequency logs,
+//they are independent of the rotation interval of file handlers.
+const (
+	TimeFormat      = "2006/01/02 15:04:05"
+	TimeFormatMilli = "2006/01/02 15:04:05.000"
+	TimeFormatMicro = "2006/01/02 15:04:05.000000"
+)
+
+//time format presets for ingestion, used like the layouts above, see SetTimeFormatPreset.
+//The epoch ones are not layouts, the formatters render the time as an integer
+//since the unix epoch for them, a number with json.
+const (
+	TimeFormatISO8601     = "2006-01-02T15:04:05.000Z07:00"
+	TimeFormatRFC3339Nano = time.RFC3339Nano
+	TimeFormatEpochMillis = "epoch_millis"
+	TimeFormatEpochNanos  = "epoch_nanos"
+)
+
+//buffers larger than maxPoolBufSize are not pooled, so a huge log does not pin memory
+const maxPoolBufSize = 64 << 10
+
+//bufPool pools the buffers logs are formatted into, a buffer is reused after
+//the handler Write returns, so handlers must not retain p after Write.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
 
 type atomicInt32 int32
 
@@ -42,42 +167,352 @@ func (i *atomicInt32) Get() int {
 	return int(atomic.LoadInt32((*int32)(i)))
 }
 
-type Logger struct {
-	level atomicInt32
-	flag  int
+//message is a log queued to the sink, if done is not nil,
+//the handler is synced, or flushed if flush is set, after the log is written
+//and the result sent to done. If only flush is set, the handler is flushed.
+//If swap is not nil, the handler is replaced by handler and the old one sent to swap.
+type message struct {
+	//rec is the log, without the line, which is in buf
+	rec   Record
+	buf   *[]byte
+	done  chan error
+	flush bool
+
+	handler Handler
+	swap    chan Handler
+}
+
+//sink writes logs to the handler in its own goroutine,
+//it is shared by a Logger and all loggers derived from it.
+type sink struct {
+	//count of disk full writes, first to be 64-bit aligned for atomic
+	diskFull uint64
 
 	hMutex  sync.Mutex
 	handler Handler
 
-	quit chan struct{}
-	msg  chan []byte
+	//retry of writes failed as the disk is full, see SetDiskFullRetry
+	retries int
+	backoff time.Duration
+
+	metrics Metrics
+	//recover panics of the handler, see SetRecoverPanics
+	recoverPanics bool
 
-	bufMutex sync.Mutex
-	bufs     [][]byte
+	//banner of Logger.Banner, its start is written before the next log
+	//if bannerPending is 1, its stop on close
+	bannerPending int32
+	bannerMu      sync.Mutex
+	banner        *banner
 
-	wg sync.WaitGroup
+	quit chan struct{}
+	exit chan struct{}
+	msg  chan message
 
 	closed atomicInt32
 }
 
+func newSink(handler Handler) *sink {
+	s := new(sink)
+
+	s.handler = handler
+	s.metrics = nopMetrics{}
+	s.recoverPanics = true
+
+	s.quit = make(chan struct{})
+	s.exit = make(chan struct{})
+	s.closed.Set(0)
+
+	s.msg = make(chan message, 1024)
+
+	go s.run()
+
+	return s
+}
+
+func (s *sink) run() {
+	defer close(s.exit)
+	for {
+		select {
+		case msg := <-s.msg:
+			if msg.swap != nil {
+				s.hMutex.Lock()
+				old := s.handler
+				s.handler = msg.handler
+				s.hMutex.Unlock()
+
+				msg.swap <- old
+				continue
+			}
+
+			s.hMutex.Lock()
+			if msg.buf != nil {
+				s.writeBuf(&msg.rec, *msg.buf)
+			}
+			if msg.done != nil || msg.flush {
+				start := time.Now()
+				err := s.flushHandler(msg.flush)
+				s.metrics.ObserveFlush(time.Since(start))
+
+				if msg.done != nil {
+					msg.done <- err
+				}
+			}
+			s.hMutex.Unlock()
+
+			if msg.buf != nil {
+				s.putBuf(msg.buf)
+			}
+		case <-s.quit:
+			//we must log all msg
+			if len(s.msg) == 0 {
+				return
+			}
+		}
+	}
+}
+
+//diskFullFallback is where a log is written if the disk stays full, tests replace it.
+var diskFullFallback io.Writer = os.Stderr
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+//formatError counts a log whose formatter panicked, see FormatErrorMetrics.
+func (s *sink) formatError() {
+	s.hMutex.Lock()
+	m := s.metrics
+	s.hMutex.Unlock()
+
+	if f, ok := m.(FormatErrorMetrics); ok {
+		f.IncFormatError()
+	}
+}
+
+//handlerPanicFallback is where a log is written if the handler panics, tests replace it.
+var handlerPanicFallback io.Writer = os.Stderr
+
+//recoverHandler recovers a panic of the handler if SetRecoverPanics is on, it must be
+//deferred. The panic and p, the log being written if any, are written to
+//handlerPanicFallback, and the error is set to err if not nil. s.hMutex must be held.
+func (s *sink) recoverHandler(p []byte, err *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+
+	fmt.Fprintf(handlerPanicFallback, "log: handler panicked: %v\n", e)
+	if p != nil {
+		handlerPanicFallback.Write(p)
+		s.metrics.IncDropped()
+	}
+	if m, ok := s.metrics.(HandlerPanicMetrics); ok {
+		m.IncHandlerPanic()
+	}
+
+	if err != nil {
+		*err = fmt.Errorf("log: handler panicked: %v", e)
+	}
+}
+
+//backgroundPanic reports e, a panic recovered in the background goroutine of a handler,
+//e.g. AsyncHandler writing queued logs, to handlerPanicFallback and m, so it goes on.
+func backgroundPanic(e interface{}, m Metrics) {
+	fmt.Fprintf(handlerPanicFallback, "log: handler panicked: %v\n", e)
+	if pm, ok := m.(HandlerPanicMetrics); ok {
+		pm.IncHandlerPanic()
+	}
+}
+
+//flushHandler flushes the handler if flush is set, or syncs it, s.hMutex must be held.
+func (s *sink) flushHandler(flush bool) (err error) {
+	if s.recoverPanics {
+		defer s.recoverHandler(nil, &err)
+	}
+
+	if flush {
+		return flushHandler(s.handler)
+	}
+	return syncHandler(s.handler)
+}
+
+//writeBuf writes p to the handler and updates the metrics, s.hMutex must be held.
+func (s *sink) writeBuf(r *Record, p []byte) {
+	if s.recoverPanics {
+		defer s.recoverHandler(p, nil)
+	}
+
+	if err := s.writeRetry(r, p); err != nil {
+		s.metrics.IncDropped()
+		return
+	}
+
+	s.metrics.IncLevel(r.Level)
+	s.metrics.AddBytes(len(p))
+}
+
+//writeRecord writes r with its line p to the handler, with HandleRecord
+//if the handler implements RecordHandler, s.hMutex must be held.
+func (s *sink) writeRecord(r *Record, p []byte) error {
+	if rh, ok := s.handler.(RecordHandler); ok {
+		//copied here, so logs to other handlers are not moved to the heap
+		rec := *r
+		rec.Line = p
+		return rh.HandleRecord(&rec)
+	}
+	_, err := writeTime(s.handler, r.Level, r.Time, p)
+	return err
+}
+
+//writeRetry writes p to the handler, retrying if the disk is full, s.hMutex must be held.
+func (s *sink) writeRetry(r *Record, p []byte) error {
+	err := s.writeRecord(r, p)
+	if err == nil || !isDiskFull(err) {
+		return err
+	}
+
+	atomic.AddUint64(&s.diskFull, 1)
+	if s.retries <= 0 {
+		return err
+	}
+
+	backoff := s.backoff
+	for i := 0; i < s.retries; i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+
+		if err = s.writeRecord(r, p); err == nil || !isDiskFull(err) {
+			return err
+		}
+	}
+
+	diskFullFallback.Write(p)
+	return err
+}
+
+//write queues the log r formatted in buf, if sync is true, it waits until buf is written
+//and the handler is synced. If flush is true, the handler is flushed after buf is written.
+func (s *sink) write(r Record, buf *[]byte, sync bool, flush bool) error {
+	msg := message{rec: r, buf: buf, flush: flush && !sync}
+	if !sync {
+		s.msg <- msg
+		return nil
+	}
+
+	msg.done = make(chan error, 1)
+	s.msg <- msg
+
+	select {
+	case err := <-msg.done:
+		return err
+	case <-s.exit:
+		return nil
+	}
+}
+
+//flush waits until queued logs are written, then flushes the handler.
+func (s *sink) flush() error {
+	msg := message{done: make(chan error, 1), flush: true}
+	s.msg <- msg
+
+	select {
+	case err := <-msg.done:
+		return err
+	case <-s.exit:
+		return nil
+	}
+}
+
+//swap replaces the handler after the queued logs are written to the old one,
+//and returns the old one, or nil if the sink is closed.
+func (s *sink) swap(h Handler) Handler {
+	msg := message{handler: h, swap: make(chan Handler, 1)}
+	s.msg <- msg
+
+	select {
+	case old := <-msg.swap:
+		return old
+	case <-s.exit:
+		select {
+		case old := <-msg.swap:
+			return old
+		default:
+			return nil
+		}
+	}
+}
+
+//popBuf returns an empty pooled buffer, the pointer is pooled too so putBuf does not allocate.
+func (s *sink) popBuf() *[]byte {
+	buf := bufPool.Get().(*[]byte)
+	*buf = (*buf)[0:0]
+	return buf
+}
+
+func (s *sink) putBuf(buf *[]byte) {
+	if cap(*buf) > maxPoolBufSize {
+		return
+	}
+	bufPool.Put(buf)
+}
+
+func (s *sink) close() {
+	if s.closed.Get() == 1 {
+		return
+	}
+	s.closed.Set(1)
+
+	close(s.quit)
+
+	<-s.exit
+
+	s.handler.Close()
+}
+
+type Logger struct {
+	level *atomicInt32
+	boost *levelBoost
+	flag  int
+
+	mu         sync.RWMutex
+	timeFormat string
+	utc        bool
+	flushLevel int
+	prefix     string
+	maxLine    int
+	terminator string
+	formatter  Formatter
+	fields     map[string]interface{}
+	//static are set by SetStaticFields, all is static with fields over them
+	static map[string]interface{}
+	all    map[string]interface{}
+	ctxKey interface{}
+
+	includeSeq  bool
+	includeGoid bool
+	seq         *uint64
+	stackLevel  int
+
+	s *sink
+}
+
 //new a logger with specified handler and flag
 func New(handler Handler, flag int) *Logger {
 	var l = new(Logger)
 
+	l.level = new(atomicInt32)
 	l.level.Set(LevelInfo)
-	l.handler = handler
+	l.boost = new(levelBoost)
 
 	l.flag = flag
+	l.timeFormat = TimeFormat
+	l.terminator = "\n"
+	l.seq = new(uint64)
+	l.stackLevel = math.MaxInt32
+	l.flushLevel = math.MaxInt32
 
-	l.quit = make(chan struct{})
-	l.closed.Set(0)
-
-	l.msg = make(chan []byte, 1024)
-
-	l.bufs = make([][]byte, 0, 16)
-
-	l.wg.Add(1)
-	go l.run()
+	l.s = newSink(handler)
 
 	return l
 }
@@ -87,6 +522,19 @@ func NewDefault(handler Handler) *Logger {
 	return New(handler, Ltime|Lfile|Llevel)
 }
 
+//NewStdLogger creates a default logger writing logs with level >= errLevel
+//to stderr and others to stdout.
+func NewStdLogger(errLevel int) *Logger {
+	out, _ := NewStreamHandler(os.Stdout)
+	err, _ := NewStreamHandler(os.Stderr)
+
+	h, _ := NewLevelRouterHandler()
+	h.AddRangeRoute(LevelTrace, errLevel-1, out)
+	h.AddRoute(errLevel, err)
+
+	return NewDefault(h)
+}
+
 func newStdHandler() *StreamHandler {
 	h, _ := NewStreamHandler(os.Stdout)
 	return h
@@ -94,196 +542,890 @@ func newStdHandler() *StreamHandler {
 
 var std = NewDefault(newStdHandler())
 
-func (l *Logger) run() {
-	defer l.wg.Done()
-	for {
-		select {
-		case msg := <-l.msg:
-			l.hMutex.Lock()
-			l.handler.Write(msg)
-			l.hMutex.Unlock()
-			l.putBuf(msg)
-		case <-l.quit:
-			//we must log all msg
-			if len(l.msg) == 0 {
-				return
-			}
-		}
+//an unset or invalid EnvLevel keeps the default level
+func init() {
+	if level, err := LevelFromEnv(EnvLevel); err == nil {
+		std.SetLevel(level)
 	}
 }
 
-func (l *Logger) popBuf() []byte {
-	l.bufMutex.Lock()
-	var buf []byte
-	if len(l.bufs) == 0 {
-		buf = make([]byte, 0, 1024)
-	} else {
-		buf = l.bufs[len(l.bufs)-1]
-		l.bufs = l.bufs[0 : len(l.bufs)-1]
-	}
-	l.bufMutex.Unlock()
+//Close closes the logger and its handler after all logs are written,
+//loggers derived with WithFields are closed too.
+//The stop banner is written first, see Banner. It is unregistered, see RegisterForShutdown.
+func (l *Logger) Close() {
+	l.s.stopBanner()
+	l.s.close()
+	unregisterForShutdown(l.s)
+}
 
-	return buf
+//Sync waits until all queued logs are written, then commits them to
+//stable storage if the handler implements Syncer.
+func (l *Logger) Sync() error {
+	if l.s.closed.Get() == 1 {
+		return nil
+	}
+	return l.s.write(Record{}, nil, true, false)
 }
 
-func (l *Logger) putBuf(buf []byte) {
-	l.bufMutex.Lock()
-	if len(l.bufs) < maxBufPoolSize {
-		buf = buf[0:0]
-		l.bufs = append(l.bufs, buf)
+//Flush waits until all queued logs are written, then flushes the handler chain,
+//e.g. a BufferedHandler wrapped by an AsyncHandler, if it implements Flusher.
+//Unlike Sync, nothing is committed to stable storage, so it is cheap enough
+//to call at request boundaries.
+func (l *Logger) Flush() error {
+	if l.s.closed.Get() == 1 {
+		return nil
 	}
-	l.bufMutex.Unlock()
+	return l.s.flush()
 }
 
-func (l *Logger) Close() {
-	if l.closed.Get() == 1 {
-		return
+//HealthCheck checks the handler chain can write logs, e.g. for a readiness probe,
+//by calling Check of the handlers implementing HealthChecker, so a file not
+//writable any more or a dropped connection is found before logs are lost.
+//It does not wait for queued logs. ErrHandlerClosed is returned after Close.
+func (l *Logger) HealthCheck() error {
+	if l.s.closed.Get() == 1 {
+		return ErrHandlerClosed
 	}
-	l.closed.Set(1)
 
-	close(l.quit)
+	l.s.hMutex.Lock()
+	defer l.s.hMutex.Unlock()
 
-	l.wg.Wait()
+	return checkHandler(l.s.handler)
+}
+
+//SetDiskFullRetry makes a write failed with ENOSPC be retried up to retries times,
+//waiting backoff before the first retry and doubling it after each one, then the log
+//is written to stderr so it is not lost. The retries block the logger, and a log
+//partly written before the disk filled up may be repeated.
+//A retries <= 0, the default, disables it. It is shared by derived loggers.
+func (l *Logger) SetDiskFullRetry(retries int, backoff time.Duration) {
+	l.s.hMutex.Lock()
+	l.s.retries = retries
+	l.s.backoff = backoff
+	l.s.hMutex.Unlock()
+}
+
+//SetRecoverPanics sets whether a panic of the handler, e.g. a bug in a custom handler,
+//is recovered, so a log call never takes the process down. The log is written to
+//stderr with the panic instead, and counted, see HandlerPanicMetrics. It is on by
+//default, tests may turn it off so panics surface. It is shared by derived loggers.
+func (l *Logger) SetRecoverPanics(recover bool) {
+	l.s.hMutex.Lock()
+	l.s.recoverPanics = recover
+	l.s.hMutex.Unlock()
+}
+
+//SetMetrics makes the logger report its logs to m, see Metrics,
+//it is shared by derived loggers. A nil m disables it, which is the default.
+func (l *Logger) SetMetrics(m Metrics) {
+	if m == nil {
+		m = nopMetrics{}
+	}
 
-	l.quit = nil
+	l.s.hMutex.Lock()
+	l.s.metrics = m
+	l.s.hMutex.Unlock()
+}
 
-	l.handler.Close()
+//DiskFullCount returns how many logs failed to be written as the disk was full,
+//whether the retry succeeded or not.
+func (l *Logger) DiskFullCount() uint64 {
+	return atomic.LoadUint64(&l.s.diskFull)
 }
 
-//set log level, any log level less than it will not log
+//set log level, any log level less than it will not log,
+//it ends a boost by BoostLevel.
 func (l *Logger) SetLevel(level int) {
+	l.boost.mu.Lock()
+	l.boost.stop()
 	l.level.Set(level)
+	l.boost.mu.Unlock()
+}
+
+//levelBoost is the state of BoostLevel, shared by the loggers sharing the level.
+type levelBoost struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	restore int
+}
+
+//stop ends the boost without restoring the level, mu must be held.
+func (b *levelBoost) stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+//BoostLevel sets the level to level for d, then restores the level set before,
+//e.g. l.BoostLevel(LevelDebug, 5*time.Minute) while debugging an incident.
+//
+//A boost during another one replaces its level and duration, and the level
+//before the first boost is restored. SetLevel ends a boost.
+func (l *Logger) BoostLevel(level int, d time.Duration) {
+	b := l.boost
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer == nil {
+		b.restore = l.level.Get()
+	} else {
+		b.stop()
+	}
+	l.level.Set(level)
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		//t was replaced by another boost or stopped
+		if b.timer != t {
+			return
+		}
+		b.timer = nil
+		l.level.Set(b.restore)
+	})
+	b.timer = t
+}
+
+//SetFlags sets the output flags, e.g. Lfile can be turned off in hot paths
+//because getting the caller with runtime.Caller is not cheap.
+func (l *Logger) SetFlags(flag int) {
+	l.mu.Lock()
+	l.flag = flag
+	l.mu.Unlock()
+}
+
+//Flags returns the output flags.
+func (l *Logger) Flags() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.flag
+}
+
+//SetTimeFormat sets the time layout used with Ltime, default is TimeFormat.
+//The default is kept for compatibility with existing log parsers, set
+//time.RFC3339 for RFC3339 timestamps. An empty layout disables the time,
+//e.g. journald adds its own.
+func (l *Logger) SetTimeFormat(layout string) {
+	l.mu.Lock()
+	l.timeFormat = layout
+	l.mu.Unlock()
+}
+
+//SetTimeFormatPreset sets the time format to one of the named presets, like SetTimeFormat,
+//e.g. TimeFormatISO8601 or TimeFormatEpochMillis. An unknown preset returns an error,
+//so a typo is found at startup instead of in the logs.
+func (l *Logger) SetTimeFormatPreset(preset string) error {
+	switch preset {
+	case TimeFormat, TimeFormatMilli, TimeFormatMicro,
+		TimeFormatISO8601, TimeFormatRFC3339Nano, TimeFormatEpochMillis, TimeFormatEpochNanos:
+	default:
+		return fmt.Errorf("unknown time format preset %q", preset)
+	}
+
+	l.SetTimeFormat(preset)
+	return nil
+}
+
+//SetPrefix sets a tag prepended to every log, e.g. "[billing] ", at the start
+//of the line, or before the message with Lmsgprefix. With json the prefix,
+//trimmed of spaces, is the field "component" instead.
+func (l *Logger) SetPrefix(prefix string) {
+	l.mu.Lock()
+	l.prefix = prefix
+	l.mu.Unlock()
+}
+
+//SetStaticFields sets fields added to every log, like WithFields, e.g. host, app
+//and version set once at startup, replacing the static fields set before.
+//A field set by WithFields wins over a static one with the same key.
+//Loggers derived from l afterwards, e.g. by WithFields, have them too.
+func (l *Logger) SetStaticFields(fields map[string]interface{}) {
+	m := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		m[k] = v
+	}
+
+	l.mu.Lock()
+	l.static = m
+	l.all = mergeFields(m, l.fields)
+	l.mu.Unlock()
+}
+
+//mergeFields returns fields over static, static is not copied if there are no fields.
+func mergeFields(static map[string]interface{}, fields map[string]interface{}) map[string]interface{} {
+	if len(static) == 0 {
+		return fields
+	}
+	if len(fields) == 0 {
+		return static
+	}
+
+	m := make(map[string]interface{}, len(static)+len(fields))
+	for k, v := range static {
+		m[k] = v
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	return m
+}
+
+//SetMaxLineBytes truncates logs longer than n bytes to n bytes followed by
+//"…(truncated)", so a huge payload can not blow up the log storage.
+//With json msg and every string field are truncated instead, keeping the object valid.
+//0, the default, disables it.
+func (l *Logger) SetMaxLineBytes(n int) {
+	l.mu.Lock()
+	l.maxLine = n
+	l.mu.Unlock()
+}
+
+//SetFlushLevel makes the handler chain be flushed, see Flush, after a log with
+//level >= level is written, e.g. LevelError, so errors are visible at once while
+//lower levels stay buffered. It is disabled by default.
+func (l *Logger) SetFlushLevel(level int) {
+	l.mu.Lock()
+	l.flushLevel = level
+	l.mu.Unlock()
+}
+
+//SetUTC makes log times be in UTC instead of local time, which is the default.
+func (l *Logger) SetUTC(utc bool) {
+	l.mu.Lock()
+	l.utc = utc
+	l.mu.Unlock()
+}
+
+//SetTerminator sets the terminator of logs, default is "\n",
+//e.g. "\r\n" or "\x00" for systems framing logs so.
+//It replaces the trailing newline of the formatter output.
+func (l *Logger) SetTerminator(s string) {
+	l.mu.Lock()
+	l.terminator = s
+	l.mu.Unlock()
+}
+
+//SetIncludeSeq makes every log start with "seq=N ", N is a sequence number
+//starting from 1 and shared by derived loggers, so dropped or reordered logs
+//can be detected. With json, seq is added as a key instead.
+func (l *Logger) SetIncludeSeq(include bool) {
+	l.mu.Lock()
+	l.includeSeq = include
+	l.mu.Unlock()
 }
 
+//SetIncludeGoroutineID makes every log include "goid=N ", the id of the
+//logging goroutine, after the seq if included. It is parsed from the stack,
+//so it is not cheap. With json, goid is added as a key instead.
+func (l *Logger) SetIncludeGoroutineID(include bool) {
+	l.mu.Lock()
+	l.includeGoid = include
+	l.mu.Unlock()
+}
+
+//SetStackLevel makes logs with level >= level carry the stack of the caller
+//as the field "stack", a line per frame in text and an array with json.
+//Stacks are not captured by default.
+func (l *Logger) SetStackLevel(level int) {
+	l.mu.Lock()
+	l.stackLevel = level
+	l.mu.Unlock()
+}
+
+//SetFormatter sets the formatter of logs, then the logger flags and time format
+//are not used, except Lfile which is only supported by the built-in formatters.
+//If f panics, e.g. on a bad field type, "[logformat error] msg" is written instead.
+//A nil formatter restores the text or json format selected by the flags.
+func (l *Logger) SetFormatter(f Formatter) {
+	l.mu.Lock()
+	l.formatter = f
+	l.mu.Unlock()
+}
+
+//SetHandler replaces the handler and closes the old one, logs queued before
+//the call are written to the old handler, so it is never written after closed.
 func (l *Logger) SetHandler(h Handler) {
-	if l.closed.Get() == 1 {
-		return
+	if old := l.SwapHandler(h); old != nil {
+		old.Close()
 	}
+}
 
-	l.hMutex.Lock()
-	if l.handler != nil {
-		l.handler.Close()
+//SwapHandler is like SetHandler, but returns the old handler instead of closing it,
+//e.g. to keep using stdout elsewhere after switching to a file.
+//It returns nil if the logger is closed.
+func (l *Logger) SwapHandler(h Handler) Handler {
+	if l.s.closed.Get() == 1 {
+		return nil
 	}
-	l.handler = h
-	l.hMutex.Unlock()
+	return l.s.swap(h)
 }
 
-func (l *Logger) Output(callDepth int, level int, s string) {
-	if l.closed.Get() == 1 {
-		// closed
+//derive returns a logger with the same configuration as l,
+//sharing the handler and level with l.
+func (l *Logger) derive() *Logger {
+	n := new(Logger)
+
+	l.mu.RLock()
+	n.level = l.level
+	n.boost = l.boost
+	n.flag = l.flag
+	n.timeFormat = l.timeFormat
+	n.utc = l.utc
+	n.flushLevel = l.flushLevel
+	n.prefix = l.prefix
+	n.maxLine = l.maxLine
+	n.terminator = l.terminator
+	n.formatter = l.formatter
+	n.fields = l.fields
+	n.static = l.static
+	n.all = l.all
+	n.ctxKey = l.ctxKey
+	n.includeSeq = l.includeSeq
+	n.includeGoid = l.includeGoid
+	n.stackLevel = l.stackLevel
+	l.mu.RUnlock()
+
+	n.seq = l.seq
+	n.s = l.s
+
+	return n
+}
+
+//Clone returns a logger with a copy of the configuration of l, including the level,
+//sharing only the handler, so setting its level, prefix or formatter does not
+//affect l. Closing either logger closes the handler for both.
+func (l *Logger) Clone() *Logger {
+	n := l.derive()
+
+	//fields are never modified in place, so they are shared
+	n.level = new(atomicInt32)
+	n.level.Set(l.level.Get())
+	n.boost = new(levelBoost)
+
+	return n
+}
+
+//WithFields returns a logger which adds fields to every log, the fields
+//are rendered as key=value after the message, or as keys with Ljson.
+//
+//The returned logger shares the handler and level with l.
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	n := l.derive()
+
+	m := make(map[string]interface{}, len(n.fields)+len(fields))
+	for k, v := range n.fields {
+		m[k] = v
+	}
+	for k, v := range fields {
+		m[k] = v
+	}
+	n.fields = m
+	n.all = mergeFields(n.static, m)
+
+	return n
+}
+
+//WithField returns a logger which adds the field key to every log, like WithFields,
+//so fields can be chained, e.g. l.WithField("a", 1).WithField("b", 2).
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.WithFields(map[string]interface{}{key: value})
+}
+
+//WithError returns a logger which adds err as the field "error" to every log,
+//JSONFormatter renders the chain of a wrapped error as an array.
+func (l *Logger) WithError(err error) *Logger {
+	return l.WithField("error", err)
+}
+
+//LogErr logs err with level and the field "error" if err is not nil, and returns err,
+//e.g. return l.LogErr(LevelError, doThing()). Nothing is logged for a nil err.
+func (l *Logger) LogErr(level int, err error) error {
+	l.logErr(3, level, err)
+	return err
+}
+
+func (l *Logger) logErr(callDepth int, level int, err error) {
+	if err == nil || !l.enabled(level) {
 		return
 	}
+	l.WithError(err).Output(callDepth, level, err.Error())
+}
 
+type logWriter struct {
+	l     *Logger
+	level int
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if w.l.enabled(w.level) {
+		s := string(p)
+		if len(s) > 0 && s[len(s)-1] == '\n' {
+			s = s[0 : len(s)-1]
+		}
+		w.l.Output(2, w.level, s)
+	}
+	return len(p), nil
+}
+
+//Writer returns an io.Writer logging everything written with level,
+//e.g. for http.Server.ErrorLog with the standard log package.
+func (l *Logger) Writer(level int) io.Writer {
+	return &logWriter{l, level}
+}
+
+//enabled reports whether a log with level will be logged,
+//so the log methods can skip formatting if not.
+func (l *Logger) enabled(level int) bool {
+	return l.level.Get() <= level
+}
+
+func (l *Logger) Output(callDepth int, level int, s string) {
 	if l.level.Get() > level {
 		// higher level can be logged
 		return
 	}
 
-	buf := l.popBuf()
+	if atomic.LoadInt32(&l.s.bannerPending) == 1 {
+		l.s.startBanner(callDepth + 1)
+	}
+
+	l.output(callDepth+1, level, s)
+}
+
+//output writes a log with level, whatever the level of the logger is.
+func (l *Logger) output(callDepth int, level int, s string) {
+	if l.s.closed.Get() == 1 {
+		// closed
+		return
+	}
+
+	p := l.s.popBuf()
+
+	t := time.Now()
 
-	if l.flag&Ltime > 0 {
-		now := time.Now().Format(TimeFormat)
-		buf = append(buf, '[')
-		buf = append(buf, now...)
-		buf = append(buf, "] "...)
+	l.mu.RLock()
+	flush := level >= l.flushLevel
+	if l.utc {
+		t = t.UTC()
+	}
+	buf, fields := l.format(*p, callDepth+1, level, t, s)
+	if l.maxLine > 0 && !l.isJSON() {
+		buf = truncateLine(buf, l.maxLine)
 	}
+	if l.terminator != "\n" && len(buf) > 0 && buf[len(buf)-1] == '\n' {
+		buf = append(buf[0:len(buf)-1], l.terminator...)
+	}
+	l.mu.RUnlock()
+
+	*p = buf
+
+	//make sure a fatal log is on disk before the process may exit
+	l.s.write(Record{Level: level, Time: t, Msg: s, Fields: fields}, p, level >= LevelFatal, flush)
+}
 
-	if l.flag&Lfile > 0 {
-		_, file, line, ok := runtime.Caller(callDepth)
-		if !ok {
-			file = "???"
-			line = 0
+//format appends the formatted log to buf, with the formatter set by SetFormatter,
+//or the text or json format selected by the logger flags, and returns the fields of the log.
+func (l *Logger) format(buf []byte, callDepth int, level int, t time.Time, msg string) ([]byte, map[string]interface{}) {
+	fields := l.all
+	if len(l.prefix) > 0 {
+		if l.isJSON() {
+			fields = withField(fields, "component", strings.TrimSpace(l.prefix))
+		} else if l.flag&Lmsgprefix > 0 {
+			msg = l.prefix + msg
 		} else {
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					file = file[i+1:]
-					break
+			buf = append(buf, l.prefix...)
+		}
+	}
+
+	if l.includeSeq || l.includeGoid {
+		buf, fields = l.appendSeq(buf, fields)
+	}
+
+	if l.maxLine > 0 && l.isJSON() {
+		msg, fields = l.truncateValues(msg, fields)
+	}
+
+	if level >= l.stackLevel {
+		fields = withField(fields, "stack", callerStack(callDepth+1))
+	}
+
+	if l.formatter == nil {
+		var file string
+		var line int
+		if l.flag&Lfile > 0 {
+			file, line = caller(callDepth)
+		}
+
+		//called directly, not by appendFormatter, so f does not escape to the heap
+		if l.flag&Ljson > 0 {
+			f := JSONFormatter{l.flag, l.timeFormat}
+			return f.appendFormat(buf, file, line, level, t, msg, fields), fields
+		}
+
+		f := TextFormatter{Flag: l.flag, TimeFormat: l.timeFormat}
+		return f.appendFormat(buf, file, line, level, t, msg, fields), fields
+	}
+
+	if f, ok := l.formatter.(appendFormatter); ok {
+		return appendWithCaller(f, buf, callDepth+1, level, t, msg, fields), fields
+	}
+
+	return l.customFormat(buf, level, t, msg, fields), fields
+}
+
+//customFormat appends the log formatted by the formatter set by SetFormatter.
+//A panic of the formatter is recovered and counted, see FormatErrorMetrics,
+//and the raw message is written instead, so the log is not lost.
+func (l *Logger) customFormat(buf []byte, level int, t time.Time, msg string, fields map[string]interface{}) (b []byte) {
+	defer func() {
+		if e := recover(); e != nil {
+			l.s.formatError()
+
+			b = append(buf, "[logformat error] "...)
+			b = append(b, strings.TrimSuffix(msg, "\n")...)
+			b = append(b, '\n')
+		}
+	}()
+
+	return append(buf, l.formatter.Format(level, t, msg, fields)...)
+}
+
+//truncatedMark ends a truncated log or value.
+const truncatedMark = "…(truncated)"
+
+//truncateLine truncates the log in buf to n bytes, not counting its newline,
+//without splitting a utf8 character.
+func truncateLine(buf []byte, n int) []byte {
+	line := bytes.TrimSuffix(buf, []byte("\n"))
+	if len(line) <= n {
+		return buf
+	}
+
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+
+	newline := len(line) < len(buf)
+	buf = append(buf[0:n], truncatedMark...)
+	if newline {
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+//truncateString truncates s to n bytes, without splitting a utf8 character.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[0:n] + truncatedMark
+}
+
+//truncateValues truncates msg and the string fields to maxLine bytes,
+//fields are copied only if one is truncated.
+func (l *Logger) truncateValues(msg string, fields map[string]interface{}) (string, map[string]interface{}) {
+	msg = truncateString(msg, l.maxLine)
+
+	var m map[string]interface{}
+	for k, v := range fields {
+		if s, ok := v.(string); ok && len(s) > l.maxLine {
+			if m == nil {
+				m = make(map[string]interface{}, len(fields))
+				for k, v := range fields {
+					m[k] = v
 				}
 			}
+			m[k] = truncateString(s, l.maxLine)
 		}
+	}
+
+	if m == nil {
+		return msg, fields
+	}
+	return msg, m
+}
+
+//isJSON returns whether logs are formatted as json objects.
+func (l *Logger) isJSON() bool {
+	_, ok := l.formatter.(*JSONFormatter)
+	return ok || l.formatter == nil && l.flag&Ljson > 0
+}
+
+//withField returns a copy of fields with key set to value.
+func withField(fields map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		m[k] = v
+	}
+	m[key] = value
+	return m
+}
+
+//appendSeq prefixes buf with the seq and goroutine id, or adds them to the fields
+//returned for json, where a prefix would break the object.
+func (l *Logger) appendSeq(buf []byte, fields map[string]interface{}) ([]byte, map[string]interface{}) {
+	var seq uint64
+	if l.includeSeq {
+		seq = atomic.AddUint64(l.seq, 1)
+	}
 
-		buf = append(buf, file...)
-		buf = append(buf, ':')
+	var goid int64
+	if l.includeGoid {
+		goid = goroutineID()
+	}
+
+	if l.isJSON() {
+		m := make(map[string]interface{}, len(fields)+2)
+		for k, v := range fields {
+			m[k] = v
+		}
+		if l.includeSeq {
+			m["seq"] = seq
+		}
+		if l.includeGoid {
+			m["goid"] = goid
+		}
+		return buf, m
+	}
 
-		buf = strconv.AppendInt(buf, int64(line), 10)
+	if l.includeSeq {
+		buf = append(buf, "seq="...)
+		buf = strconv.AppendUint(buf, seq, 10)
+		buf = append(buf, ' ')
+	}
+	if l.includeGoid {
+		buf = append(buf, "goid="...)
+		buf = strconv.AppendInt(buf, goid, 10)
 		buf = append(buf, ' ')
 	}
+	return buf, fields
+}
 
-	if l.flag&Llevel > 0 {
-		buf = append(buf, '[')
-		buf = append(buf, LevelName[level]...)
-		buf = append(buf, "] "...)
+//goroutineID parses the id of the current goroutine from "goroutine N [running]:".
+func goroutineID() int64 {
+	var b [64]byte
+	s := b[0:runtime.Stack(b[:], false)]
+	s = bytes.TrimPrefix(s, []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i > 0 {
+		s = s[0:i]
 	}
 
-	buf = append(buf, s...)
+	id, _ := strconv.ParseInt(string(s), 10, 64)
+	return id
+}
 
-	if s[len(s)-1] != '\n' {
-		buf = append(buf, '\n')
+func appendWithCaller(f appendFormatter, buf []byte, callDepth int, level int, t time.Time, msg string, fields map[string]interface{}) []byte {
+	var file string
+	var line int
+	if f.flags()&Lfile > 0 {
+		file, line = caller(callDepth)
 	}
 
-	l.msg <- buf
+	return f.appendFormat(buf, file, line, level, t, msg, fields)
+}
+
+//caller returns the file base name and line of the caller at callDepth.
+func caller(callDepth int) (string, int) {
+	_, file, line, ok := runtime.Caller(callDepth + 1)
+	if !ok {
+		file = "???"
+		line = 0
+	} else {
+		for i := len(file) - 1; i > 0; i-- {
+			if file[i] == '/' {
+				file = file[i+1:]
+				break
+			}
+		}
+	}
+	return file, line
+}
+
+//stack is the frames of a stack, "function file:line" each,
+//printed a frame per line in text, and encoded as an array in json.
+type stack []string
+
+func (s stack) String() string {
+	var b strings.Builder
+	for _, f := range s {
+		b.WriteString("\n\t")
+		b.WriteString(f)
+	}
+	return b.String()
+}
+
+//callerStack returns the stack from the caller at callDepth, without runtime frames.
+func callerStack(callDepth int) stack {
+	pcs := make([]uintptr, 32)
+	pcs = pcs[0:runtime.Callers(callDepth+1, pcs)]
+
+	var s stack
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, "runtime.") {
+			break
+		}
+		s = append(s, f.Function+" "+f.File+":"+strconv.Itoa(f.Line))
+		if !more {
+			break
+		}
+	}
+	return s
 }
 
 //log with Trace level
 func (l *Logger) Trace(v ...interface{}) {
+	if !l.enabled(LevelTrace) {
+		return
+	}
 	l.Output(2, LevelTrace, fmt.Sprint(v...))
 }
 
 //log with Debug level
 func (l *Logger) Debug(v ...interface{}) {
+	if !l.enabled(LevelDebug) {
+		return
+	}
 	l.Output(2, LevelDebug, fmt.Sprint(v...))
 }
 
 //log with info level
 func (l *Logger) Info(v ...interface{}) {
+	if !l.enabled(LevelInfo) {
+		return
+	}
 	l.Output(2, LevelInfo, fmt.Sprint(v...))
 }
 
 //log with warn level
 func (l *Logger) Warn(v ...interface{}) {
+	if !l.enabled(LevelWarn) {
+		return
+	}
 	l.Output(2, LevelWarn, fmt.Sprint(v...))
 }
 
 //log with error level
 func (l *Logger) Error(v ...interface{}) {
+	if !l.enabled(LevelError) {
+		return
+	}
 	l.Output(2, LevelError, fmt.Sprint(v...))
 }
 
-//log with fatal level
+//log with fatal level, the handler is flushed and synced before Fatal returns,
+//it does not exit the process.
 func (l *Logger) Fatal(v ...interface{}) {
+	if !l.enabled(LevelFatal) {
+		return
+	}
 	l.Output(2, LevelFatal, fmt.Sprint(v...))
 }
 
+//TraceMsg logs msg with trace level, see InfoMsg.
+func (l *Logger) TraceMsg(msg string) {
+	l.Output(2, LevelTrace, msg)
+}
+
+//DebugMsg logs msg with debug level, see InfoMsg.
+func (l *Logger) DebugMsg(msg string) {
+	l.Output(2, LevelDebug, msg)
+}
+
+//InfoMsg logs msg with info level as is. Unlike Info, msg is not boxed
+//into an interface{} and formatted with fmt, so logging a string with
+//the text or json format and no fields allocates nothing, and a disabled
+//level costs a few ns. Prefer it to Info and Infof in hot paths.
+func (l *Logger) InfoMsg(msg string) {
+	l.Output(2, LevelInfo, msg)
+}
+
+//WarnMsg logs msg with warn level, see InfoMsg.
+func (l *Logger) WarnMsg(msg string) {
+	l.Output(2, LevelWarn, msg)
+}
+
+//ErrorMsg logs msg with error level, see InfoMsg.
+func (l *Logger) ErrorMsg(msg string) {
+	l.Output(2, LevelError, msg)
+}
+
+//FatalMsg logs msg with fatal level like Fatal, see InfoMsg.
+func (l *Logger) FatalMsg(msg string) {
+	l.Output(2, LevelFatal, msg)
+}
+
 //log with Trace level
 func (l *Logger) Tracef(format string, v ...interface{}) {
+	if !l.enabled(LevelTrace) {
+		return
+	}
 	l.Output(2, LevelTrace, fmt.Sprintf(format, v...))
 }
 
 //log with Debug level
 func (l *Logger) Debugf(format string, v ...interface{}) {
+	if !l.enabled(LevelDebug) {
+		return
+	}
 	l.Output(2, LevelDebug, fmt.Sprintf(format, v...))
 }
 
 //log with info level
 func (l *Logger) Infof(format string, v ...interface{}) {
+	if !l.enabled(LevelInfo) {
+		return
+	}
 	l.Output(2, LevelInfo, fmt.Sprintf(format, v...))
 }
 
 //log with warn level
 func (l *Logger) Warnf(format string, v ...interface{}) {
+	if !l.enabled(LevelWarn) {
+		return
+	}
 	l.Output(2, LevelWarn, fmt.Sprintf(format, v...))
 }
 
 //log with error level
 func (l *Logger) Errorf(format string, v ...interface{}) {
+	if !l.enabled(LevelError) {
+		return
+	}
 	l.Output(2, LevelError, fmt.Sprintf(format, v...))
 }
 
 //log with fatal level
 func (l *Logger) Fatalf(format string, v ...interface{}) {
+	if !l.enabled(LevelFatal) {
+		return
+	}
 	l.Output(2, LevelFatal, fmt.Sprintf(format, v...))
 }
 
+//Panic logs with fatal level like Fatal, then panics with the message.
+func (l *Logger) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	l.Output(2, LevelFatal, s)
+	panic(s)
+}
+
+//Panicf is Panic with a format.
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	l.Output(2, LevelFatal, s)
+	panic(s)
+}
+
 func SetLevel(level int) {
 	std.SetLevel(level)
 }
@@ -292,50 +1434,155 @@ func SetHandler(h Handler) {
 	std.SetHandler(h)
 }
 
+func SetFlags(flag int) {
+	std.SetFlags(flag)
+}
+
+func SetTimeFormat(layout string) {
+	std.SetTimeFormat(layout)
+}
+
+func SetTimeFormatPreset(preset string) error {
+	return std.SetTimeFormatPreset(preset)
+}
+
+func SetPrefix(prefix string) {
+	std.SetPrefix(prefix)
+}
+
+func SetMaxLineBytes(n int) {
+	std.SetMaxLineBytes(n)
+}
+
+func SetUTC(utc bool) {
+	std.SetUTC(utc)
+}
+
+func SetTerminator(s string) {
+	std.SetTerminator(s)
+}
+
+func SetFormatter(f Formatter) {
+	std.SetFormatter(f)
+}
+
+func SetStackLevel(level int) {
+	std.SetStackLevel(level)
+}
+
+func SetStaticFields(fields map[string]interface{}) {
+	std.SetStaticFields(fields)
+}
+
+func WithFields(fields map[string]interface{}) *Logger {
+	return std.WithFields(fields)
+}
+
+func WithField(key string, value interface{}) *Logger {
+	return std.WithField(key, value)
+}
+
+func WithError(err error) *Logger {
+	return std.WithError(err)
+}
+
+func LogErr(level int, err error) error {
+	std.logErr(3, level, err)
+	return err
+}
+
 func Trace(v ...interface{}) {
+	if !std.enabled(LevelTrace) {
+		return
+	}
 	std.Output(2, LevelTrace, fmt.Sprint(v...))
 }
 
 func Debug(v ...interface{}) {
+	if !std.enabled(LevelDebug) {
+		return
+	}
 	std.Output(2, LevelDebug, fmt.Sprint(v...))
 }
 
 func Info(v ...interface{}) {
+	if !std.enabled(LevelInfo) {
+		return
+	}
 	std.Output(2, LevelInfo, fmt.Sprint(v...))
 }
 
 func Warn(v ...interface{}) {
+	if !std.enabled(LevelWarn) {
+		return
+	}
 	std.Output(2, LevelWarn, fmt.Sprint(v...))
 }
 
 func Error(v ...interface{}) {
+	if !std.enabled(LevelError) {
+		return
+	}
 	std.Output(2, LevelError, fmt.Sprint(v...))
 }
 
 func Fatal(v ...interface{}) {
+	if !std.enabled(LevelFatal) {
+		return
+	}
 	std.Output(2, LevelFatal, fmt.Sprint(v...))
 }
 
 func Tracef(format string, v ...interface{}) {
+	if !std.enabled(LevelTrace) {
+		return
+	}
 	std.Output(2, LevelTrace, fmt.Sprintf(format, v...))
 }
 
 func Debugf(format string, v ...interface{}) {
+	if !std.enabled(LevelDebug) {
+		return
+	}
 	std.Output(2, LevelDebug, fmt.Sprintf(format, v...))
 }
 
 func Infof(format string, v ...interface{}) {
+	if !std.enabled(LevelInfo) {
+		return
+	}
 	std.Output(2, LevelInfo, fmt.Sprintf(format, v...))
 }
 
 func Warnf(format string, v ...interface{}) {
+	if !std.enabled(LevelWarn) {
+		return
+	}
 	std.Output(2, LevelWarn, fmt.Sprintf(format, v...))
 }
 
 func Errorf(format string, v ...interface{}) {
+	if !std.enabled(LevelError) {
+		return
+	}
 	std.Output(2, LevelError, fmt.Sprintf(format, v...))
 }
 
 func Fatalf(format string, v ...interface{}) {
+	if !std.enabled(LevelFatal) {
+		return
+	}
 	std.Output(2, LevelFatal, fmt.Sprintf(format, v...))
 }
+
+func Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	std.Output(2, LevelFatal, s)
+	panic(s)
+}
+
+func Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	std.Output(2, LevelFatal, s)
+	panic(s)
+}
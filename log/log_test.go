@@ -1,8 +1,27 @@
 package log
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdlog "log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestStdStreamLog(t *testing.T) {
@@ -57,7 +76,2672 @@ func TestRotatingFileLog(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if files := h.CurrentBackups(); len(files) != 2 || files[0] != fileName+".2" || files[1] != fileName+".1" {
+		t.Fatal(files)
+	}
+
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+func TestRotatingFileLogSize(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	fileName := path + "/test"
+
+	h, err := NewRotatingFileHandler(fileName, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write(make([]byte, 6))
+	h.Close()
+
+	//reopen, the existing 6 bytes must count
+	h, err = NewRotatingFileHandler(fileName, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write(make([]byte, 6))
+	if _, err := os.Stat(fileName + ".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	//larger than maxBytes, must go to a fresh file only once
+	h.Write(make([]byte, 30))
+	if f, err := os.Stat(fileName); err != nil {
+		t.Fatal(err)
+	} else if f.Size() != 30 {
+		t.Fatal(f.Size())
+	}
+
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogBackupCount(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	baseName := path + "/test"
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenSecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetBackupCount(2)
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		name := baseName + now.Add(-time.Duration(i)*time.Hour).Format(h.suffix)
+		if err := ioutil.WriteFile(name, []byte("old"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//not produced by this handler, must be kept
+	ioutil.WriteFile(baseName+".bak", []byte("other"), 0666)
+
+	h.rolloverAt = 0
+	h.Write([]byte("hello world\n"))
+
+	files, err := h.backups()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 2 {
+		t.Fatal(files)
+	}
+
+	if _, err := os.Stat(baseName + ".bak"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogRenameFail(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	//the rotated name is too long for the file system, so rename fails
+	baseName := path + "/" + strings.Repeat("t", 250)
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.rolloverAt = 0
+	if _, err := h.Write([]byte("hello\n")); err == nil {
+		t.Fatal("must fail")
+	}
+
+	//rollover is tried again and logging goes on with the current file
+	if _, err := h.Write([]byte("world\n")); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h.Close()
+
+	if b, err := ioutil.ReadFile(baseName); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello\nworld\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestRolloverCrash(t *testing.T) {
+	path := "./test_log_crash"
+	defer func() {
+		renameFile = renameNoReplace
+		openAppend = func(name string) (*os.File, error) {
+			return os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		}
+		os.RemoveAll(path)
+	}()
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	open := openAppend
+	fail := errors.New("crash")
+	for _, step := range []string{"rename", "open"} {
+		os.RemoveAll(path)
+		os.Mkdir(path, 0777)
+
+		baseName := path + "/test"
+		h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewRotatingFileHandler(path+"/size", 3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Write([]byte("a\n"))
+		r.Write([]byte("a\n"))
+
+		if step == "rename" {
+			renameFile = func(string, string) error { return fail }
+		} else {
+			openAppend = func(string) (*os.File, error) { return nil, fail }
+		}
+
+		h.rolloverAt = 0
+		if _, err := h.Write([]byte("b\n")); err != fail {
+			t.Fatal(step, err)
+		}
+		if _, err := r.Write([]byte("b\n")); err != fail {
+			t.Fatal(step, err)
+		}
+
+		renameFile, openAppend = renameNoReplace, open
+		h.Write([]byte("c\n"))
+		r.Write([]byte("c\n"))
+		h.Close()
+		r.Close()
+
+		//a and b are in the file open during the crash, c in a new one
+		backups, _ := h.backups()
+		if len(backups) != 1 || read(backups[0]) != "a\nb\n" || read(baseName) != "c\n" {
+			t.Fatal(step, backups, read(baseName))
+		}
+		if read(path+"/size.1") != "a\nb\n" || read(path+"/size") != "c\n" {
+			t.Fatal(step, read(path+"/size.1"), read(path+"/size"))
+		}
+	}
+}
+
+func TestRolloverRetryKeepsBackups(t *testing.T) {
+	path := "./test_log_retry"
+	os.RemoveAll(path)
+	defer func() {
+		renameFile = renameNoReplace
+		os.RemoveAll(path)
+	}()
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	baseName := path + "/size"
+	h, err := NewRotatingFileHandler(baseName, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a\n", "b\n", "c\n", "d\n", "e\n"} {
+		h.Write([]byte(s))
+	}
+	if read(baseName+".1") != "d\n" || read(baseName+".3") != "b\n" {
+		t.Fatal(read(baseName+".1"), read(baseName+".3"))
+	}
+
+	fail := errors.New("crash")
+	renameFile = func(string, string) error { return fail }
+	for i := 0; i < 2; i++ {
+		if _, err := h.Write([]byte("f\n")); err != fail {
+			t.Fatal(err)
+		}
+	}
+
+	renameFile = renameNoReplace
+	h.Write([]byte("g\n"))
+	h.Close()
+
+	//only b is lost to the rollover, however many times it was retried
+	for i, s := range []string{"e\nf\nf\n", "d\n", "c\n"} {
+		if name := fmt.Sprintf("%s.%d", baseName, i+1); read(name) != s {
+			t.Fatal(name, read(name))
+		}
+	}
+	if read(baseName) != "g\n" {
+		t.Fatal(read(baseName))
+	}
+}
+
+func BenchmarkFilteredLog(b *testing.B) {
+	l := NewDefault(DiscardHandler())
+	l.SetLevel(LevelError)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Debugf("%s %d", "hello", i)
+	}
+
+	l.Close()
+}
+
+func TestTimeRotatingFileLogConcurrent(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	baseName := path + "/test"
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := []byte("0123456789\n")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i == 25 && j == 50 {
+					//force a rollover while others are writing
+					h.mu.Lock()
+					h.rolloverAt = 0
+					h.mu.Unlock()
+				}
+				h.Write(line)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	h.Close()
+
+	var size int64
+	files, _ := filepath.Glob(baseName + "*")
+	if len(files) != 2 {
+		t.Fatal(files)
+	}
+	for _, name := range files {
+		f, _ := os.Stat(name)
+		size += f.Size()
+	}
+
+	if size != int64(50*100*len(line)) {
+		t.Fatal(size)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestLogTimeFormat(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Ltime)
+
+	l.SetTimeFormat("2006")
+	l.Info("hello")
+
+	l.SetTimeFormat("")
+	l.Info("world")
+
+	l.Close()
+
+	if s := fmt.Sprintf("[%d] hello\nworld\n", time.Now().Year()); buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel|Ljson)
+
+	l.WithFields(map[string]interface{}{"id": 1, "ch": make(chan int)}).Info("hello\n")
+	l.Close()
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 2 || lines[1] != "" {
+		t.Fatal(buf.String())
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["level"] != "Info" || m["msg"] != "hello" || m["id"] != float64(1) {
+		t.Fatal(m)
+	} else if _, ok := m["ch"].(string); !ok {
+		t.Fatal(m)
+	}
+}
+
+func TestTextFieldsLog(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel)
+
+	l.WithFields(map[string]interface{}{"b": 2, "a": "x"}).Info("hello")
+	l.Info("world")
+	l.Close()
+
+	if buf.String() != "[Info] hello a=x b=2\n[Info] world\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestLogStaticFields(t *testing.T) {
+	th, buf := NewTestHandler()
+	l := New(th, 0)
+	l.SetStaticFields(map[string]interface{}{"host": "h1", "app": "x"})
+
+	l.Info("a")
+	l.WithField("app", "y").Info("b")
+
+	l.SetFlags(Ljson)
+	l.WithField("k", 1).Info("c")
+
+	l.SetStaticFields(nil)
+	l.Info("d")
+	l.Close()
+
+	s := "a app=x host=h1\n" +
+		"b app=y host=h1\n" +
+		`{"msg":"c","app":"x","host":"h1","k":1}` + "\n" +
+		`{"msg":"d"}` + "\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestWithFieldChain(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel)
+
+	a := l.WithFields(map[string]interface{}{"a": 1})
+	b := a.WithField("b", 2).WithField("a", 3)
+	a.Info("a")
+	b.Info("b")
+	l.Close()
+
+	if buf.String() != "[Info] a a=1\n[Info] b a=3 b=2\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestTimeRotatingFileLogAlign(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+
+	h, err := NewTimeRotatingFileHandler(path+"/test", WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SetAlignToBoundary(true); err != nil {
+		t.Fatal(err)
+	}
+
+	t1 := time.Date(2014, 6, 1, 15, 30, 0, 0, time.Local)
+	t2 := time.Date(2014, 6, 2, 0, 0, 0, 0, time.Local)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0))
+	}
+
+	h.when = WhenHour
+	h.interval = 3600
+	t2 = time.Date(2014, 6, 1, 16, 0, 0, 0, time.Local)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0))
+	}
+
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingWeek(t *testing.T) {
+	path := "./test_time_rotating_week"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+
+	h, err := NewTimeRotatingFileHandler(path+"/test", WhenWeek, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a sunday
+	t1 := time.Date(2014, 6, 1, 15, 30, 0, 0, time.Local)
+	t2 := time.Date(2014, 6, 2, 0, 0, 0, 0, time.Local)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0))
+	}
+
+	if err := h.SetWeekday(time.Sunday); err != nil {
+		t.Fatal(err)
+	}
+	t2 = time.Date(2014, 6, 8, 0, 0, 0, 0, time.Local)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0))
+	}
+
+	if s := h.formatSuffix(t2); s != "2014-06-08_W23" {
+		t.Fatal(s)
+	}
+	if p, err := h.parseSuffix("2014-06-08_W23"); err != nil || !p.Equal(t2) {
+		t.Fatal(p, err)
+	}
+
+	h.Close()
+
+	h, err = NewTimeRotatingFileHandler(path+"/midnight", WhenMidnight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(false)
+
+	t2 = time.Date(2014, 6, 2, 0, 0, 0, 0, time.Local)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0))
+	}
+
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func TestTimeRotatingFileLogClock(t *testing.T) {
+	path := "./test_log_clock"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	h.Write([]byte("a\n"))
+	c.Advance(20 * time.Minute)
+	h.Write([]byte("b\n"))
+	c.Advance(10 * time.Minute)
+	h.Write([]byte("c\n"))
+	h.Close()
+
+	if b, err := ioutil.ReadFile(baseName + "2014-06-01_11"); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "a\nb\n" {
+		t.Fatal(string(b))
+	}
+
+	if b, err := ioutil.ReadFile(baseName); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "c\n" {
+		t.Fatal(string(b))
+	}
+
+	if r := h.RolloverAt(); !r.Equal(time.Date(2014, 6, 1, 12, 0, 0, 0, time.Local)) {
+		t.Fatal(r)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileSkipEmptyRollover(t *testing.T) {
+	path := "./test_log_skip_empty"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+	h.SetSkipEmptyRollover(true)
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	//idle for the first hour
+	c.Advance(time.Hour)
+	h.Write([]byte("a\n"))
+	c.Advance(time.Hour)
+	h.Write([]byte("b\n"))
+	h.Close()
+
+	names, _ := filepath.Glob(baseName + "*")
+	if len(names) != 2 || filepath.Base(names[1]) != "test2014-06-01_12" {
+		t.Fatal(names)
+	}
+
+	if r := h.RolloverAt(); !r.Equal(time.Date(2014, 6, 1, 13, 0, 0, 0, time.Local)) {
+		t.Fatal(r)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileRotate(t *testing.T) {
+	path := "./test_log_rotate"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	h.Write([]byte("a\n"))
+	if err := h.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	//in the same second
+	h.Write([]byte("b\n"))
+	if err := h.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("c\n"))
+
+	if r := h.RolloverAt(); !r.Equal(c.Now().Add(time.Hour)) {
+		t.Fatal(r)
+	}
+	h.Close()
+
+	for name, s := range map[string]string{"test2014-06-01_10": "a\n", "test2014-06-01_10.1": "b\n", "test": "c\n"} {
+		if b, _ := ioutil.ReadFile(path + "/" + name); string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+	if err := misused(h.Rotate); !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal("must fail after close", err)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileRotateNoClobber(t *testing.T) {
+	path := "./test_log_no_clobber"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenSecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCompress(true)
+
+	//every rollover in the same second
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	n := 20
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(h, "%d\n", i)
+		if err := h.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+
+	names, _ := filepath.Glob(baseName + "2014-06-01_10-30-00*.gz")
+	if len(names) != n {
+		t.Fatal(names)
+	}
+
+	got := make(map[string]bool)
+	for _, name := range names {
+		f, _ := os.Open(name)
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		b, _ := ioutil.ReadAll(r)
+		f.Close()
+		got[string(b)] = true
+	}
+	for i := 0; i < n; i++ {
+		if !got[fmt.Sprintf("%d\n", i)] {
+			t.Fatal("lost archive", i)
+		}
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestRotatingFileRotate(t *testing.T) {
+	path := "./test_log_size_rotate"
+	os.RemoveAll(path)
+
+	fileName := path + "/test"
+	h, err := NewRotatingFileHandler(fileName, 1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("a\n"))
+	if err := h.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("b\n"))
+	h.Close()
+
+	for name, s := range map[string]string{"test.1": "a\n", "test": "b\n"} {
+		if b, _ := ioutil.ReadFile(path + "/" + name); string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	h, _ = NewRotatingFileHandler(fileName, 1024, 0)
+	if err := h.Rotate(); err == nil {
+		t.Fatal("must fail without backups")
+	}
+	h.Close()
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileMaxTotalBytes(t *testing.T) {
+	path := "./test_log_max_total"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+	if err := h.SetMaxTotalBytes(10); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	for _, s := range []string{"aaa\n", "bbb\n", "ccc\n", "ddd\n", "eee\n"} {
+		h.Write([]byte(s))
+		c.Advance(time.Hour)
+	}
+	h.Close()
+
+	//4 bytes each, the empty active file and 2 rotated ones fit in 10 on rollover
+	names, _ := h.CurrentBackups()
+	if len(names) != 2 {
+		t.Fatal(names)
+	}
+	for i, s := range []string{"ccc\n", "ddd\n"} {
+		if b, _ := ioutil.ReadFile(names[i]); string(b) != s {
+			t.Fatal(names[i], string(b))
+		}
+	}
+
+	if err := h.SetMaxTotalBytes(-1); err == nil {
+		t.Fatal("must fail")
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestRotatingFileMaxTotalBytes(t *testing.T) {
+	path := "./test_log_size_max_total"
+	os.RemoveAll(path)
+
+	fileName := path + "/test"
+	h, err := NewRotatingFileHandler(fileName, 4, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetMaxTotalBytes(10)
+
+	for _, s := range []string{"aaa\n", "bbb\n", "ccc\n", "ddd\n", "eee\n"} {
+		h.Write([]byte(s))
+	}
+	h.Close()
+
+	//checked on rollover, when the active file is empty
+	if names := h.CurrentBackups(); len(names) != 2 {
+		t.Fatal(names)
+	}
+	for name, s := range map[string]string{".2": "ccc\n", ".1": "ddd\n", "": "eee\n"} {
+		if b, _ := ioutil.ReadFile(fileName + name); string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogMaxBytes(t *testing.T) {
+	path := "./test_log_max_bytes"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+	if err := h.SetMaxBytes(4); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	h.Write([]byte("a\n"))
+	h.Write([]byte("b\n"))
+	//rolled by size twice in the period
+	h.Write([]byte("c\n"))
+	h.Write([]byte("d\n"))
+	h.Write([]byte("e\n"))
+	//rolled by time
+	c.Advance(30 * time.Minute)
+	h.Write([]byte("f\n"))
+	h.Close()
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	if s := read(baseName + "2014-06-01_10"); s != "a\nb\n" {
+		t.Fatal(s)
+	}
+	if s := read(baseName + "2014-06-01_10.1"); s != "c\nd\n" {
+		t.Fatal(s)
+	}
+	if s := read(baseName + "2014-06-01_11"); s != "e\n" {
+		t.Fatal(s)
+	}
+	if s := read(baseName); s != "f\n" {
+		t.Fatal(s)
+	}
+
+	if r := h.RolloverAt(); !r.Equal(time.Date(2014, 6, 1, 12, 0, 0, 0, time.Local)) {
+		t.Fatal(r)
+	}
+
+	if err := h.SetMaxBytes(-1); err == nil {
+		t.Fatal("must fail")
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileLogRotationMarkers(t *testing.T) {
+	path := "./test_log_markers"
+	os.RemoveAll(path)
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	baseName := path + "/time"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+	h.SetRotationMarkers(DefaultRotationMarker, "--- new file")
+
+	start := time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)
+	c := &fakeClock{t: start}
+	h.setClock(c)
+
+	h.Write([]byte("a\n"))
+	c.Advance(30 * time.Minute)
+	h.Write([]byte("b\n"))
+	h.Close()
+
+	at := start.Add(30 * time.Minute).Format(time.RFC3339)
+	if s := read(baseName + "2014-06-01_11"); s != "a\n--- rotated at "+at+" ---\n" {
+		t.Fatal(s)
+	}
+	if s := read(baseName); s != "--- new file\nb\n" {
+		t.Fatal(s)
+	}
+
+	fileName := path + "/size"
+	r, err := NewRotatingFileHandler(fileName, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetRotationMarkers("--- end", "")
+
+	r.Write([]byte("a\n"))
+	r.Write([]byte("b\n"))
+	r.Write([]byte("c\n"))
+	r.Close()
+
+	if s := read(fileName + ".1"); s != "a\nb\n--- end\n" {
+		t.Fatal(s)
+	}
+	if s := read(fileName); s != "c\n" {
+		t.Fatal(s)
+	}
+
+	os.RemoveAll(path)
+}
+
+//timeHandler records logs and the times they are written with.
+type timeHandler struct {
+	*TestHandler
+	times []time.Time
+}
+
+func (h *timeHandler) WriteTime(level int, t time.Time, p []byte) (int, error) {
+	h.times = append(h.times, t)
+	return h.Write(p)
+}
+
+func TestLogWriteTime(t *testing.T) {
+	th, buf := NewTestHandler()
+	h := &timeHandler{TestHandler: th}
+
+	l := New(h, Ltime)
+	l.SetTimeFormat(TimeFormatMicro)
+	l.Info("a")
+	l.Close()
+
+	lt, err := time.ParseInLocation("[2006/01/02 15:04:05.000000] a\n", buf.String(), time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.times) != 1 || !h.times[0].Truncate(time.Microsecond).Equal(lt) {
+		t.Fatal(h.times, lt)
+	}
+
+	//the time of the log decides rollover, not the clock
+	path := "./test_log_write_time"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	fh, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.SetAlignToBoundary(true)
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 59, 59, 0, time.Local)}
+	fh.setClock(c)
+
+	fh.WriteTime(LevelInfo, c.Now(), []byte("a\n"))
+	fh.WriteTime(LevelInfo, c.Now().Add(time.Second), []byte("b\n"))
+	fh.Close()
+
+	if b, err := ioutil.ReadFile(baseName + "2014-06-01_11"); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "a\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogNameFunc(t *testing.T) {
+	path := "test_log_name_func"
+	os.RemoveAll(path)
+
+	baseName := path + "/app.log"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetAlignToBoundary(true)
+	h.SetBackupCount(2)
+	h.SetMaxBytes(2)
+	h.SetNameFunc(func(base string, t time.Time, seq int) string {
+		name := filepath.Join(filepath.Dir(base), t.Format("2006/01/02_15"))
+		if seq > 0 {
+			name += fmt.Sprintf("-%d", seq)
+		}
+		return name + ".log"
+	})
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	if n := h.RotatedName(c.Now()); n != path+"/2014/06/01_10.log" {
+		t.Fatal(n)
+	}
+
+	h.Write([]byte("a\n"))
+	h.Write([]byte("b\n"))
+	h.Write([]byte("c\n"))
+	c.Advance(30 * time.Minute)
+	h.Write([]byte("d\n"))
+	h.Close()
+
+	if backups, _ := h.CurrentBackups(); len(backups) != 2 ||
+		backups[0] != path+"/2014/06/01_10-1.log" || backups[1] != path+"/2014/06/01_11.log" {
+		t.Fatal(backups)
+	}
+
+	files := map[string]string{
+		"2014/06/01_10-1.log": "b\n",
+		"2014/06/01_11.log":   "c\n",
+		"app.log":             "d\n",
+	}
+	for name, s := range files {
+		if b, err := ioutil.ReadFile(path + "/" + name); err != nil {
+			t.Fatal(err)
+		} else if string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	//the oldest one is deleted
+	if fileExists(path + "/2014/06/01_10.log") {
+		t.Fatal("must be deleted")
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogNameFuncNoSeq(t *testing.T) {
+	path := "test_log_name_func_no_seq"
+	os.RemoveAll(path)
+
+	baseName := path + "/app.log"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//seq is ignored, the second rollover of a day must not hang
+	h.SetNameFunc(func(base string, t time.Time, seq int) string {
+		return base + t.Format(".20060102")
+	})
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+	h.setClock(c)
+
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		h.Write([]byte(s))
+		if err := h.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+
+	files := map[string]string{
+		"app.log.20140601":   "a\n",
+		"app.log.20140601.1": "b\n",
+		"app.log.20140601.2": "c\n",
+	}
+	for name, s := range files {
+		if b, _ := ioutil.ReadFile(path + "/" + name); string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogSharedRollover(t *testing.T) {
+	path := "./test_log_shared"
+	os.RemoveAll(path)
+	defer func() {
+		renameFile = renameNoReplace
+		os.RemoveAll(path)
+	}()
+
+	baseName := path + "/test"
+	c := &fakeClock{t: time.Date(2014, 6, 1, 10, 30, 0, 0, time.Local)}
+
+	//two handlers of the same file, like two processes
+	var hs []*TimeRotatingFileHandler
+	for i := 0; i < 2; i++ {
+		h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.SetAlignToBoundary(true)
+		h.setClock(c)
+		hs = append(hs, h)
+	}
+
+	hs[0].Write([]byte("a\n"))
+	hs[1].Write([]byte("b\n"))
+	c.Advance(30 * time.Minute)
+
+	//the other one rotates to the name once it is checked free
+	renameFile = func(src string, dst string) error {
+		renameFile = renameNoReplace
+		ioutil.WriteFile(dst, []byte("x\n"), 0666)
+		return renameNoReplace(src, dst)
+	}
+
+	hs[0].Write([]byte("c\n"))
+	hs[1].Write([]byte("d\n"))
+	hs[0].Close()
+	hs[1].Close()
+
+	names, _ := filepath.Glob(baseName + "*")
+	var lines []string
+	for _, name := range names {
+		b, _ := ioutil.ReadFile(name)
+		lines = append(lines, strings.Fields(string(b))...)
+	}
+	sort.Strings(lines)
+
+	if s := strings.Join(lines, ""); s != "abcdx" {
+		t.Fatal(names, s)
+	}
+	if len(names) != 4 {
+		t.Fatal(names)
+	}
+}
+
+func TestTimeRotatingFileLogSyncInterval(t *testing.T) {
+	path := "./test_log_sync_interval"
+	os.RemoveAll(path)
+
+	var mu sync.Mutex
+	synced := make(map[string]int)
+	syncFile = func(fd *os.File) error {
+		mu.Lock()
+		synced[fd.Name()]++
+		mu.Unlock()
+		return fd.Sync()
+	}
+	defer func() {
+		syncFile = (*os.File).Sync
+		os.RemoveAll(path)
+	}()
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetSyncInterval(time.Millisecond)
+
+	h.Write([]byte("a\n"))
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	if synced[baseName] == 0 {
+		t.Fatal(synced)
+	}
+	mu.Unlock()
+
+	//synced once more before rollover, then no periodic sync
+	h.SetSyncInterval(0)
+	mu.Lock()
+	n := synced[baseName]
+	mu.Unlock()
+
+	h.mu.Lock()
+	h.syncInterval = time.Hour
+	h.rolloverAt = 0
+	h.mu.Unlock()
+	h.Write([]byte("b\n"))
+	time.Sleep(5 * time.Millisecond)
+
+	mu.Lock()
+	if synced[baseName] != n+1 {
+		t.Fatal(n, synced)
+	}
+	mu.Unlock()
+
+	h.SetSyncInterval(time.Millisecond)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeRotatingFileLogRotatedName(t *testing.T) {
+	path := "./test_log_rotated_name"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if n := h.RotatedName(time.Date(2014, 6, 1, 11, 0, 0, 0, time.Local)); n != baseName+"2014-06-01_11" {
+		t.Fatal(n)
+	}
+	if _, err := os.Stat(baseName + "2014-06-01_11"); !os.IsNotExist(err) {
+		t.Fatal("must not touch the filesystem")
+	}
+
+	//time.Local can not be changed safely, so the suffix is checked in the zone directly
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	//01:30 happens twice when daylight saving time ends
+	edt := time.Date(2014, 11, 2, 5, 30, 0, 0, time.UTC).In(loc)
+	est := edt.Add(time.Hour)
+
+	s1, s2 := h.formatSuffix(edt), h.formatSuffix(est)
+	if s1 != "2014-11-02_01" || s2 != "2014-11-02_01-0500" {
+		t.Fatal(s1, s2)
+	}
+
+	if r, err := h.parseSuffix(s2); err != nil {
+		t.Fatal(err)
+	} else if !r.Equal(est.Truncate(time.Hour)) {
+		t.Fatal(r)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogInvalid(t *testing.T) {
+	if _, err := NewTimeRotatingFileHandler("", WhenDay, 1); err == nil {
+		t.Fatal("must error with an empty base name")
+	}
+
+	for _, interval := range []int{0, -1} {
+		if _, err := NewTimeRotatingFileHandler("./test_log_invalid", WhenDay, interval); err == nil {
+			t.Fatal("must error with interval", interval)
+		}
+	}
+
+	if _, err := NewTimeRotatingFileHandler("./test_log_invalid", 100, 1); err == nil {
+		t.Fatal("must error with an invalid when")
+	}
+	os.Remove("./test_log_invalid")
+}
+
+func TestTimeRotatingFileLogUTC(t *testing.T) {
+	path := "./test_log_utc"
+	os.RemoveAll(path)
+	os.Mkdir(path, 0777)
+
+	h, err := NewTimeRotatingFileHandler(path+"/utc", WhenMidnight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.SetUTC(true); err != nil {
+		t.Fatal(err)
+	}
+
+	//22:00 June 1 at UTC-5 is 03:00 June 2 UTC
+	t1 := time.Date(2014, 6, 1, 22, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	t2 := time.Date(2014, 6, 3, 0, 0, 0, 0, time.UTC)
+	if r := h.computeRollover(t1); r != t2.Unix() {
+		t.Fatal(time.Unix(r, 0).UTC())
+	}
+	if s := h.formatSuffix(h.in(t1)); s != "2014-06-02" {
+		t.Fatal(s)
+	}
+	if tm, err := h.parseSuffix("2014-06-02"); err != nil || tm.Location() != time.UTC {
+		t.Fatal(tm, err)
+	}
+	h.Close()
+
+	var buf bytes.Buffer
+	sh, _ := NewStreamHandler(&buf)
+	l := New(sh, Ltime)
+	l.SetTimeFormat("MST")
+	l.SetUTC(true)
+	l.Info("hello")
+	l.Close()
+
+	if buf.String() != "[UTC] hello\n" {
+		t.Fatal(buf.String())
+	}
+
+	os.RemoveAll(path)
+}
+
+type testCtxKey string
+
+func TestContextLog(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel).WithContextKey(testCtxKey("request_id"))
+
+	ctx := context.WithValue(context.Background(), testCtxKey("request_id"), 123)
+	l.InfoContext(ctx, "hello")
+	l.InfoContext(context.Background(), "world")
+	l.Close()
+
+	if buf.String() != "[Info] hello request_id=123\n[Info] world\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestLogCaller(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Lfile)
+
+	l.Info("hello")
+	_, _, line, _ := runtime.Caller(0)
+
+	l.SetFlags(0)
+	l.Info("world")
+	l.Close()
+
+	if s := fmt.Sprintf("log_test.go:%d hello\nworld\n", line-1); buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func BenchmarkFileHandlerWrite(b *testing.B) {
+	h, _ := NewFileHandler(os.DevNull, os.O_WRONLY)
+	s := strings.Repeat("hello world, this is a log line. ", 4) + "\n"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Write([]byte(s))
+	}
+
+	h.Close()
+}
+
+func BenchmarkUnsafeFileHandlerWrite(b *testing.B) {
+	h, _ := NewUnsafeFileHandler(os.DevNull, os.O_WRONLY)
+	s := strings.Repeat("hello world, this is a log line. ", 4) + "\n"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Write([]byte(s))
+	}
+
+	h.Close()
+}
+
+func BenchmarkTimeRotatingRollover10k(b *testing.B) {
+	path := "./test_log_bench_rollover"
+	os.RemoveAll(path)
+	os.Mkdir(path, 0777)
+	defer os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenSecond, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	h.SetBackupCount(10000)
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 0, 0, 0, 0, time.Local)}
+	for i := 0; i < 10000; i++ {
+		ioutil.WriteFile(baseName+h.formatSuffix(c.Now()), nil, 0666)
+		c.Advance(time.Second)
+	}
+	h.setClock(c)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Advance(time.Second)
+		h.Write([]byte("hello\n"))
+	}
+	b.StopTimer()
+
+	h.Close()
+}
+
+func TestUnsafeFileHandler(t *testing.T) {
+	path := "./test_unsafe_log"
+	os.RemoveAll(path)
+
+	h, err := NewUnsafeFileHandler(path+"/test.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("hello\n"))
+	h.WriteString("world\n")
+	h.Close()
+
+	if b, err := ioutil.ReadFile(h.Filename()); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello\nworld\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func BenchmarkFileHandlerWriteString(b *testing.B) {
+	h, _ := NewFileHandler(os.DevNull, os.O_WRONLY)
+	s := strings.Repeat("hello world, this is a log line. ", 4) + "\n"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.WriteString(s)
+	}
+
+	h.Close()
+}
+
+type syncCountHandler struct {
+	StreamHandler
+	syncs int
+}
+
+func (h *syncCountHandler) Sync() error {
+	h.syncs++
+	return nil
+}
+
+func TestLogSync(t *testing.T) {
+	var buf bytes.Buffer
+	h := new(syncCountHandler)
+	h.w = &buf
+
+	l := New(h, 0)
+
+	l.Info("hello")
+	l.Fatal("fatal")
+
+	//fatal must be written and synced when Fatal returns
+	if buf.String() != "hello\nfatal\n" || h.syncs != 1 {
+		t.Fatal(buf.String(), h.syncs)
+	}
+
+	if err := l.Sync(); err != nil || h.syncs != 2 {
+		t.Fatal(err, h.syncs)
+	}
+
+	l.Close()
+
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogFlush(t *testing.T) {
+	th, buf := NewTestHandler()
+	b, _ := NewBufferedHandler(th, 4096, time.Hour)
+	a, _ := NewAsyncHandler(b, 16, AsyncBlock)
+
+	l := New(a, 0)
+	l.Info("hello")
+
+	//flushes through the async and buffered handlers without closing them
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	} else if th.String() != "hello\n" {
+		t.Fatal(th.String())
+	}
+
+	l.Info("world")
+	l.Close()
+
+	if buf.String() != "hello\nworld\n" {
+		t.Fatal(buf.String())
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type flushCountHandler struct {
+	StreamHandler
+	flushes int
+}
+
+func (h *flushCountHandler) Flush() error {
+	h.flushes++
+	return nil
+}
+
+func TestLogFlushLevel(t *testing.T) {
+	var buf bytes.Buffer
+	h := new(flushCountHandler)
+	h.w = &buf
+
+	l := New(h, 0)
+	l.SetFlushLevel(LevelError)
+
+	l.Info("a")
+	l.Error("b")
+	l.Warn("c")
+	l.Error("d")
+	l.Sync()
+
+	if h.flushes != 2 {
+		t.Fatal(h.flushes)
+	}
+	l.Close()
+}
+
+func TestLogBoostLevel(t *testing.T) {
+	l := New(DiscardHandler(), 0)
+	l.SetLevel(LevelWarn)
+
+	waitLevel := func(level int) {
+		for i := 0; i < 100 && l.level.Get() != level; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := l.level.Get(); n != level {
+			t.Fatal(n)
+		}
+	}
+
+	//the derived logger shares the boost
+	w := l.WithField("k", 1)
+	l.BoostLevel(LevelDebug, 50*time.Millisecond)
+	w.BoostLevel(LevelTrace, 50*time.Millisecond)
+	if n := l.level.Get(); n != LevelTrace {
+		t.Fatal(n)
+	}
+	waitLevel(LevelWarn)
+
+	//SetLevel ends the boost
+	l.BoostLevel(LevelDebug, 50*time.Millisecond)
+	l.SetLevel(LevelError)
+	time.Sleep(100 * time.Millisecond)
+	if n := l.level.Get(); n != LevelError {
+		t.Fatal(n)
+	}
+
+	//a clone has its own level
+	c := l.Clone()
+	c.BoostLevel(LevelDebug, time.Hour)
+	if n := l.level.Get(); n != LevelError {
+		t.Fatal(n)
+	}
+	c.SetLevel(LevelInfo)
+	l.Close()
+}
+
+func TestRegisterLevel(t *testing.T) {
+	old, _ := levels.Load().(*levelTable)
+	defer levels.Store(old)
+
+	const levelAudit = LevelFatal + 1
+	RegisterLevel(LevelWarn, "WARNING")
+	RegisterLevel(levelAudit, "Audit")
+	if err := RegisterLevel(levelAudit, ""); err == nil {
+		t.Fatal("must fail")
+	}
+
+	th, buf := NewTestHandler()
+	l := New(th, Llevel)
+	l.Warn("a")
+	l.Output(2, levelAudit, "b")
+	l.Output(2, levelAudit+1, "c")
+	l.Close()
+
+	if buf.String() != "[WARNING] a\n[Audit] b\n[Level7] c\n" {
+		t.Fatal(buf.String())
+	}
+
+	for name, level := range map[string]int{"warning": LevelWarn, "Warn": LevelWarn, "AUDIT": levelAudit} {
+		if n, err := ParseLevel(name); err != nil || n != level {
+			t.Fatal(name, n, err)
+		}
+	}
+}
+
+func TestLevelFromEnv(t *testing.T) {
+	const name = "TEST_LOG_LEVEL"
+	defer os.Unsetenv(name)
+
+	os.Unsetenv(name)
+	if _, err := LevelFromEnv(name); err == nil {
+		t.Fatal("must fail if not set")
+	}
+
+	for s, level := range map[string]int{"debug": LevelDebug, "WARN": LevelWarn, " Error ": LevelError} {
+		os.Setenv(name, s)
+		if l, err := LevelFromEnv(name); err != nil {
+			t.Fatal(err)
+		} else if l != level {
+			t.Fatal(s, l)
+		}
+	}
+
+	os.Setenv(name, "verbose")
+	if _, err := LevelFromEnv(name); err == nil {
+		t.Fatal("must fail with an invalid name")
+	}
+}
+
+//diskFullHandler fails the first full writes with ENOSPC.
+type diskFullHandler struct {
+	buf  bytes.Buffer
+	full int
+}
+
+func (h *diskFullHandler) Write(p []byte) (int, error) {
+	if h.full > 0 {
+		h.full--
+		return 0, &os.PathError{Op: "write", Path: "test", Err: syscall.ENOSPC}
+	}
+	return h.buf.Write(p)
+}
+
+func (h *diskFullHandler) Close() error {
+	return nil
+}
+
+func TestLogDiskFull(t *testing.T) {
+	var fallback bytes.Buffer
+	defer func(w io.Writer) { diskFullFallback = w }(diskFullFallback)
+	diskFullFallback = &fallback
+
+	h := &diskFullHandler{full: 2}
+	l := New(h, 0)
+	l.SetDiskFullRetry(2, time.Millisecond)
+
+	l.Info("a")
+	l.Sync()
+	if h.buf.String() != "a\n" || fallback.Len() != 0 {
+		t.Fatal(h.buf.String(), fallback.String())
+	}
+
+	//retries exhausted
+	h.full = 3
+	l.Info("b")
+	l.Sync()
+	if h.buf.String() != "a\n" || fallback.String() != "b\n" {
+		t.Fatal(h.buf.String(), fallback.String())
+	}
+
+	if n := l.DiskFullCount(); n != 2 {
+		t.Fatal(n)
+	}
+
+	//without retry the log is dropped, but counted
+	l.SetDiskFullRetry(0, 0)
+	h.full = 1
+	l.Info("c")
+	l.Sync()
+	if h.buf.String() != "a\n" || fallback.String() != "b\n" || l.DiskFullCount() != 3 {
+		t.Fatal(h.buf.String(), fallback.String(), l.DiskFullCount())
+	}
+	l.Close()
+}
+
+func TestFileHandlerCloseTwice(t *testing.T) {
+	path := "./test_log_close"
+	os.RemoveAll(path)
+
+	fh, _ := NewFileHandler(path+"/file", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	rh, _ := NewRotatingFileHandler(path+"/size", 1024, 1)
+	th, _ := NewTimeRotatingFileHandler(path+"/time", WhenDay, 1)
+	dh, _ := NewDateFileHandler(path + "/date.log")
+	uh, _ := NewUnsafeFileHandler(path+"/unsafe", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+
+	for _, h := range []Handler{fh, rh, th, dh, uh} {
+		if err := h.Close(); err != nil {
+			t.Fatal(handlerName(h), err)
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(handlerName(h), err)
+		}
+		err := misused(func() error {
+			_, err := h.Write([]byte("a\n"))
+			return err
+		})
+		if !errors.Is(err, ErrHandlerClosed) {
+			t.Fatal(handlerName(h), err)
+		}
+		if err := misused(func() error { return syncHandler(h) }); !errors.Is(err, ErrHandlerClosed) {
+			t.Fatal(handlerName(h), err)
+		}
+	}
+
+	if err := misused(fh.Reopen); !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal(err)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerBadFD(t *testing.T) {
+	path := "./test_log_bad_fd"
+	os.RemoveAll(path)
+
+	fh, _ := NewFileHandler(path+"/file", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	rh, _ := NewRotatingFileHandler(path+"/size", 1024, 1)
+	th, _ := NewTimeRotatingFileHandler(path+"/time", WhenDay, 1)
+
+	//closed by mistake, the file is reopened once
+	fh.fd.Close()
+	rh.fd.Close()
+	th.fd.Close()
+
+	for _, h := range []Handler{fh, rh, th} {
+		if _, err := h.Write([]byte("a\n")); err != nil {
+			t.Fatal(handlerName(h), err)
+		}
+		if _, err := h.Write([]byte("b\n")); err != nil {
+			t.Fatal(handlerName(h), err)
+		}
+		h.Close()
+	}
+
+	for _, name := range []string{"file", "size", "time"} {
+		if b, _ := ioutil.ReadFile(path + "/" + name); string(b) != "a\nb\n" {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerOffset(t *testing.T) {
+	path := "./test_log_offset"
+	os.RemoveAll(path)
+	os.Mkdir(path, 0777)
+
+	fileName := path + "/file"
+	ioutil.WriteFile(fileName, []byte("abc\n"), 0666)
+
+	h, _ := NewFileHandler(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if n := h.Offset(); n != 4 {
+		t.Fatal(n)
+	}
+
+	var offsets []int64
+	for _, p := range []string{"r1\n", "x\n", "r2\n", "r3\n"} {
+		if p[0] == 'x' {
+			h.Write([]byte(p))
+			continue
+		}
+
+		if p == "r3\n" {
+			//reopened, the offset is in the new file
+			h.fd.Close()
+		}
+
+		off, n, err := h.WriteRecord([]byte(p))
+		if err != nil || n != len(p) {
+			t.Fatal(n, err)
+		}
+		offsets = append(offsets, off)
+	}
+	h.Close()
+
+	b, _ := ioutil.ReadFile(fileName)
+	for i, off := range offsets {
+		if s := string(b[off : off+3]); s != fmt.Sprintf("r%d\n", i+1) {
+			t.Fatal(i, off, s)
+		}
+	}
+	if n := h.Offset(); n != int64(len(b)) {
+		t.Fatal(n, len(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestNewStdLogger(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+	}()
+
+	out, _ := ioutil.TempFile("", "stdout")
+	errOut, _ := ioutil.TempFile("", "stderr")
+	defer os.Remove(out.Name())
+	defer os.Remove(errOut.Name())
+	os.Stdout, os.Stderr = out, errOut
+
+	l := NewStdLogger(LevelWarn)
+	l.SetFlags(Llevel)
+	l.Info("i")
+	l.Warn("w")
+	l.Error("e")
+	l.Close()
+
+	//errLevel and above go to stderr
+	if b, _ := ioutil.ReadFile(out.Name()); string(b) != "[Info] i\n" {
+		t.Fatal(string(b))
+	}
+	if b, _ := ioutil.ReadFile(errOut.Name()); string(b) != "[Warn] w\n[Error] e\n" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestLoggerLogErr(t *testing.T) {
+	th, buf := NewTestHandler()
+	l := New(th, Lfile)
+
+	err := errors.New("boom")
+	if e := l.LogErr(LevelError, err); e != err {
+		t.Fatal(e)
+	}
+	if e := l.LogErr(LevelError, nil); e != nil {
+		t.Fatal(e)
+	}
+
+	l.SetLevel(LevelError)
+	l.LogErr(LevelWarn, err)
+	l.Close()
+
+	if s := buf.String(); !strings.HasPrefix(s, "log_test.go:") || !strings.HasSuffix(s, " boom error=boom\n") || strings.Count(s, "\n") != 1 {
+		t.Fatal(s)
+	}
+}
+
+//panicHandler panics on a log containing boom, and on Sync.
+type panicHandler struct {
+	buf bytes.Buffer
+}
+
+func (h *panicHandler) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("boom")) {
+		panic("bug")
+	}
+	return h.buf.Write(p)
+}
+
+func (h *panicHandler) Sync() error {
+	panic("sync bug")
+}
+
+func (h *panicHandler) Close() error {
+	return nil
+}
+
+func TestLoggerRecoverPanics(t *testing.T) {
+	var fallback bytes.Buffer
+	handlerPanicFallback = &fallback
+	defer func() {
+		handlerPanicFallback = os.Stderr
+	}()
+
+	h := new(panicHandler)
+	m := &testMetrics{levels: make(map[int]int)}
+	l := New(h, 0)
+	l.SetMetrics(m)
+
+	l.Info("a")
+	l.Info("boom")
+	l.Info("b")
+	if err := l.Sync(); err == nil || !strings.Contains(err.Error(), "sync bug") {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	if h.buf.String() != "a\nb\n" {
+		t.Fatal(h.buf.String())
+	}
+	if s := fallback.String(); s != "log: handler panicked: bug\nboom\nlog: handler panicked: sync bug\n" {
+		t.Fatal(s)
+	}
+	if m.handlerPanics != 2 || m.dropped != 1 {
+		t.Fatal(m.handlerPanics, m.dropped)
+	}
+}
+
+func TestLoggerHealthCheck(t *testing.T) {
+	path := "./test_log_check"
+	os.RemoveAll(path)
+
+	fh, _ := NewFileHandler(path+"/file", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	rh, _ := NewRotatingFileHandler(path+"/size", 1024, 1)
+	th, _ := NewTimeRotatingFileHandler(path+"/time", WhenDay, 1)
+	ro, _ := NewFileHandler(path+"/file", os.O_RDONLY)
+
+	m, _ := NewMultiHandler(fh, rh, th)
+	a, _ := NewAsyncHandler(m, 16, AsyncBlock)
+	l := New(a, 0)
+
+	if err := l.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	//removed by mistake, logs go nowhere until reopened
+	os.Remove(path + "/time")
+	if err := l.HealthCheck(); err == nil {
+		t.Fatal("must fail")
+	}
+	th.Reopen()
+	if err := l.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ro.Check(); err == nil {
+		t.Fatal("read only must fail")
+	}
+	ro.Close()
+
+	l.Close()
+	if err := l.HealthCheck(); err != ErrHandlerClosed {
+		t.Fatal(err)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerPerm(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	fileName := path + "/test"
+
+	h, err := NewFileHandler(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if f, err := os.Stat(fileName); err != nil {
+		t.Fatal(err)
+	} else if f.Mode().Perm()&0600 != 0600 {
+		t.Fatal(f.Mode())
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogMissing(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	baseName := path + "/test"
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(baseName)
+
+	h.rolloverAt = 0
+	if _, err := h.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if b, err := ioutil.ReadFile(baseName); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogCompress(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	baseName := path + "/test"
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCompress(true)
+
+	h.Write([]byte("hello\n"))
+	h.rolloverAt = 0
+	h.Write([]byte("world\n"))
+	h.Close()
+
+	name := baseName + time.Now().Format(h.suffix)
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello\n" {
+		t.Fatal(string(b))
+	}
+
+	if files, _ := h.backups(); len(files) != 1 {
+		t.Fatal(files)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewStreamHandler(&buf)
+	l := New(h, Llevel)
+
+	sl := stdlog.New(l.Writer(LevelError), "", 0)
+	sl.Println("hello")
+	l.WithFields(map[string]interface{}{"a": 1}).Writer(LevelWarn).Write([]byte("world\n"))
+	l.Close()
+
+	if buf.String() != "[Error] hello\n[Warn] world a=1\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestFileHandlerReopen(t *testing.T) {
+	path := "./test_log"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	fileName := path + "/test"
+
+	h, err := NewFileHandler(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("old\n"))
+	os.Rename(fileName, fileName+".1")
+
+	if err := h.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("new\n"))
+	h.Close()
+
+	if b, _ := ioutil.ReadFile(fileName + ".1"); string(b) != "old\n" {
+		t.Fatal(string(b))
+	}
+
+	if b, _ := ioutil.ReadFile(fileName); string(b) != "new\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestLogTimeFormatMicro(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Ltime)
+
+	l.SetTimeFormat(TimeFormatMicro)
+	l.Info("hello")
+	l.Close()
+
+	if ok, _ := regexp.MatchString(`^\[\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6}\] hello\n$`, buf.String()); !ok {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestPanicLog(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel)
+	defer l.Close()
+
+	defer func() {
+		if r := recover(); r != "hello 1" {
+			t.Fatal(r)
+		}
+
+		if buf.String() != "[Fatal] hello 1\n" {
+			t.Fatal(buf.String())
+		}
+	}()
+
+	l.Panicf("hello %d", 1)
+}
+
+func TestSetLogDir(t *testing.T) {
+	path := "./test_log_dir"
+	os.RemoveAll(path)
+
+	if err := SetLogDir(path + "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetLogDir("")
+
+	h, err := NewFileHandler("test.log", os.O_CREATE|os.O_WRONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if name := h.Filename(); name != filepath.Join(path, "a/b/test.log") {
+		t.Fatal(name)
+	}
+	if _, err := os.Stat(h.Filename()); err != nil {
+		t.Fatal(err)
+	}
+
+	abs, _ := filepath.Abs(path + "/abs.log")
+	h, err = NewFileHandler(abs, os.O_CREATE|os.O_WRONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if h.Filename() != abs {
+		t.Fatal(h.Filename())
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerParentDir(t *testing.T) {
+	path := "./test_log_parent"
+	os.RemoveAll(path)
+
+	SetLogDirPerm(0700)
+	defer SetLogDirPerm(0755)
+
+	h, err := NewRotatingFileHandler(path+"/a/b/test.log", 1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	if f, err := os.Stat(path + "/a/b"); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && f.Mode().Perm() != 0700 {
+		t.Fatal(f.Mode())
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestJSONRotatingFileLog(t *testing.T) {
+	path := "./test_log_json"
+	os.RemoveAll(path)
+
+	baseName := path + "/test.log"
+
+	h, err := NewRotatingFileHandler(baseName, 200, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, Ltime|Llevel|Ljson)
+	for i := 0; i < 50; i++ {
+		l.WithField("i", i).Info("hello world")
+	}
+	l.Close()
+
+	files, _ := filepath.Glob(baseName + "*")
+	if len(files) < 2 {
+		t.Fatal(files)
+	}
+
+	n := 0
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			var m map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatal(name, line, err)
+			}
+			n++
+		}
+	}
+
+	if n != 50 {
+		t.Fatal(n)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogCollision(t *testing.T) {
+	path := "./test_log_collision"
+	os.RemoveAll(path)
+
+	os.Mkdir(path, 0777)
+	baseName := path + "/test"
+
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("0\n"))
+	for i := 1; i < 3; i++ {
+		h.rolloverAt = 0
+		h.Write([]byte(fmt.Sprintf("%d\n", i)))
+	}
+	h.Close()
+
+	files, err := h.backups()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 2 {
+		t.Fatal(files)
+	}
+
+	//the second backup is named with a counter, the first one is kept
+	if !strings.HasSuffix(files[1], ".1") {
+		t.Fatal(files)
+	}
+	if b, _ := ioutil.ReadFile(files[0]); string(b) != "0\n" {
+		t.Fatal(string(b))
+	}
+
+	os.RemoveAll(path)
+}
+
+func BenchmarkLog(b *testing.B) {
+	l := New(DiscardHandler(), Ltime|Llevel)
+	s := strings.Repeat("hello world, this is a log line. ", 4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info(s)
+	}
+
+	l.Close()
+}
+
+func BenchmarkLogInfoMsg(b *testing.B) {
+	l := New(DiscardHandler(), Ltime|Llevel)
+	s := strings.Repeat("hello world, this is a log line. ", 4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoMsg(s)
+	}
+
+	l.Close()
+}
+
+func BenchmarkLogInfoMsgFiltered(b *testing.B) {
+	l := New(DiscardHandler(), Ltime|Llevel)
+	l.SetLevel(LevelWarn)
+	s := strings.Repeat("hello world, this is a log line. ", 4)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoMsg(s)
+	}
+
+	l.Close()
+}
+
+//signalHandler signals every write, so a test can wait for logs to be written.
+type signalHandler chan struct{}
+
+func (h signalHandler) Write(p []byte) (int, error) {
+	h <- struct{}{}
+	return len(p), nil
+}
+
+func (h signalHandler) Close() error {
+	return nil
+}
+
+func TestLogMsg(t *testing.T) {
+	th, buf := NewTestHandler()
+	l := New(th, Llevel)
+	l.SetLevel(LevelTrace)
+
+	l.TraceMsg("t")
+	l.DebugMsg("d")
+	l.InfoMsg("i")
+	l.WarnMsg("w")
+	l.ErrorMsg("e")
+	l.FatalMsg("f")
+	l.Close()
+
+	if s := buf.String(); s != "[Trace] t\n[Debug] d\n[Info] i\n[Warn] w\n[Error] e\n[Fatal] f\n" {
+		t.Fatal(s)
+	}
+}
+
+func TestLogInfoMsgAllocs(t *testing.T) {
+	h := make(signalHandler, 1)
+	l := New(h, Ltime|Llevel)
+	defer l.Close()
+
+	//each log is written before the next one, else the buffer pool is drained
+	//by the queue and allocates, 0 usually, the race detector drops pooled buffers at random
+	s := strings.Repeat("hello world, this is a log line. ", 4)
+	if n := testing.AllocsPerRun(1000, func() { l.InfoMsg(s); <-h }); n > 1 {
+		t.Fatal(n)
+	}
+
+	l.SetLevel(LevelWarn)
+	if n := testing.AllocsPerRun(1000, func() { l.InfoMsg(s) }); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+//closeCheckHandler fails the test if written after closed.
+type closeCheckHandler struct {
+	t      *testing.T
+	closed bool
+	n      int
+}
+
+func (h *closeCheckHandler) Write(p []byte) (int, error) {
+	if h.closed {
+		h.t.Error("write after close")
+	}
+	h.n++
+	return len(p), nil
+}
+
+func (h *closeCheckHandler) Close() error {
+	h.closed = true
+	return nil
+}
+
+func TestLogSetHandler(t *testing.T) {
+	h1 := &closeCheckHandler{t: t}
+	l := New(h1, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info("hello")
+			}
+		}()
+	}
+
+	h2 := &closeCheckHandler{t: t}
+	l.SetHandler(h2)
+
+	h3 := &closeCheckHandler{t: t}
+	if old := l.SwapHandler(h3); old != h2 {
+		t.Fatal(old)
+	}
+
+	wg.Wait()
+	l.Close()
+
+	if !h1.closed || h2.closed || !h3.closed {
+		t.Fatal(h1.closed, h2.closed, h3.closed)
+	}
+	if n := h1.n + h2.n + h3.n; n != 400 {
+		t.Fatal(n)
+	}
+}
+
+func TestFileHandlerLock(t *testing.T) {
+	path := "./test_log_lock"
+	os.RemoveAll(path)
+
+	h1, err := NewTimeRotatingFileHandler(path+"/test.log", WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h1.LockFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	h2, err := NewFileHandler(path+"/test.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h2.LockFile(); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h1.Close()
+	if err := misused(h1.LockFile); !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal(err)
+	}
+
+	if err := h2.LockFile(); err != nil {
+		t.Fatal(err)
+	}
+	h2.Close()
+
+	//the lock file is removed on Close
+	if _, err := os.Stat(path + "/test.log.lock"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerPIDFile(t *testing.T) {
+	path := "./test_log_pid"
+	os.RemoveAll(path)
+
+	fh, _ := NewFileHandler(path+"/file.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	rh, _ := NewRotatingFileHandler(path+"/size.log", 1024, 1)
+	th, _ := NewTimeRotatingFileHandler(path+"/time.log", WhenDay, 1)
+
+	for _, h := range []interface {
+		Handler
+		WritePIDFile() error
+	}{fh, rh, th} {
+		if err := h.WritePIDFile(); err != nil {
+			t.Fatal(err)
+		}
+		h.WritePIDFile()
+	}
+
+	pid := strconv.Itoa(os.Getpid()) + "\n"
+	for _, name := range []string{"file", "size", "time"} {
+		if b, _ := ioutil.ReadFile(path + "/" + name + ".log.pid"); string(b) != pid {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	fh.Close()
+	rh.Close()
+	th.Close()
+
+	if names, _ := filepath.Glob(path + "/*.pid*"); len(names) != 0 {
+		t.Fatal(names)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestFileHandlerSyncPolicy(t *testing.T) {
+	var p syncPolicy
+
+	f, err := os.Create("./test_sync_policy.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("./test_sync_policy.log")
+
+	if err := p.afterWrite(f, 10, nil); err != nil || p.unsynced != 0 {
+		t.Fatal(err, p.unsynced)
+	}
+
+	p.set(time.Hour, 16)
+	p.afterWrite(f, 10, nil)
+	if p.unsynced != 10 {
+		t.Fatal(p.unsynced)
+	}
+	p.afterWrite(f, 10, nil)
+	if p.unsynced != 0 {
+		t.Fatal(p.unsynced)
+	}
+
+	//the sync error is returned after closed
+	f.Close()
+	p.set(time.Nanosecond, 0)
+	time.Sleep(time.Millisecond)
+	if err := p.afterWrite(f, 1, nil); err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+func TestLogIncludeSeq(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel)
+
+	l.SetIncludeSeq(true)
+	l.Info("a")
+	l.WithField("k", 1).Info("b")
+
+	l.SetIncludeGoroutineID(true)
+	l.SetFlags(Ljson)
+	l.Info("c")
+	l.Close()
+
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "seq=1 [Info] a" || lines[1] != "seq=2 [Info] b k=1" {
+		t.Fatal(lines)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &m); err != nil {
+		t.Fatal(err)
+	} else if m["seq"] != float64(3) || m["goid"] != float64(goroutineID()) {
+		t.Fatal(m)
+	}
+}
+
+func TestTimeRotatingFileLogHook(t *testing.T) {
+	path := "./test_log_hook"
+	os.RemoveAll(path)
+
+	baseName := path + "/test"
+	h, err := NewTimeRotatingFileHandler(baseName, WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCompress(true)
+
+	var rotated []string
+	h.SetRolloverHook(func(name string) error {
+		rotated = append(rotated, name)
+		return os.Remove(name)
+	})
+
+	h.Write([]byte("hello\n"))
+	h.rolloverAt = 0
+	h.Write([]byte("world\n"))
+	h.Close()
+
+	if len(rotated) != 1 || !strings.HasSuffix(rotated[0], ".gz") {
+		t.Fatal(rotated)
+	}
+	if _, err := os.Stat(rotated[0]); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogCloseHook(t *testing.T) {
+	path := "./test_log_close_hook"
+	os.RemoveAll(path)
+
+	h, err := NewTimeRotatingFileHandler(path+"/test", WhenDay, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	var hooks int32
+	h.SetRolloverHook(func(name string) error {
+		atomic.AddInt32(&hooks, 1)
+		<-release
+		return nil
+	})
+
+	h.Write([]byte("hello\n"))
+	h.Rotate()
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- h.Close()
+	}()
+
+	//closed before Close waits for the hook
+	for i := 0; ; i++ {
+		h.mu.Lock()
+		c := h.closed
+		h.rolloverAt = 0
+		h.mu.Unlock()
+		if c {
+			break
+		} else if i == 1000 {
+			t.Fatal("not closed while waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	//so a write is refused, not rolled over
+	err = misused(func() error {
+		_, err := h.Write([]byte("world\n"))
+		return err
+	})
+	if !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal(err)
+	}
+	close(release)
+
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&hooks); n != 1 {
+		t.Fatal(n)
+	}
+
+	os.RemoveAll(path)
+}
+
+func TestTimeRotatingFileLogRolloverAt(t *testing.T) {
+	path := "./test_log_rollover_at"
+	os.RemoveAll(path)
+
+	h, err := NewTimeRotatingFileHandler(path+"/test", WhenHour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.LastRollover().IsZero() {
+		t.Fatal(h.LastRollover())
+	}
+	if d := time.Until(h.RolloverAt()); d <= 59*time.Minute || d > time.Hour {
+		t.Fatal(h.RolloverAt())
+	}
+
+	h.rolloverAt = 0
+	h.Write([]byte("hello\n"))
+	if time.Since(h.LastRollover()) > time.Minute {
+		t.Fatal(h.LastRollover())
+	}
+
 	h.Close()
 
 	os.RemoveAll(path)
 }
+
+func TestLogStackLevel(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel)
+
+	l.SetStackLevel(LevelError)
+	l.Warn("a")
+	l.Error("b")
+
+	l.SetFlags(Ljson)
+	l.Error("c")
+	l.Close()
+
+	lines := strings.SplitN(buf.String(), "\n", 4)
+	if lines[0] != "[Warn] a" || lines[1] != "[Error] b stack=" {
+		t.Fatal(lines)
+	}
+	//the import path depends on where the package is built
+	if !strings.HasPrefix(lines[2], "\t") || !strings.Contains(lines[2], ".TestLogStackLevel ") {
+		t.Fatal(lines[2])
+	}
+
+	js := buf.String()[strings.LastIndex(strings.TrimSuffix(buf.String(), "\n"), "\n")+1:]
+	var m struct {
+		Stack []string
+	}
+	if err := json.Unmarshal([]byte(js), &m); err != nil {
+		t.Fatal(err)
+	} else if len(m.Stack) == 0 || !strings.Contains(m.Stack[0], "TestLogStackLevel") {
+		t.Fatal(m)
+	}
+}
+
+func TestLogPrefix(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel)
+
+	l.SetPrefix("[billing] ")
+	l.Info("a")
+
+	l.SetFlags(Llevel | Lmsgprefix)
+	l.Info("b")
+
+	l.SetFlags(Ljson)
+	l.Info("c")
+	l.Close()
+
+	if s := buf.String(); s != "[billing] [Info] a\n[Info] [billing] b\n"+`{"msg":"c","component":"[billing]"}`+"\n" {
+		t.Fatal(s)
+	}
+}
+
+func TestLogMaxLineBytes(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel)
+
+	l.SetMaxLineBytes(10)
+	l.Info("hi")
+	l.Info("hello world")
+	l.Info("heéllo")
+
+	l.SetFlags(Ljson)
+	l.WithField("body", "0123456789abc").WithField("n", 12345678901).Info("01234567890")
+	l.Close()
+
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "[Info] hi" {
+		t.Fatal(lines[0])
+	}
+	if lines[1] != "[Info] hel"+truncatedMark {
+		t.Fatal(lines[1])
+	}
+	//é is 2 bytes and not split
+	if lines[2] != "[Info] he"+truncatedMark {
+		t.Fatal(lines[2])
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[3]), &m); err != nil {
+		t.Fatal(err)
+	} else if m["msg"] != "0123456789"+truncatedMark || m["body"] != "0123456789"+truncatedMark || m["n"] != float64(12345678901) {
+		t.Fatal(m)
+	}
+}
+
+func TestLogClone(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Llevel).WithField("k", 1)
+
+	c := l.Clone()
+	c.SetLevel(LevelDebug)
+	c.SetPrefix("[child] ")
+	c.SetFlags(0)
+
+	l.Debug("a")
+	c.Debug("b")
+	l.Info("c")
+	l.Close()
+
+	if buf.String() != "[child] b k=1\n[Info] c k=1\n" {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//Metrics observes logging, e.g. to export counters to Prometheus or statsd
+//without this package depending on them. Set it with Logger.SetMetrics,
+//and AsyncHandler.SetMetrics for logs dropped by the queue.
+//
+//The methods are called in the writing goroutine while logging, so they
+//must be fast and must not log to the same logger.
+type Metrics interface {
+	//IncLevel is called for every log written to the handler, with its level.
+	IncLevel(level int)
+	//AddBytes is called with the size of every log written to the handler.
+	AddBytes(n int)
+	//IncDropped is called for every log the handler failed to write, or dropped.
+	IncDropped()
+	//ObserveFlush is called with the time a Sync or Flush of the handler took.
+	ObserveFlush(d time.Duration)
+}
+
+//FormatErrorMetrics is implemented by Metrics counting formatter failures,
+//IncFormatError is called in the logging goroutine for every log the Formatter
+//set by SetFormatter panicked on.
+type FormatErrorMetrics interface {
+	IncFormatError()
+}
+
+//HandlerPanicMetrics is implemented by Metrics counting handler panics,
+//IncHandlerPanic is called in the logging goroutine for every Write, Sync or Flush
+//of the handler which panicked and was recovered, see Logger.SetRecoverPanics,
+//and in the background goroutines of AsyncHandler, BufferedHandler and HookHandler.
+type HandlerPanicMetrics interface {
+	IncHandlerPanic()
+}
+
+//metricsValue holds a Metrics loaded atomically, by background goroutines which
+//must not take the lock of their handler. The zero value loads nopMetrics.
+type metricsValue struct {
+	v atomic.Value
+}
+
+func (m *metricsValue) store(x Metrics) {
+	if x == nil {
+		x = nopMetrics{}
+	}
+	m.v.Store(&x)
+}
+
+func (m *metricsValue) load() Metrics {
+	if x, _ := m.v.Load().(*Metrics); x != nil {
+		return *x
+	}
+	return nopMetrics{}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) IncLevel(level int)           {}
+func (nopMetrics) AddBytes(n int)               {}
+func (nopMetrics) IncDropped()                  {}
+func (nopMetrics) ObserveFlush(d time.Duration) {}
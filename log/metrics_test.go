@@ -0,0 +1,100 @@
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu      sync.Mutex
+	levels  map[int]int
+	bytes   int
+	dropped int
+	flushes int
+
+	formatErrors  int
+	handlerPanics int
+}
+
+func (m *testMetrics) IncLevel(level int) {
+	m.mu.Lock()
+	m.levels[level]++
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) AddBytes(n int) {
+	m.mu.Lock()
+	m.bytes += n
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) IncDropped() {
+	m.mu.Lock()
+	m.dropped++
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) ObserveFlush(d time.Duration) {
+	m.mu.Lock()
+	m.flushes++
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) IncFormatError() {
+	m.mu.Lock()
+	m.formatErrors++
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) IncHandlerPanic() {
+	m.mu.Lock()
+	m.handlerPanics++
+	m.mu.Unlock()
+}
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{levels: make(map[int]int)}
+
+	th, _ := NewTestHandler()
+	l := New(th, 0)
+	l.SetMetrics(m)
+
+	l.Info("a")
+	l.Info("bb")
+	l.Error("c")
+	l.Sync()
+
+	l.SetHandler(new(errHandler))
+	l.Warn("d")
+	l.Flush()
+
+	m.mu.Lock()
+	if m.levels[LevelInfo] != 2 || m.levels[LevelError] != 1 || m.levels[LevelWarn] != 0 {
+		t.Fatal(m.levels)
+	}
+	if m.bytes != 7 || m.dropped != 1 || m.flushes != 2 {
+		t.Fatal(m.bytes, m.dropped, m.flushes)
+	}
+	m.mu.Unlock()
+
+	l.SetMetrics(nil)
+	l.Close()
+
+	//the async queue drops
+	bh := &blockHandler{release: make(chan struct{})}
+	a, _ := NewAsyncHandler(bh, 1, AsyncDropNew)
+	a.SetMetrics(m)
+	for i := 0; i < 5; i++ {
+		a.Write([]byte("a\n"))
+	}
+
+	m.mu.Lock()
+	if int64(m.dropped-1) != a.Dropped() || a.Dropped() == 0 {
+		t.Fatal(m.dropped, a.Dropped())
+	}
+	m.mu.Unlock()
+
+	close(bh.release)
+	a.Close()
+}
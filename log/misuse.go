@@ -0,0 +1,12 @@
+// +build !logdebug
+
+package log
+
+//logDebug is true if built with -tags logdebug, see misuse.
+const logDebug = false
+
+//misuse returns err, the error of a misused handler, e.g. written after Close.
+//Built with -tags logdebug it panics instead, so tests catch lifecycle bugs.
+func misuse(err error) error {
+	return err
+}
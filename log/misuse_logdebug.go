@@ -0,0 +1,13 @@
+// +build logdebug
+
+package log
+
+import (
+	"fmt"
+)
+
+const logDebug = true
+
+func misuse(err error) error {
+	panic(fmt.Errorf("%w, misuse panics as built with -tags logdebug", err))
+}
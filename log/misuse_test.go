@@ -0,0 +1,29 @@
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+//misused returns the error of f, or the error it panics with if built with -tags logdebug.
+func misused(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !logDebug {
+				panic(r)
+			}
+			err = r.(error)
+		}
+	}()
+	return f()
+}
+
+func TestMisuseNilHandler(t *testing.T) {
+	err := misused(func() error {
+		_, err := writeLevel(nil, LevelInfo, []byte("a\n"))
+		return err
+	})
+	if !errors.Is(err, ErrNilHandler) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,224 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+//MultiError is the errors of the handlers of a MultiHandler failed in a call,
+//each one prefixed with the name of its handler. errors.Is and errors.As
+//check all of them.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+//add adds the error of handler h if err is not nil.
+func (e *MultiError) add(h Handler, err error) {
+	if err != nil {
+		e.Errors = append(e.Errors, fmt.Errorf("%s: %w", handlerName(h), err))
+	}
+}
+
+//err returns e, or nil if there are no errors.
+func (e *MultiError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+//MultiHandler writes logs to all its handlers in order.
+//
+//A failed handler does not stop writing to the others, the errors
+//of all failed handlers are returned as a *MultiError.
+//
+//Handlers can be added and removed while logging, e.g. a ChannelHandler
+//for a live viewer.
+//
+//It is safe for concurrent use if all its handlers are.
+type MultiHandler struct {
+	mu sync.RWMutex
+	hs []Handler
+}
+
+func NewMultiHandler(handlers ...Handler) (*MultiHandler, error) {
+	h := new(MultiHandler)
+
+	h.hs = handlers
+
+	return h, nil
+}
+
+func (h *MultiHandler) Write(p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		_, e := s.Write(p)
+		errs.add(s, e)
+	}
+
+	if err = errs.err(); err == nil {
+		n = len(p)
+	}
+	return
+}
+
+//WriteLevel writes p with level to all handlers, so level aware handlers get the level.
+func (h *MultiHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		_, e := writeLevel(s, level, p)
+		errs.add(s, e)
+	}
+
+	if err = errs.err(); err == nil {
+		n = len(p)
+	}
+	return
+}
+
+//WriteTime writes p with level and t to all handlers, see TimeWriter.
+func (h *MultiHandler) WriteTime(level int, t time.Time, p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		_, e := writeTime(s, level, t, p)
+		errs.add(s, e)
+	}
+
+	if err = errs.err(); err == nil {
+		n = len(p)
+	}
+	return
+}
+
+//HandleRecord passes r to all handlers, see RecordHandler.
+func (h *MultiHandler) HandleRecord(r *Record) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		errs.add(s, handleRecord(s, r))
+	}
+	return errs.err()
+}
+
+//Sync syncs all handlers implementing Syncer, the errors are returned as a *MultiError.
+func (h *MultiHandler) Sync() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		errs.add(s, syncHandler(s))
+	}
+	return errs.err()
+}
+
+//Check checks all handlers, a MultiError is returned if some fail.
+func (h *MultiHandler) Check() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		errs.add(s, checkHandler(s))
+	}
+	return errs.err()
+}
+
+//Flush flushes all handlers implementing Flusher, the errors are returned as a *MultiError.
+func (h *MultiHandler) Flush() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		errs.add(s, flushHandler(s))
+	}
+	return errs.err()
+}
+
+//Handlers returns a copy of the handlers, in order.
+func (h *MultiHandler) Handlers() []Handler {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]Handler(nil), h.hs...)
+}
+
+//Add adds s after the other handlers, logs written from now on go to it too.
+func (h *MultiHandler) Add(s Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hs = append(h.hs[:len(h.hs):len(h.hs)], s)
+}
+
+//Remove removes s, and closes it if close is set, a write in progress
+//finishes before. It returns an error if s is not a handler of h.
+//A handler of an uncomparable type, e.g. a map, can not be found, add a pointer instead.
+func (h *MultiHandler) Remove(s Handler, close bool) error {
+	h.mu.Lock()
+
+	i := 0
+	for ; i < len(h.hs) && !sameHandler(h.hs[i], s); i++ {
+	}
+	if i == len(h.hs) {
+		h.mu.Unlock()
+		return fmt.Errorf("%s is not in the multi handler", handlerName(s))
+	}
+
+	hs := make([]Handler, 0, len(h.hs)-1)
+	hs = append(hs, h.hs[:i]...)
+	h.hs = append(hs, h.hs[i+1:]...)
+	h.mu.Unlock()
+
+	if close {
+		return s.Close()
+	}
+	return nil
+}
+
+//sameHandler reports whether a and b are the same handler, never if they are
+//uncomparable, as comparing them panics.
+func sameHandler(a Handler, b Handler) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.ValueOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func (h *MultiHandler) Close() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var errs MultiError
+	for _, s := range h.hs {
+		errs.add(s, s.Close())
+	}
+	return errs.err()
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type errHandler struct {
+}
+
+func (h *errHandler) Write(p []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func (h *errHandler) Close() error {
+	return nil
+}
+
+func TestMultiHandler(t *testing.T) {
+	var b1, b2 bytes.Buffer
+	h1, _ := NewStreamHandler(&b1)
+	h2, _ := NewStreamHandler(&b2)
+
+	h, _ := NewMultiHandler(h1, new(errHandler), h2)
+
+	if _, err := h.Write([]byte("hello")); err == nil {
+		t.Fatal("must fail")
+	} else if err.Error() != "*log.errHandler: write error" {
+		t.Fatal(err)
+	}
+
+	if b1.String() != "hello" || b2.String() != "hello" {
+		t.Fatal(b1.String(), b2.String())
+	}
+
+	h.Close()
+}
+
+func TestMultiHandlerMultiError(t *testing.T) {
+	var buf bytes.Buffer
+	bh, _ := NewStreamHandler(&buf)
+
+	errFull := &os.PathError{Op: "write", Path: "test", Err: syscall.ENOSPC}
+	h, _ := NewMultiHandler(new(errHandler), bh, &diskFullHandler{full: 1})
+
+	_, err := h.Write([]byte("hello"))
+
+	var me *MultiError
+	if !errors.As(err, &me) || len(me.Errors) != 2 {
+		t.Fatal(err)
+	}
+	if err.Error() != "*log.errHandler: write error; *log.diskFullHandler: "+errFull.Error() {
+		t.Fatal(err)
+	}
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatal("must unwrap all errors")
+	}
+
+	var pe *os.PathError
+	if !errors.As(err, &pe) || pe.Path != "test" {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Write([]byte("hello")); err == nil || len(err.(*MultiError).Errors) != 1 {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//mapHandler counts the logs written, it is of an uncomparable type.
+type mapHandler map[string]int
+
+func (h mapHandler) Write(p []byte) (int, error) {
+	h[string(p)]++
+	return len(p), nil
+}
+
+func (h mapHandler) Close() error {
+	return nil
+}
+
+func TestMultiHandlerRemoveUncomparable(t *testing.T) {
+	m := make(mapHandler)
+	h, _ := NewMultiHandler(m)
+
+	//the handlers can not be compared, but must not panic
+	if err := h.Remove(make(mapHandler), false); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h.Write([]byte("a\n"))
+	if m["a\n"] != 1 {
+		t.Fatal(m)
+	}
+}
+
+func TestMultiHandlerAddRemove(t *testing.T) {
+	h1, b1 := NewTestHandler()
+	h, _ := NewMultiHandler(h1)
+
+	c, _ := NewChannelHandler(4)
+	ch := c.Subscribe()
+	h.Add(c)
+
+	if hs := h.Handlers(); len(hs) != 2 || hs[1] != c {
+		t.Fatal(hs)
+	}
+
+	h.Write([]byte("a\n"))
+	if s := string(<-ch); s != "a\n" {
+		t.Fatal(s)
+	}
+
+	if err := h.Remove(c, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("must be closed")
+	}
+	if err := h.Remove(c, true); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h.Write([]byte("b\n"))
+	if hs := h.Handlers(); len(hs) != 1 {
+		t.Fatal(hs)
+	}
+	if b1.String() != "a\nb\n" {
+		t.Fatal(b1.String())
+	}
+
+	h.Close()
+}
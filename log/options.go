@@ -0,0 +1,148 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+//options are collected by Option functions and wired by NewLogger.
+type options struct {
+	file     string
+	rotate   bool
+	when     int8
+	interval int
+
+	streams  []io.Writer
+	handlers []Handler
+	multi    bool
+
+	level int
+	flag  int
+	json  bool
+}
+
+//Option configures a Logger created by NewLogger.
+type Option func(o *options)
+
+//WithFile logs to fileName, appending to it.
+func WithFile(fileName string) Option {
+	return func(o *options) {
+		o.file = fileName
+	}
+}
+
+//WithRotation rotates the file set by WithFile with a TimeRotatingFileHandler.
+func WithRotation(when int8, interval int) Option {
+	return func(o *options) {
+		o.rotate = true
+		o.when = when
+		o.interval = interval
+	}
+}
+
+//WithStream logs to w.
+func WithStream(w io.Writer) Option {
+	return func(o *options) {
+		o.streams = append(o.streams, w)
+	}
+}
+
+//WithHandler logs to h.
+func WithHandler(h Handler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, h)
+	}
+}
+
+//WithMulti allows many outputs, e.g. WithFile and WithStream, combined with a MultiHandler,
+//else more than one output is an error.
+func WithMulti() Option {
+	return func(o *options) {
+		o.multi = true
+	}
+}
+
+//WithLevel sets the log level, default is LevelInfo.
+func WithLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+//WithFlags sets the logger flags, default is Ltime|Lfile|Llevel.
+func WithFlags(flag int) Option {
+	return func(o *options) {
+		o.flag = flag
+	}
+}
+
+//WithJSON formats logs as json objects, adding Ljson to the flags.
+func WithJSON() Option {
+	return func(o *options) {
+		o.json = true
+	}
+}
+
+//NewLogger creates a Logger wired by opts, e.g.
+//
+//  l, err := log.NewLogger(log.WithFile("app.log"), log.WithRotation(log.WhenDay, 1), log.WithJSON())
+//
+//It logs to stdout if no output is set. Conflicting options, like WithRotation
+//without WithFile, or many outputs without WithMulti, return an error.
+func NewLogger(opts ...Option) (*Logger, error) {
+	o := options{level: LevelInfo, flag: Ltime | Lfile | Llevel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.rotate && len(o.file) == 0 {
+		return nil, fmt.Errorf("rotation without a file")
+	}
+
+	outputs := len(o.streams) + len(o.handlers)
+	if len(o.file) > 0 {
+		outputs++
+	}
+	if outputs > 1 && !o.multi {
+		return nil, fmt.Errorf("%d outputs without a multi handler", outputs)
+	}
+
+	hs := append([]Handler(nil), o.handlers...)
+	for _, w := range o.streams {
+		h, _ := NewStreamHandler(w)
+		hs = append(hs, h)
+	}
+
+	if len(o.file) > 0 {
+		var h Handler
+		var err error
+		if o.rotate {
+			h, err = NewTimeRotatingFileHandler(o.file, o.when, o.interval)
+		} else {
+			h, err = NewFileHandler(o.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+		}
+		if err != nil {
+			return nil, err
+		}
+		hs = append(hs, h)
+	}
+
+	var h Handler
+	switch len(hs) {
+	case 0:
+		h = newStdHandler()
+	case 1:
+		h = hs[0]
+	default:
+		h, _ = NewMultiHandler(hs...)
+	}
+
+	if o.json {
+		o.flag |= Ljson
+	}
+
+	l := New(h, o.flag)
+	l.SetLevel(o.level)
+	return l, nil
+}
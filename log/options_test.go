@@ -0,0 +1,43 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	path := "./test_log_options"
+	os.RemoveAll(path)
+
+	var buf bytes.Buffer
+	l, err := NewLogger(WithFile(path+"/app.log"), WithRotation(WhenDay, 1), WithStream(&buf),
+		WithMulti(), WithLevel(LevelWarn), WithFlags(Llevel), WithJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info("dropped")
+	l.Warn("hello")
+	l.Close()
+
+	s := `{"level":"Warn","msg":"hello"}` + "\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+	if b, err := ioutil.ReadFile(path + "/app.log"); err != nil {
+		t.Fatal(err)
+	} else if string(b) != s {
+		t.Fatal(string(b))
+	}
+
+	if _, err := NewLogger(WithRotation(WhenDay, 1)); err == nil {
+		t.Fatal("must fail without a file")
+	}
+	if _, err := NewLogger(WithFile(path+"/app.log"), WithStream(&buf)); err == nil {
+		t.Fatal("must fail without multi")
+	}
+
+	os.RemoveAll(path)
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+//pipelineFlushInterval is how often a Buffered stage of a pipeline is flushed.
+const pipelineFlushInterval = time.Second
+
+//stage wraps the handler of the next stage.
+type stage struct {
+	name string
+	wrap func(h Handler) (Handler, error)
+}
+
+//PipelineBuilder declares a chain of handlers, see Pipeline.
+type PipelineBuilder struct {
+	stages []stage
+	err    error
+}
+
+//Pipeline starts a chain of handlers, each stage writes to the next one and the
+//chain ends with the handler written to at last, e.g.
+//
+//  h, err := log.Pipeline().Async(1000).Buffered(64 * 1024).Gzip(6).File("app.log")
+//
+//Closing the returned handler closes every stage, in order.
+//A stage after Gzip gets compressed bytes instead of logs, so only Buffered
+//is allowed there, and Gzip only once. An illegal chain returns an error
+//from the last call.
+func Pipeline() *PipelineBuilder {
+	return new(PipelineBuilder)
+}
+
+func (b *PipelineBuilder) add(name string, wrap func(h Handler) (Handler, error)) *PipelineBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	for _, s := range b.stages {
+		if s.name == "gzip" && name != "buffered" {
+			b.err = fmt.Errorf("pipeline %s stage after gzip", name)
+			return b
+		}
+	}
+
+	b.stages = append(b.stages, stage{name, wrap})
+	return b
+}
+
+//Async queues logs with an AsyncHandler of size logs, blocking when it is full.
+func (b *PipelineBuilder) Async(size int) *PipelineBuilder {
+	return b.add("async", func(h Handler) (Handler, error) {
+		return NewAsyncHandler(h, size, AsyncBlock)
+	})
+}
+
+//Buffered buffers size bytes with a BufferedHandler, flushed every second.
+func (b *PipelineBuilder) Buffered(size int) *PipelineBuilder {
+	return b.add("buffered", func(h Handler) (Handler, error) {
+		return NewBufferedHandler(h, size, pipelineFlushInterval)
+	})
+}
+
+//Gzip compresses with a GzipHandler at level, an invalid level fails before
+//any handler is created, see NewGzipHandler.
+func (b *PipelineBuilder) Gzip(level int) *PipelineBuilder {
+	if err := checkGzipLevel(level); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b.add("gzip", func(h Handler) (Handler, error) {
+		return NewGzipHandler(h, level)
+	})
+}
+
+//File ends the chain with a FileHandler appending to fileName.
+func (b *PipelineBuilder) File(fileName string) (Handler, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	h, err := NewFileHandler(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		return nil, err
+	}
+	return b.Handler(h)
+}
+
+//Stream ends the chain with a StreamHandler writing to w.
+func (b *PipelineBuilder) Stream(w io.Writer) (Handler, error) {
+	h, _ := NewStreamHandler(w)
+	return b.Handler(h)
+}
+
+//Handler ends the chain with h, h is closed if a stage can not be created.
+func (b *PipelineBuilder) Handler(h Handler) (Handler, error) {
+	if b.err != nil {
+		h.Close()
+		return nil, b.err
+	}
+
+	for i := len(b.stages) - 1; i >= 0; i-- {
+		n, err := b.stages[i].wrap(h)
+		if err != nil {
+			h.Close()
+			return nil, fmt.Errorf("pipeline %s stage: %w", b.stages[i].name, err)
+		}
+		h = n
+	}
+	return h, nil
+}
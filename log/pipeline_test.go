@@ -0,0 +1,68 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "app.log.gz")
+	h, err := Pipeline().Async(10).Buffered(1024).Gzip(gzip.BestSpeed).File(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, 0)
+	l.Info("hello")
+	l.Info("world")
+	l.Close()
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "hello\nworld\n" {
+		t.Fatal(string(b))
+	}
+}
+
+func TestPipelineInvalid(t *testing.T) {
+	if _, err := Pipeline().Gzip(1).Async(10).Stream(ioutil.Discard); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := Pipeline().Gzip(1).Gzip(1).Stream(ioutil.Discard); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := Pipeline().Async(0).Stream(ioutil.Discard); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := Pipeline().Gzip(10).File("./test_pipeline_gzip"); err == nil {
+		t.Fatal("must error")
+	} else if _, err := os.Stat("./test_pipeline_gzip"); err == nil {
+		t.Fatal("must not create the file")
+	}
+
+	//buffering compressed bytes is fine
+	h, err := Pipeline().Gzip(1).Buffered(1024).Stream(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+}
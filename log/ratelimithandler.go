@@ -0,0 +1,117 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//RateLimitHandler caps the logs per second written to another handler
+//with a token bucket, logs over the limit are dropped and counted.
+//
+//If notice is enabled, a single "rate limited" line is written when the limit trips,
+//and again only after logs have been let through since.
+//
+//It is safe for concurrent use, the lock only guards the bucket arithmetic.
+type RateLimitHandler struct {
+	h Handler
+
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	limited bool
+	notice  bool
+
+	dropped int64
+}
+
+//NewRateLimitHandler creates a RateLimitHandler allowing perSecond logs per second
+//written to h, with bursts of at most burst logs.
+func NewRateLimitHandler(h Handler, perSecond int, burst int, notice bool) (*RateLimitHandler, error) {
+	if perSecond <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d", perSecond)
+	}
+
+	if burst <= 0 {
+		return nil, fmt.Errorf("invalid rate limit burst %d", burst)
+	}
+
+	r := new(RateLimitHandler)
+
+	r.h = h
+	r.rate = float64(perSecond)
+	r.burst = float64(burst)
+	r.tokens = r.burst
+	r.last = time.Now()
+	r.notice = notice
+
+	return r, nil
+}
+
+//allow takes a token, notify is true if the limit just tripped.
+func (h *RateLimitHandler) allow() (ok bool, notify bool) {
+	now := time.Now()
+
+	h.mu.Lock()
+	h.tokens += now.Sub(h.last).Seconds() * h.rate
+	if h.tokens > h.burst {
+		h.tokens = h.burst
+	}
+	h.last = now
+
+	if h.tokens >= 1 {
+		h.tokens--
+		h.limited = false
+		ok = true
+	} else if !h.limited {
+		h.limited = true
+		notify = h.notice
+	}
+	h.mu.Unlock()
+	return
+}
+
+func (h *RateLimitHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *RateLimitHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	ok, notify := h.allow()
+	if ok {
+		return writeLevel(h.h, level, p)
+	}
+
+	atomic.AddInt64(&h.dropped, 1)
+
+	if notify {
+		writeLevel(h.h, LevelWarn, []byte("rate limited, dropping logs\n"))
+	}
+	return len(p), nil
+}
+
+//Dropped returns the number of logs dropped because of the rate limit.
+func (h *RateLimitHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+//Sync syncs the wrapped handler.
+func (h *RateLimitHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *RateLimitHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *RateLimitHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *RateLimitHandler) Close() error {
+	return h.h.Close()
+}
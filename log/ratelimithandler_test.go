@@ -0,0 +1,37 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	h, err := NewRateLimitHandler(th, 1, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.Write([]byte("hello\n"))
+	}
+	h.Close()
+
+	if s := buf.String(); s != strings.Repeat("hello\n", 3)+"rate limited, dropping logs\n" {
+		t.Fatal(s)
+	}
+
+	if n := h.Dropped(); n != 7 {
+		t.Fatal(n)
+	}
+}
+
+func TestRateLimitHandlerInvalid(t *testing.T) {
+	if _, err := NewRateLimitHandler(DiscardHandler(), 0, 1, false); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := NewRateLimitHandler(DiscardHandler(), 1, 0, false); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"time"
+)
+
+//Record is a log as logged, with its formatted line, passed to handlers
+//implementing RecordHandler, so they can use the level, message and fields
+//without parsing the line.
+type Record struct {
+	Level int
+	Time  time.Time
+	//Msg is the message without the prefix set by SetPrefix
+	Msg    string
+	Fields map[string]interface{}
+
+	//Line is the formatted log, it is reused after HandleRecord returns,
+	//a handler must copy it to retain it, like p of Write.
+	Line []byte
+}
+
+//RecordHandler is implemented by handlers using the structure of logs, e.g. to
+//route them by a field, the Logger calls HandleRecord instead of WriteTime.
+//A wrapping handler implementing it passes the record on, so structure survives
+//the chain, other handlers get the formatted line with WriteTime, WriteLevel or Write.
+//
+//The fields must not be modified, they are shared by logs.
+type RecordHandler interface {
+	HandleRecord(r *Record) error
+}
+
+//handleRecord passes r to h if it implements RecordHandler, else writes the line.
+func handleRecord(h Handler, r *Record) error {
+	if rh, ok := h.(RecordHandler); ok {
+		return rh.HandleRecord(r)
+	}
+	_, err := writeTime(h, r.Level, r.Time, r.Line)
+	return err
+}
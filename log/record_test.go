@@ -0,0 +1,64 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+//recordHandler keeps the records passed to HandleRecord.
+type recordHandler struct {
+	*TestHandler
+	records []Record
+}
+
+func (h *recordHandler) HandleRecord(r *Record) error {
+	rec := *r
+	rec.Line = append([]byte(nil), r.Line...)
+	h.records = append(h.records, rec)
+	return nil
+}
+
+func TestRecordHandler(t *testing.T) {
+	th, _ := NewTestHandler()
+	rh := &recordHandler{TestHandler: th}
+
+	router, _ := NewLevelRouterHandler()
+	router.AddRoute(LevelWarn, rh)
+	h, _ := NewMultiHandler(router)
+
+	l := New(h, Llevel)
+	l.SetPrefix("[p] ")
+	l.Info("skipped")
+	l.WithField("k", "a b").Warn("hello")
+	l.Close()
+
+	if len(rh.records) != 1 {
+		t.Fatal(rh.records)
+	}
+	r := rh.records[0]
+	if r.Level != LevelWarn || r.Msg != "hello" || r.Fields["k"] != "a b" || r.Time.IsZero() {
+		t.Fatal(r)
+	}
+	if string(r.Line) != "[p] [Warn] hello k=a b\n" {
+		t.Fatal(string(r.Line))
+	}
+}
+
+func TestSplitHandlerRecord(t *testing.T) {
+	bufs := make(map[string]*bytes.Buffer)
+	def, _ := NewTestHandler()
+
+	h, _ := NewSplitHandler("tenant", func(value string) (Handler, error) {
+		bufs[value] = new(bytes.Buffer)
+		return NewStreamHandler(bufs[value])
+	}, def)
+
+	//a value with a space can not be parsed from the line
+	l := New(h, 0)
+	l.WithField("tenant", "a b").Info("1")
+	l.Close()
+
+	if b := bufs["a b"]; b == nil || b.String() != "1 tenant=a b\n" {
+		t.Fatal(bufs)
+	}
+}
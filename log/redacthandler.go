@@ -0,0 +1,99 @@
+package log
+
+import (
+	"bytes"
+	"regexp"
+)
+
+//built-in patterns for RedactHandler
+var (
+	RedactEmail      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	RedactBearer     = regexp.MustCompile(`[Bb]earer [A-Za-z0-9._~+/-]+=*`)
+	RedactCardNumber = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+//redactHints are substrings the built-in patterns can not match without,
+//so a log without them skips the regexp.
+var redactHints = map[*regexp.Regexp]string{
+	RedactEmail:  "@",
+	RedactBearer: "earer ",
+}
+
+type redactPattern struct {
+	re   *regexp.Regexp
+	hint []byte
+}
+
+//RedactHandler replaces the matches of patterns in logs, e.g. tokens or emails,
+//before writing them to another handler.
+//
+//A pattern is only run on logs holding its literal prefix, or a substring
+//it always matches for the built-in ones, so most logs are written as is
+//without a copy.
+//
+//It is safe for concurrent use if the wrapped handler is.
+type RedactHandler struct {
+	h Handler
+
+	patterns    []redactPattern
+	replacement []byte
+}
+
+//NewRedactHandler creates a RedactHandler replacing matches of patterns with replacement,
+//e.g. NewRedactHandler(h, []*regexp.Regexp{RedactEmail, RedactBearer}, "[REDACTED]").
+func NewRedactHandler(h Handler, patterns []*regexp.Regexp, replacement string) (*RedactHandler, error) {
+	r := new(RedactHandler)
+
+	r.h = h
+	r.replacement = []byte(replacement)
+
+	for _, re := range patterns {
+		hint, ok := redactHints[re]
+		if !ok {
+			hint, _ = re.LiteralPrefix()
+		}
+		r.patterns = append(r.patterns, redactPattern{re, []byte(hint)})
+	}
+
+	return r, nil
+}
+
+func (h *RedactHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p with the matches replaced, n is len(p) if the write succeeds.
+func (h *RedactHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	b := p
+	for _, r := range h.patterns {
+		if len(r.hint) > 0 && !bytes.Contains(b, r.hint) {
+			continue
+		}
+		//ReplaceAllLiteral returns a copy, so p is never modified
+		b = r.re.ReplaceAllLiteral(b, h.replacement)
+	}
+
+	if _, err = writeLevel(h.h, level, b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Sync syncs the wrapped handler.
+func (h *RedactHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *RedactHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler.
+func (h *RedactHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+func (h *RedactHandler) Close() error {
+	return h.h.Close()
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	th, buf := NewTestHandler()
+	h, _ := NewRedactHandler(th, []*regexp.Regexp{RedactEmail, RedactBearer, RedactCardNumber,
+		regexp.MustCompile(`password=\S+`)}, "[REDACTED]")
+
+	l := New(h, 0)
+	l.Info("user a.b@example.com logged in")
+	l.Info("Authorization: Bearer abc.DEF-123==")
+	l.Info("card 4111 1111 1111 1111 charged")
+	l.Info("login password=hunter2 ok")
+	l.Info("nothing to hide $1")
+	l.Close()
+
+	s := "user [REDACTED] logged in\n" +
+		"Authorization: [REDACTED]\n" +
+		"card [REDACTED] charged\n" +
+		"login [REDACTED] ok\n" +
+		"nothing to hide $1\n"
+	if buf.String() != s {
+		t.Fatal(buf.String())
+	}
+}
+
+func BenchmarkRedactHandlerNoMatch(b *testing.B) {
+	h, _ := NewRedactHandler(DiscardHandler(), []*regexp.Regexp{RedactEmail, RedactBearer}, "[REDACTED]")
+	p := []byte("[2014/06/01 10:00:00] main.go:12 [Info] hello world, this is a log line\n")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Write(p)
+	}
+}
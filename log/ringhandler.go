@@ -0,0 +1,125 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+//RingBufferHandler keeps the last lines of logs in memory,
+//and writes logs to an optional wrapped handler too.
+//
+//It is safe for concurrent use.
+type RingBufferHandler struct {
+	mu sync.Mutex
+
+	h Handler
+
+	lines      []string
+	next       int
+	full       bool
+	terminator []byte
+}
+
+//NewRingBufferHandler creates a RingBufferHandler keeping the last size lines,
+//h may be nil if logs need not be written to elsewhere.
+func NewRingBufferHandler(size int, h Handler) (*RingBufferHandler, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid ring buffer size %d", size)
+	}
+
+	r := new(RingBufferHandler)
+
+	r.h = h
+	r.lines = make([]string, size)
+	r.terminator = []byte{'\n'}
+
+	return r, nil
+}
+
+func (h *RingBufferHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel keeps the lines of p, and writes p to the wrapped handler with level.
+func (h *RingBufferHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	h.mu.Lock()
+	for b := p; len(b) > 0; {
+		line := b
+		if i := bytes.Index(b, h.terminator); i >= 0 {
+			line, b = b[0:i], b[i+len(h.terminator):]
+		} else {
+			b = nil
+		}
+
+		h.lines[h.next] = string(line)
+		h.next++
+		if h.next == len(h.lines) {
+			h.next = 0
+			h.full = true
+		}
+	}
+	h.mu.Unlock()
+
+	if h.h != nil {
+		return writeLevel(h.h, level, p)
+	}
+	return len(p), nil
+}
+
+//SetTerminator sets the terminator lines are split on, default is "\n",
+//it must be the one set with Logger.SetTerminator. An empty s is ignored.
+func (h *RingBufferHandler) SetTerminator(s string) {
+	if s == "" {
+		return
+	}
+
+	h.mu.Lock()
+	h.terminator = []byte(s)
+	h.mu.Unlock()
+}
+
+//Lines returns a snapshot of the kept lines, oldest first.
+func (h *RingBufferHandler) Lines() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]string(nil), h.lines[0:h.next]...)
+	}
+
+	lines := make([]string, 0, len(h.lines))
+	lines = append(lines, h.lines[h.next:]...)
+	return append(lines, h.lines[0:h.next]...)
+}
+
+//Flush flushes the wrapped handler if any.
+func (h *RingBufferHandler) Flush() error {
+	if h.h != nil {
+		return flushHandler(h.h)
+	}
+	return nil
+}
+
+//Sync syncs the wrapped handler if any.
+func (h *RingBufferHandler) Sync() error {
+	if h.h != nil {
+		return syncHandler(h.h)
+	}
+	return nil
+}
+
+//Check checks the wrapped handler if any, see HealthChecker.
+func (h *RingBufferHandler) Check() error {
+	if h.h != nil {
+		return checkHandler(h.h)
+	}
+	return nil
+}
+
+func (h *RingBufferHandler) Close() error {
+	if h.h != nil {
+		return h.h.Close()
+	}
+	return nil
+}
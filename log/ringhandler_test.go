@@ -0,0 +1,73 @@
+package log
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRingBufferHandler(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewRingBufferHandler(3, s)
+
+	h.Write([]byte("1\n2\n"))
+	if lines := h.Lines(); !reflect.DeepEqual(lines, []string{"1", "2"}) {
+		t.Fatal(lines)
+	}
+
+	h.Write([]byte("3\n"))
+	h.Write([]byte("4\n5\n"))
+	if lines := h.Lines(); !reflect.DeepEqual(lines, []string{"3", "4", "5"}) {
+		t.Fatal(lines)
+	}
+
+	if buf.String() != "1\n2\n3\n4\n5\n" {
+		t.Fatal(buf.String())
+	}
+
+	h.Close()
+}
+
+func TestRingBufferHandlerTerminator(t *testing.T) {
+	h, _ := NewRingBufferHandler(3, nil)
+	h.SetTerminator("\x00")
+
+	l := New(h, Llevel|Ljson)
+	l.SetTerminator("\x00")
+	l.Info("hello\nworld")
+	l.Info("again")
+	l.Close()
+
+	lines := h.Lines()
+	if !reflect.DeepEqual(lines, []string{`{"level":"Info","msg":"hello\nworld"}`, `{"level":"Info","msg":"again"}`}) {
+		t.Fatal(lines)
+	}
+}
+
+func TestRingBufferHandlerWrapped(t *testing.T) {
+	var buf bytes.Buffer
+	s := new(syncCountHandler)
+	s.w = &buf
+
+	var hooked []string
+	hh, _ := NewHookHandler(s, LevelError, func(level int, p []byte) {
+		hooked = append(hooked, string(p))
+	}, false)
+
+	h, _ := NewRingBufferHandler(3, hh)
+	l := New(h, 0)
+	l.Info("info")
+	l.Fatal("fatal")
+
+	//the level reaches the hook, and the fatal log is synced through the ring
+	if !reflect.DeepEqual(hooked, []string{"fatal\n"}) || s.syncs != 1 {
+		t.Fatal(hooked, s.syncs)
+	}
+
+	if lines := h.Lines(); !reflect.DeepEqual(lines, []string{"info", "fatal"}) {
+		t.Fatal(lines)
+	}
+	l.Close()
+}
@@ -0,0 +1,187 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+type levelRoute struct {
+	minLevel int
+	maxLevel int
+	h        Handler
+}
+
+//LevelRouterHandler writes logs to different handlers by level.
+//
+//A log is written to every route its level matches, so errors can go to
+//an error file and be copied to the main log too.
+//A Write without level, e.g. not from a Logger, is routed as LevelInfo.
+//
+//Each route owns its handler and its state, e.g. error.log and app.log routed to
+//two TimeRotatingFileHandlers rotate independently, each by its own rollover time,
+//and a route handler only rolls on a write routed to it.
+//
+//It is safe for concurrent use if all its handlers are.
+type LevelRouterHandler struct {
+	mu     sync.RWMutex
+	routes []levelRoute
+}
+
+func NewLevelRouterHandler() (*LevelRouterHandler, error) {
+	return new(LevelRouterHandler), nil
+}
+
+//AddRoute routes logs with level >= minLevel to handler.
+func (h *LevelRouterHandler) AddRoute(minLevel int, handler Handler) {
+	h.AddRangeRoute(minLevel, LevelFatal, handler)
+}
+
+//AddRangeRoute routes logs with level in [minLevel, maxLevel] to handler.
+func (h *LevelRouterHandler) AddRangeRoute(minLevel int, maxLevel int, handler Handler) {
+	h.mu.Lock()
+	h.routes = append(h.routes, levelRoute{minLevel, maxLevel, handler})
+	h.mu.Unlock()
+}
+
+func (h *LevelRouterHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p to all matched routes, the first error is returned.
+func (h *LevelRouterHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, r := range h.routes {
+		if level < r.minLevel || level > r.maxLevel {
+			continue
+		}
+
+		if _, e := writeLevel(r.h, level, p); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if err == nil {
+		n = len(p)
+	}
+	return
+}
+
+//WriteTime writes p with level and t to all matched routes, see TimeWriter.
+func (h *LevelRouterHandler) WriteTime(level int, t time.Time, p []byte) (n int, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, r := range h.routes {
+		if level < r.minLevel || level > r.maxLevel {
+			continue
+		}
+
+		if _, e := writeTime(r.h, level, t, p); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if err == nil {
+		n = len(p)
+	}
+	return
+}
+
+//HandleRecord passes r to all matched routes, see RecordHandler, the first error is returned.
+func (h *LevelRouterHandler) HandleRecord(r *Record) (err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, route := range h.routes {
+		if r.Level < route.minLevel || r.Level > route.maxLevel {
+			continue
+		}
+
+		if e := handleRecord(route.h, r); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Sync syncs all route handlers, a handler used by many routes is synced once.
+func (h *LevelRouterHandler) Sync() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var err error
+	synced := make(map[Handler]bool, len(h.routes))
+	for _, r := range h.routes {
+		if synced[r.h] {
+			continue
+		}
+		synced[r.h] = true
+
+		if e := syncHandler(r.h); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Check checks all route handlers, a handler used by many routes is checked once.
+func (h *LevelRouterHandler) Check() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var err error
+	checked := make(map[Handler]bool, len(h.routes))
+	for _, r := range h.routes {
+		if checked[r.h] {
+			continue
+		}
+		checked[r.h] = true
+
+		if e := checkHandler(r.h); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Flush flushes all route handlers, a handler used by many routes is flushed once.
+func (h *LevelRouterHandler) Flush() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var err error
+	flushed := make(map[Handler]bool, len(h.routes))
+	for _, r := range h.routes {
+		if flushed[r.h] {
+			continue
+		}
+		flushed[r.h] = true
+
+		if e := flushHandler(r.h); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Close closes all route handlers, a handler used by many routes is closed once.
+func (h *LevelRouterHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	closed := make(map[Handler]bool, len(h.routes))
+	for _, r := range h.routes {
+		if closed[r.h] {
+			continue
+		}
+		closed[r.h] = true
+
+		if e := r.h.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
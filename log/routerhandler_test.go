@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLevelRouterHandler(t *testing.T) {
+	var main, errs bytes.Buffer
+	mh, _ := NewStreamHandler(&main)
+	eh, _ := NewStreamHandler(&errs)
+
+	h, _ := NewLevelRouterHandler()
+	h.AddRoute(LevelError, eh)
+	h.AddRoute(LevelTrace, mh)
+
+	l := New(h, Llevel)
+	l.Info("hello")
+	l.Error("failed")
+	l.Close()
+
+	if main.String() != "[Info] hello\n[Error] failed\n" {
+		t.Fatal(main.String())
+	}
+
+	if errs.String() != "[Error] failed\n" {
+		t.Fatal(errs.String())
+	}
+}
+
+func TestLevelRouterHandlerRotation(t *testing.T) {
+	path := "./test_log_router"
+	os.RemoveAll(path)
+	os.Mkdir(path, 0777)
+
+	c := &fakeClock{t: time.Date(2014, 6, 1, 23, 59, 0, 0, time.Local)}
+
+	app, err := NewTimeRotatingFileHandler(path+"/app.log", WhenMidnight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.setClock(c)
+
+	errs, err := NewTimeRotatingFileHandler(path+"/error.log", WhenMidnight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs.setClock(c)
+
+	h, _ := NewLevelRouterHandler()
+	h.AddRangeRoute(LevelTrace, LevelWarn, app)
+	h.AddRoute(LevelError, errs)
+
+	//written directly, a Logger rolls with the time of logs, not the fake clock
+	h.WriteLevel(LevelInfo, []byte("[Info] day1\n"))
+	h.WriteLevel(LevelError, []byte("[Error] day1 failed\n"))
+
+	c.Advance(2 * time.Minute)
+	h.WriteLevel(LevelInfo, []byte("[Info] day2\n"))
+	h.WriteLevel(LevelError, []byte("[Error] day2 failed\n"))
+	h.Close()
+
+	files := map[string]string{
+		"app.log2014-06-02":   "[Info] day1\n",
+		"app.log":             "[Info] day2\n",
+		"error.log2014-06-02": "[Error] day1 failed\n",
+		"error.log":           "[Error] day2 failed\n",
+	}
+	for name, s := range files {
+		if b, err := ioutil.ReadFile(path + "/" + name); err != nil {
+			t.Fatal(err)
+		} else if string(b) != s {
+			t.Fatal(name, string(b))
+		}
+	}
+
+	os.RemoveAll(path)
+}
@@ -0,0 +1,262 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type sampleEntry struct {
+	level   int
+	line    []byte
+	key     string
+	count   int
+	dropped int
+}
+
+//SampleKeyFunc returns the key a log is sampled by, see SamplingHandler.SetKeyFunc,
+//an empty key samples the log by its message.
+type SampleKeyFunc func(p []byte) string
+
+//SampleByField returns a SampleKeyFunc keying logs by the value of field,
+//parsed as key=value for text or a key of a json object, like SplitHandler.
+func SampleByField(field string) SampleKeyFunc {
+	return func(p []byte) string {
+		v, _ := fieldValue(p, field)
+		return v
+	}
+}
+
+//SamplingHandler limits the volume of identical logs, e.g. during an error storm.
+//
+//In every window, the first threshold logs with the same message are written
+//to the wrapped handler and the rest are dropped, a "repeated N times" summary
+//is written for dropped logs when the window ends.
+//Logs are compared without their leading timestamp, so logs are identical if
+//they have the same level, caller and message.
+//
+//SetKeyFunc samples logs by a key instead, e.g. the endpoint field, so rare endpoints
+//are all kept and noisy ones limited. SetMaxKeys bounds the keys counted in a window,
+//for keys of high cardinality.
+//
+//It is safe for concurrent use.
+type SamplingHandler struct {
+	mu sync.Mutex
+
+	h         Handler
+	threshold int
+	entries   map[string]*sampleEntry
+
+	keyFunc SampleKeyFunc
+	maxKeys int
+	//logs of new keys once maxKeys are counted, sampled together
+	overflow *sampleEntry
+
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func NewSamplingHandler(h Handler, window time.Duration, threshold int) (*SamplingHandler, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid sampling window %v", window)
+	}
+
+	if threshold <= 0 {
+		return nil, fmt.Errorf("invalid sampling threshold %d", threshold)
+	}
+
+	s := new(SamplingHandler)
+
+	s.h = h
+	s.threshold = threshold
+	s.entries = make(map[string]*sampleEntry)
+
+	s.quit = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run(window)
+
+	return s, nil
+}
+
+func (h *SamplingHandler) run(window time.Duration) {
+	defer h.wg.Done()
+
+	t := time.NewTicker(window)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.flush()
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+//SetKeyFunc makes logs be sampled by the key fn returns, the first threshold logs
+//with a key in every window are written, whatever their messages are. Logs fn returns
+//an empty key for, e.g. without the field, are sampled by message. A nil fn, the default,
+//samples all logs by message.
+func (h *SamplingHandler) SetKeyFunc(fn SampleKeyFunc) {
+	h.mu.Lock()
+	h.keyFunc = fn
+	h.mu.Unlock()
+}
+
+//SetMaxKeys bounds the keys, or messages, counted in a window to n, logs with
+//a new one beyond are all counted under one more key, so they are still limited
+//together. An n <= 0, the default, is no bound.
+func (h *SamplingHandler) SetMaxKeys(n int) {
+	h.mu.Lock()
+	h.maxKeys = n
+	h.mu.Unlock()
+}
+
+//sampleKey strips the leading timestamp of text and json logs.
+func sampleKey(p []byte) []byte {
+	if len(p) > 1 && p[0] == '[' && p[1] >= '0' && p[1] <= '9' {
+		if i := bytes.Index(p, []byte("] ")); i > 0 {
+			return p[i+2:]
+		}
+	}
+
+	//a string, or a number with an epoch time format
+	if bytes.HasPrefix(p, []byte(`{"time":"`)) {
+		if i := bytes.Index(p[9:], []byte(`",`)); i >= 0 {
+			return p[9+i+2:]
+		}
+	} else if bytes.HasPrefix(p, []byte(`{"time":`)) {
+		if i := bytes.IndexByte(p[8:], ','); i >= 0 {
+			return p[8+i+1:]
+		}
+	}
+
+	return p
+}
+
+func (h *SamplingHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+func (h *SamplingHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	h.mu.Lock()
+	e := h.entry(level, p)
+
+	e.count++
+	if e.count > h.threshold {
+		e.dropped++
+		h.mu.Unlock()
+		return len(p), nil
+	}
+	h.mu.Unlock()
+
+	return writeLevel(h.h, level, p)
+}
+
+//entry returns the entry p is counted in, h.mu must be held.
+func (h *SamplingHandler) entry(level int, p []byte) *sampleEntry {
+	var field string
+	if h.keyFunc != nil {
+		field = h.keyFunc(p)
+	}
+
+	//a leading 0 keeps keys apart from messages
+	var key []byte
+	if len(field) > 0 {
+		key = append([]byte{0}, field...)
+	} else {
+		key = sampleKey(p)
+	}
+
+	if e, ok := h.entries[string(key)]; ok {
+		return e
+	}
+
+	if h.maxKeys > 0 && len(h.entries) >= h.maxKeys {
+		if h.overflow == nil {
+			h.overflow = &sampleEntry{level: level}
+		}
+		return h.overflow
+	}
+
+	e := &sampleEntry{level: level, key: field}
+	if len(field) == 0 {
+		e.line = append([]byte(nil), bytes.TrimRight(key, "\n")...)
+	}
+	h.entries[string(key)] = e
+	return e
+}
+
+//flush starts a new window, and writes summaries for logs dropped in the last one.
+func (h *SamplingHandler) flush() error {
+	h.mu.Lock()
+	entries := h.entries
+	h.entries = make(map[string]*sampleEntry)
+	overflow, maxKeys := h.overflow, h.maxKeys
+	h.overflow = nil
+	h.mu.Unlock()
+
+	var err error
+	summary := func(e *sampleEntry, line string) {
+		if _, we := writeLevel(h.h, e.level, []byte(line)); we != nil && err == nil {
+			err = we
+		}
+	}
+
+	for _, e := range entries {
+		if e.dropped == 0 {
+			continue
+		}
+
+		if len(e.key) > 0 {
+			summary(e, fmt.Sprintf("sampled out %d logs with key %s\n", e.dropped, e.key))
+		} else {
+			summary(e, fmt.Sprintf("%s (repeated %d times)\n", e.line, e.dropped))
+		}
+	}
+
+	if overflow != nil && overflow.dropped > 0 {
+		summary(overflow, fmt.Sprintf("sampled out %d logs over %d keys\n", overflow.dropped, maxKeys))
+	}
+	return err
+}
+
+//Sync syncs the wrapped handler.
+func (h *SamplingHandler) Sync() error {
+	return syncHandler(h.h)
+}
+
+//Check checks the wrapped handler, see HealthChecker.
+func (h *SamplingHandler) Check() error {
+	return checkHandler(h.h)
+}
+
+//Flush flushes the wrapped handler, summaries are still written when the window ends.
+func (h *SamplingHandler) Flush() error {
+	return flushHandler(h.h)
+}
+
+//Close writes summaries for the current window and closes the wrapped handler,
+//closing it again does nothing.
+func (h *SamplingHandler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.quit)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+
+	err := h.flush()
+	if e := h.h.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	h, err := NewSamplingHandler(th, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, Ltime|Llevel)
+	for i := 0; i < 5; i++ {
+		l.Error("storm")
+	}
+	l.Info("calm")
+	l.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatal(buf.String())
+	}
+
+	if lines[3] != "[Error] storm (repeated 3 times)" {
+		t.Fatal(lines[3])
+	}
+
+	//closed by the logger already
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSamplingHandlerWindow(t *testing.T) {
+	var buf lockedBuffer
+	s, _ := NewStreamHandler(&buf)
+
+	h, _ := NewSamplingHandler(s, 10*time.Millisecond, 1)
+	defer h.Close()
+
+	h.Write([]byte("a\n"))
+	h.Write([]byte("a\n"))
+
+	time.Sleep(100 * time.Millisecond)
+	h.Write([]byte("a\n"))
+
+	if buf.String() != "a\na (repeated 1 times)\na\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestSamplingHandlerInvalid(t *testing.T) {
+	if _, err := NewSamplingHandler(DiscardHandler(), 0, 1); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := NewSamplingHandler(DiscardHandler(), time.Second, 0); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestSamplingHandlerKey(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	h, _ := NewSamplingHandler(th, time.Hour, 2)
+	h.SetKeyFunc(SampleByField("endpoint"))
+
+	l := New(h, 0)
+	for i := 0; i < 5; i++ {
+		l.WithField("endpoint", "/noisy").Infof("request %d", i)
+	}
+	l.WithField("endpoint", "/rare").Info("request")
+	for i := 0; i < 3; i++ {
+		l.Info("no endpoint")
+	}
+	l.Close()
+
+	s := "request 0 endpoint=/noisy\n" +
+		"request 1 endpoint=/noisy\n" +
+		"request endpoint=/rare\n" +
+		"no endpoint\n" +
+		"no endpoint\n"
+	if !strings.HasPrefix(buf.String(), s) {
+		t.Fatal(buf.String())
+	}
+
+	//summaries are written in any order
+	rest := buf.String()[len(s):]
+	if !strings.Contains(rest, "sampled out 3 logs with key /noisy\n") ||
+		!strings.Contains(rest, "no endpoint (repeated 1 times)\n") {
+		t.Fatal(rest)
+	}
+}
+
+func TestSamplingHandlerMaxKeys(t *testing.T) {
+	th, buf := NewTestHandler()
+
+	h, _ := NewSamplingHandler(th, time.Hour, 1)
+	h.SetKeyFunc(SampleByField("user"))
+	h.SetMaxKeys(2)
+
+	for _, user := range []string{"a", "b", "c", "d"} {
+		for i := 0; i < 3; i++ {
+			h.Write([]byte("login user=" + user + "\n"))
+		}
+	}
+	h.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatal(buf.String())
+	}
+
+	//c and d are over the bound, and sampled together
+	if s := strings.Join(lines[0:3], "\n"); s != "login user=a\nlogin user=b\nlogin user=c" {
+		t.Fatal(s)
+	}
+	if lines[5] != "sampled out 5 logs over 2 keys" {
+		t.Fatal(lines[5])
+	}
+}
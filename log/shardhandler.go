@@ -0,0 +1,94 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+//ShardHandler spreads logs round-robin over shards files, baseName.0 to baseName.N-1,
+//each with its own FileHandler, so heavy writers do not contend on a single file,
+//e.g. on a RAID array.
+//
+//Logs in different shards are not ordered, even from one goroutine, readers must
+//merge the files by timestamp, e.g. with Ltime and TimeFormatMicro, which sorts
+//as text: sort -m app.log.*
+//
+//It is safe for concurrent use.
+type ShardHandler struct {
+	//first to be 64-bit aligned for atomic
+	next uint64
+
+	shards []*FileHandler
+}
+
+func NewShardHandler(baseName string, shards int) (*ShardHandler, error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("invalid shards %d", shards)
+	}
+
+	h := new(ShardHandler)
+
+	h.shards = make([]*FileHandler, 0, shards)
+	for i := 0; i < shards; i++ {
+		f, err := NewFileHandler(fmt.Sprintf("%s.%d", baseName, i), os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
+		h.shards = append(h.shards, f)
+	}
+
+	return h, nil
+}
+
+//shard returns the shard of the next write.
+func (h *ShardHandler) shard() *FileHandler {
+	i := atomic.AddUint64(&h.next, 1)
+	return h.shards[i%uint64(len(h.shards))]
+}
+
+func (h *ShardHandler) Write(p []byte) (n int, err error) {
+	return h.shard().Write(p)
+}
+
+//WriteBatch writes records to a single shard.
+func (h *ShardHandler) WriteBatch(records [][]byte) (n int, err error) {
+	return h.shard().WriteBatch(records)
+}
+
+//Filenames returns the paths of the shards.
+func (h *ShardHandler) Filenames() []string {
+	names := make([]string, len(h.shards))
+	for i, f := range h.shards {
+		names[i] = f.fileName
+	}
+	return names
+}
+
+//Sync syncs every shard, errors are returned as a *MultiError.
+func (h *ShardHandler) Sync() error {
+	var errs MultiError
+	for _, f := range h.shards {
+		errs.add(f, f.Sync())
+	}
+	return errs.err()
+}
+
+//Check checks all shard files, a MultiError is returned if some fail.
+func (h *ShardHandler) Check() error {
+	var errs MultiError
+	for _, f := range h.shards {
+		errs.add(f, f.Check())
+	}
+	return errs.err()
+}
+
+//Close closes every shard, errors are returned as a *MultiError.
+func (h *ShardHandler) Close() error {
+	var errs MultiError
+	for _, f := range h.shards {
+		errs.add(f, f.Close())
+	}
+	return errs.err()
+}
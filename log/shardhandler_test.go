@@ -0,0 +1,54 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShardHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, err := NewShardHandler(path.Join(dir, "app.log"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 30; j++ {
+				h.Write([]byte("line\n"))
+			}
+		}()
+	}
+	wg.Wait()
+	h.Close()
+
+	names := h.Filenames()
+	sort.Strings(names)
+	if len(names) != 3 || names[2] != path.Join(dir, "app.log.2") {
+		t.Fatal(names)
+	}
+
+	for _, name := range names {
+		b, _ := ioutil.ReadFile(name)
+		if n := strings.Count(string(b), "line\n"); n != 40 {
+			t.Fatal(name, n)
+		}
+	}
+
+	if _, err := NewShardHandler(path.Join(dir, "app.log"), 0); err == nil {
+		t.Fatal("must fail")
+	}
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+)
+
+//loggers registered by RegisterForShutdown, in order, one per sink,
+//as loggers derived from one share its handler
+var shutdown struct {
+	mu      sync.Mutex
+	loggers []*Logger
+}
+
+//RegisterForShutdown adds l to the loggers synced by FlushAll and closed by CloseAll,
+//so they need not be tracked for a graceful shutdown. It is opt-in, as registered
+//loggers are kept until closed. Registering l, or a logger derived from it, again does nothing.
+func RegisterForShutdown(l *Logger) {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+
+	for _, r := range shutdown.loggers {
+		if r.s == l.s {
+			return
+		}
+	}
+	shutdown.loggers = append(shutdown.loggers, l)
+}
+
+//unregisterForShutdown removes the logger of s, closed now.
+func unregisterForShutdown(s *sink) {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+
+	for i, r := range shutdown.loggers {
+		if r.s == s {
+			shutdown.loggers = append(shutdown.loggers[0:i], shutdown.loggers[i+1:]...)
+			return
+		}
+	}
+}
+
+func registered() []*Logger {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+
+	return append([]*Logger(nil), shutdown.loggers...)
+}
+
+//syncAll syncs loggers, the errors are returned as a *MultiError, each one
+//prefixed with the name of the handler of its logger.
+func syncAll(loggers []*Logger) error {
+	var errs MultiError
+	for _, l := range loggers {
+		if err := l.Sync(); err != nil {
+			errs.Errors = append(errs.Errors, fmt.Errorf("%s: %w", handlerName(l.s.handler), err))
+		}
+	}
+	return errs.err()
+}
+
+//FlushAll waits until the queued logs of all registered loggers are written,
+//and syncs their handlers, see Logger.Sync and RegisterForShutdown.
+func FlushAll() error {
+	return syncAll(registered())
+}
+
+//CloseAll syncs and closes all registered loggers, e.g. at shutdown,
+//the sync errors are returned like FlushAll. Closed loggers are unregistered.
+func CloseAll() error {
+	loggers := registered()
+
+	err := syncAll(loggers)
+	for _, l := range loggers {
+		l.Close()
+	}
+	return err
+}
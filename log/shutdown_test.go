@@ -0,0 +1,66 @@
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+//syncErrHandler discards logs and fails every Sync.
+type syncErrHandler struct{}
+
+func (h *syncErrHandler) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (h *syncErrHandler) Close() error {
+	return nil
+}
+
+func (h *syncErrHandler) Sync() error {
+	return errors.New("sync failed")
+}
+
+func TestCloseAll(t *testing.T) {
+	h1, buf1 := NewTestHandler()
+	h2 := new(syncErrHandler)
+	h3, _ := NewTestHandler()
+
+	l1 := New(h1, 0)
+	l2 := New(h2, 0)
+	l3 := New(h3, 0)
+
+	RegisterForShutdown(l1)
+	RegisterForShutdown(l1.WithField("a", 1))
+	RegisterForShutdown(l2)
+	RegisterForShutdown(l3)
+	if n := len(registered()); n != 3 {
+		t.Fatal(n)
+	}
+
+	//closed before shutdown
+	l3.Close()
+	if n := len(registered()); n != 2 {
+		t.Fatal(n)
+	}
+
+	l1.Info("hello")
+	if err := FlushAll(); err == nil {
+		t.Fatal("must fail")
+	}
+	if buf1.String() != "hello\n" {
+		t.Fatal(buf1.String())
+	}
+
+	err := CloseAll()
+	var m *MultiError
+	if !errors.As(err, &m) || len(m.Errors) != 1 || m.Errors[0].Error() != "*log.syncErrHandler: sync failed" {
+		t.Fatal(err)
+	}
+
+	if n := len(registered()); n != 0 {
+		t.Fatal(n)
+	}
+	if err := CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package log
 import (
 	"encoding/binary"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,17 +14,52 @@ type SocketHandler struct {
 	c        net.Conn
 	protocol string
 	addr     string
+	timeout  time.Duration
+
+	dropped int64
 }
 
 func NewSocketHandler(protocol string, addr string) (*SocketHandler, error) {
+	return NewSocketHandlerTimeout(protocol, addr, 0)
+}
+
+//NewSocketHandlerTimeout is like NewSocketHandler, but a write taking longer than timeout
+//is given up, the log is dropped and counted, and the connection is reopened on next write.
+//A timeout <= 0 means no timeout. Wrap it with an AsyncHandler to never block the caller.
+func NewSocketHandlerTimeout(protocol string, addr string, timeout time.Duration) (*SocketHandler, error) {
 	s := new(SocketHandler)
 
 	s.protocol = protocol
 	s.addr = addr
+	s.timeout = timeout
 
 	return s, nil
 }
 
+//setDeadline sets the write deadline of the connection if a timeout is set.
+func (h *SocketHandler) setDeadline() {
+	if h.timeout > 0 {
+		h.c.SetWriteDeadline(time.Now().Add(h.timeout))
+	}
+}
+
+//writeFailed closes the connection after a failed write, a timeout drops the log.
+func (h *SocketHandler) writeFailed(err error, n int) (int, error) {
+	h.c.Close()
+	h.c = nil
+
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		atomic.AddInt64(&h.dropped, int64(n))
+		return 0, nil
+	}
+	return 0, err
+}
+
+//Dropped returns the number of logs dropped because of write timeout.
+func (h *SocketHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
 func (h *SocketHandler) Write(p []byte) (n int, err error) {
 	if err = h.connect(); err != nil {
 		return
@@ -35,12 +71,46 @@ func (h *SocketHandler) Write(p []byte) (n int, err error) {
 
 	copy(buf[4:], p)
 
+	h.setDeadline()
 	n, err = h.c.Write(buf)
 	if err != nil {
-		h.c.Close()
-		h.c = nil
+		return h.writeFailed(err, 1)
+	}
+	return len(p), nil
+}
+
+//Name returns "socket:" and the protocol and address, e.g. "socket:tcp://127.0.0.1:5000".
+func (h *SocketHandler) Name() string {
+	return "socket:" + h.protocol + "://" + h.addr
+}
+
+//WriteBatch writes framed records with a single writev to the connection.
+func (h *SocketHandler) WriteBatch(records [][]byte) (n int, err error) {
+	if err = h.connect(); err != nil {
+		return
+	}
+
+	heads := make([]byte, 4*len(records))
+	bufs := make(net.Buffers, 0, 2*len(records))
+	size := 0
+	for i, p := range records {
+		head := heads[4*i : 4*i+4]
+		binary.BigEndian.PutUint32(head, uint32(len(p)))
+		bufs = append(bufs, head, p)
+		size += len(p)
+	}
+
+	h.setDeadline()
+	if _, err = bufs.WriteTo(h.c); err != nil {
+		return h.writeFailed(err, len(records))
 	}
-	return
+	return size, nil
+}
+
+//Check dials if disconnected, the dial error is returned.
+//A dropped connection is only found by the next write.
+func (h *SocketHandler) Check() error {
+	return h.connect()
 }
 
 func (h *SocketHandler) Close() error {
@@ -56,7 +126,12 @@ func (h *SocketHandler) connect() error {
 	}
 
 	var err error
-	h.c, err = net.DialTimeout(h.protocol, h.addr, 20*time.Second)
+	timeout := 20 * time.Second
+	if h.timeout > 0 {
+		timeout = h.timeout
+	}
+
+	h.c, err = net.DialTimeout(h.protocol, h.addr, timeout)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSocketHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	h, _ := NewSocketHandler("tcp", ln.Addr().String())
+	defer h.Close()
+
+	if _, err := h.WriteBatch([][]byte{[]byte("hello"), []byte("world")}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, msg := range []string{"hello", "world"} {
+		head := make([]byte, 4)
+		if _, err := io.ReadFull(c, head); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([]byte, binary.BigEndian.Uint32(head))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatal(err)
+		} else if string(buf) != msg {
+			t.Fatal(string(buf))
+		}
+	}
+}
+
+func TestSocketHandlerTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	h, _ := NewSocketHandlerTimeout("tcp", ln.Addr().String(), 50*time.Millisecond)
+	defer h.Close()
+
+	//nobody reads, so writes block once the socket buffers are full
+	p := make([]byte, 1<<20)
+	for i := 0; i < 64 && h.Dropped() == 0; i++ {
+		if _, err := h.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if h.Dropped() == 0 {
+		t.Fatal("must drop")
+	}
+}
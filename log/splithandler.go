@@ -0,0 +1,286 @@
+package log
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+//SplitFactory creates the handler for logs with a field value, e.g. a file per tenant.
+type SplitFactory func(value string) (Handler, error)
+
+type splitEntry struct {
+	value string
+	h     Handler
+}
+
+//SplitHandler writes logs to a handler per value of a field, e.g. tenant_id,
+//handlers are created by a factory on first use and cached.
+//
+//The field is parsed from the formatted log, as key=value for text, or a key
+//of a json object. Logs without the field are written to the default handler.
+//
+//At most maxOpen handlers are cached, the least recently used one is closed
+//when a new one is needed, and created again by the factory if its value comes back,
+//so a field with unbounded values, e.g. a request id, can not exhaust the fds.
+//
+//It is safe for concurrent use, writes are serialized.
+type SplitHandler struct {
+	mu sync.Mutex
+
+	field   string
+	factory SplitFactory
+	def     Handler
+
+	maxOpen int
+	lru     *list.List
+	cache   map[string]*list.Element
+}
+
+func NewSplitHandler(field string, factory SplitFactory, def Handler) (*SplitHandler, error) {
+	if len(field) == 0 {
+		return nil, fmt.Errorf("empty split field")
+	}
+
+	h := new(SplitHandler)
+
+	h.field = field
+	h.factory = factory
+	h.def = def
+	h.maxOpen = 128
+	h.lru = list.New()
+	h.cache = make(map[string]*list.Element)
+
+	return h, nil
+}
+
+//SetMaxOpen sets the max number of cached handlers, default is 128,
+//the least recently used ones are closed if there are more.
+func (h *SplitHandler) SetMaxOpen(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid max open: %d", n)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.maxOpen = n
+	return h.evict()
+}
+
+//Open returns the number of cached handlers, each one holds a file if
+//the factory creates file handlers, the default handler is not counted.
+func (h *SplitHandler) Open() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.lru.Len()
+}
+
+func (h *SplitHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p to the handler of its field value. If the factory fails,
+//p is written to the default handler and the factory error is returned.
+func (h *SplitHandler) WriteLevel(level int, p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	value, ok := fieldValue(p, h.field)
+	if !ok {
+		return writeLevel(h.def, level, p)
+	}
+
+	s, err := h.handler(value)
+	if err != nil {
+		writeLevel(h.def, level, p)
+		return 0, err
+	}
+
+	return writeLevel(s, level, p)
+}
+
+//HandleRecord passes r to the handler of its field value, taken from the fields
+//of r, so the line is only parsed if the field is not one of them, e.g. in the message.
+func (h *SplitHandler) HandleRecord(r *Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var value string
+	v, ok := r.Fields[h.field]
+	if ok {
+		value = fmt.Sprint(v)
+	} else if value, ok = fieldValue(r.Line, h.field); !ok {
+		return handleRecord(h.def, r)
+	}
+
+	s, err := h.handler(value)
+	if err != nil {
+		handleRecord(h.def, r)
+		return err
+	}
+
+	return handleRecord(s, r)
+}
+
+//handler returns the cached handler of value, or a new one, h.mu must be held.
+func (h *SplitHandler) handler(value string) (Handler, error) {
+	if e, ok := h.cache[value]; ok {
+		h.lru.MoveToFront(e)
+		return e.Value.(*splitEntry).h, nil
+	}
+
+	s, err := h.factory(value)
+	if err != nil {
+		return nil, fmt.Errorf("split handler for %s=%s: %w", h.field, value, err)
+	}
+
+	h.cache[value] = h.lru.PushFront(&splitEntry{value, s})
+	return s, h.evict()
+}
+
+//evict closes the least recently used handlers over maxOpen, h.mu must be held.
+func (h *SplitHandler) evict() error {
+	var err error
+	for h.lru.Len() > h.maxOpen {
+		e := h.lru.Remove(h.lru.Back()).(*splitEntry)
+		delete(h.cache, e.value)
+
+		if err1 := e.h.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+//Sync syncs all cached handlers and the default one, the first error is returned.
+func (h *SplitHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := syncHandler(h.def)
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		if err1 := syncHandler(e.Value.(*splitEntry).h); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+//Check checks all cached handlers and the default one, the first error is returned.
+func (h *SplitHandler) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := checkHandler(h.def)
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		if err1 := checkHandler(e.Value.(*splitEntry).h); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+//Flush flushes all cached handlers and the default one, the first error is returned.
+func (h *SplitHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := flushHandler(h.def)
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		if err1 := flushHandler(e.Value.(*splitEntry).h); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+//Close closes all cached handlers and the default one, the first error is returned.
+func (h *SplitHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	for e := h.lru.Front(); e != nil; e = e.Next() {
+		if err1 := e.Value.(*splitEntry).h.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	h.lru.Init()
+	h.cache = make(map[string]*list.Element)
+
+	if err1 := h.def.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+//fieldValue returns the value of key in a log formatted by TextFormatter or JSONFormatter.
+func fieldValue(p []byte, key string) (string, bool) {
+	p = bytes.TrimRight(p, "\r\n")
+
+	if len(p) > 0 && p[0] == '{' {
+		return jsonFieldValue(p, key)
+	}
+
+	//fields follow the message, so the last match is taken
+	i := bytes.LastIndex(p, []byte(" "+key+"="))
+	if i < 0 {
+		return "", false
+	}
+
+	v := p[i+len(key)+2:]
+	if j := bytes.IndexByte(v, ' '); j >= 0 {
+		v = v[0:j]
+	}
+	return string(v), true
+}
+
+//jsonFieldValue returns the value of key in a json object of one line,
+//a string is unquoted, others are returned as encoded.
+func jsonFieldValue(p []byte, key string) (string, bool) {
+	k, _ := json.Marshal(key)
+	k = append(k, ':')
+
+	//a key follows { or , while a quote in a string value is escaped
+	i := 0
+	for {
+		j := bytes.Index(p[i:], k)
+		if j < 0 {
+			return "", false
+		}
+		i += j
+		if i > 0 && (p[i-1] == '{' || p[i-1] == ',') {
+			break
+		}
+		i++
+	}
+
+	v := p[i+len(k):]
+	if len(v) > 0 && v[0] == '"' {
+		end := 1
+		for end < len(v) && v[end] != '"' {
+			if v[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(v) {
+			return "", false
+		}
+
+		var s string
+		if err := json.Unmarshal(v[0:end+1], &s); err != nil {
+			return "", false
+		}
+		return s, true
+	}
+
+	if j := bytes.IndexAny(v, ",}"); j >= 0 {
+		v = v[0:j]
+	}
+	return string(v), true
+}
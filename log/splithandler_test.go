@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitHandler(t *testing.T) {
+	bufs := make(map[string]*bytes.Buffer)
+	created := 0
+	def, defBuf := NewTestHandler()
+
+	h, _ := NewSplitHandler("tenant_id", func(value string) (Handler, error) {
+		created++
+		if bufs[value] == nil {
+			bufs[value] = new(bytes.Buffer)
+		}
+		return NewStreamHandler(bufs[value])
+	}, def)
+	h.SetMaxOpen(2)
+
+	l := New(h, Llevel)
+	l.WithField("tenant_id", "a").Info("1")
+	l.WithField("tenant_id", "b").Info("2")
+	l.Info("no tenant")
+
+	l.SetFlags(Ljson)
+	l.WithField("tenant_id", "a").Info(`tenant_id=b "tenant_id":"b"`)
+	l.WithField("tenant_id", 3).Info("3")
+	l.WithField("tenant_id", "b").Info("4")
+	l.Sync()
+
+	if n := h.Open(); n != 2 {
+		t.Fatal(n)
+	}
+	l.Close()
+
+	if s := bufs["a"].String(); s != "[Info] 1 tenant_id=a\n"+`{"msg":"tenant_id=b \"tenant_id\":\"b\"","tenant_id":"a"}`+"\n" {
+		t.Fatal(s)
+	}
+	if s := bufs["b"].String(); s != "[Info] 2 tenant_id=b\n"+`{"msg":"4","tenant_id":"b"}`+"\n" {
+		t.Fatal(s)
+	}
+	if s := bufs["3"].String(); s != `{"msg":"3","tenant_id":3}`+"\n" {
+		t.Fatal(s)
+	}
+	if s := defBuf.String(); s != "[Info] no tenant\n" {
+		t.Fatal(s)
+	}
+
+	//b was evicted by 3 and created again
+	if created != 4 {
+		t.Fatal(created)
+	}
+}
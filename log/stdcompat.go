@@ -0,0 +1,86 @@
+package log
+
+import (
+	"fmt"
+	"os"
+)
+
+//exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+//StdCompat has the methods of the standard *log.Logger, backed by a Logger,
+//so code using the standard logger can be migrated with minimal changes.
+//
+//Print logs with info level, Fatal logs with fatal level then exits with status 1
+//after the handler is synced, and Panic logs with fatal level then panics.
+type StdCompat struct {
+	l *Logger
+}
+
+//NewStdCompat returns a StdCompat backed by the standard Logger of this package.
+func NewStdCompat() *StdCompat {
+	return std.StdCompat()
+}
+
+//StdCompat returns a StdCompat backed by l.
+func (l *Logger) StdCompat() *StdCompat {
+	return &StdCompat{l}
+}
+
+//Output logs s with info level, like the standard logger, callDepth is
+//relative to the caller of Output.
+func (c *StdCompat) Output(callDepth int, s string) error {
+	c.l.Output(callDepth+1, LevelInfo, s)
+	return nil
+}
+
+func (c *StdCompat) Print(v ...interface{}) {
+	if c.l.enabled(LevelInfo) {
+		c.l.Output(2, LevelInfo, fmt.Sprint(v...))
+	}
+}
+
+func (c *StdCompat) Printf(format string, v ...interface{}) {
+	if c.l.enabled(LevelInfo) {
+		c.l.Output(2, LevelInfo, fmt.Sprintf(format, v...))
+	}
+}
+
+func (c *StdCompat) Println(v ...interface{}) {
+	if c.l.enabled(LevelInfo) {
+		c.l.Output(2, LevelInfo, fmt.Sprintln(v...))
+	}
+}
+
+func (c *StdCompat) Fatal(v ...interface{}) {
+	c.l.Output(2, LevelFatal, fmt.Sprint(v...))
+	exit(1)
+}
+
+func (c *StdCompat) Fatalf(format string, v ...interface{}) {
+	c.l.Output(2, LevelFatal, fmt.Sprintf(format, v...))
+	exit(1)
+}
+
+func (c *StdCompat) Fatalln(v ...interface{}) {
+	c.l.Output(2, LevelFatal, fmt.Sprintln(v...))
+	exit(1)
+}
+
+func (c *StdCompat) Panic(v ...interface{}) {
+	s := fmt.Sprint(v...)
+	c.l.Output(2, LevelFatal, s)
+	panic(s)
+}
+
+func (c *StdCompat) Panicf(format string, v ...interface{}) {
+	s := fmt.Sprintf(format, v...)
+	c.l.Output(2, LevelFatal, s)
+	panic(s)
+}
+
+func (c *StdCompat) Panicln(v ...interface{}) {
+	s := fmt.Sprintln(v...)
+	c.l.Output(2, LevelFatal, s)
+	panic(s)
+}
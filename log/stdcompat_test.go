@@ -0,0 +1,35 @@
+package log
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStdCompat(t *testing.T) {
+	h, buf := NewTestHandler()
+	l := New(h, Lfile|Llevel)
+	defer l.Close()
+
+	c := l.StdCompat()
+	c.Println("hello", 1)
+
+	code := 0
+	exit = func(n int) { code = n }
+	defer func() { exit = os.Exit }()
+
+	c.Fatalf("world %d", 2)
+	if code != 1 {
+		t.Fatal(code)
+	}
+
+	if s := "stdcompat_test.go:14 [Info] hello 1\nstdcompat_test.go:20 [Fatal] world 2\n"; buf.String() != s {
+		t.Fatal(buf.String())
+	}
+
+	defer func() {
+		if r := recover(); r != "again" {
+			t.Fatal(r)
+		}
+	}()
+	c.Panic("again")
+}
@@ -0,0 +1,114 @@
+// +build !windows,!plan9
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"log/syslog"
+	"sync/atomic"
+)
+
+var _ io.WriteCloser = (*SyslogHandler)(nil)
+
+//SyslogHandler writes logs to a local or remote syslog daemon.
+//
+//syslog wants discrete messages, so every line is sent as a single message.
+type SyslogHandler struct {
+	w *syslog.Writer
+
+	network  string
+	raddr    string
+	priority syslog.Priority
+	tag      string
+
+	closed int32
+}
+
+//NewSyslogHandler connects to the syslog daemon at raddr on network,
+//if network is empty, it connects to the local syslog daemon.
+func NewSyslogHandler(network, raddr string, priority syslog.Priority, tag string) (*SyslogHandler, error) {
+	w, err := syslog.Dial(network, raddr, priority, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(SyslogHandler)
+
+	h.w = w
+	h.network = network
+	h.raddr = raddr
+	h.priority = priority
+	h.tag = tag
+
+	return h, nil
+}
+
+func (h *SyslogHandler) Write(p []byte) (n int, err error) {
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if _, err = h.w.Write(line); err != nil {
+			return
+		}
+	}
+
+	return len(p), nil
+}
+
+//WriteLevel writes p with the syslog severity of level instead of the priority
+//given to NewSyslogHandler, trace and debug map to LOG_DEBUG, fatal to LOG_CRIT.
+func (h *SyslogHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if err = h.writeSeverity(level, string(line)); err != nil {
+			return
+		}
+	}
+
+	return len(p), nil
+}
+
+func (h *SyslogHandler) writeSeverity(level int, m string) error {
+	switch {
+	case level <= LevelDebug:
+		return h.w.Debug(m)
+	case level == LevelInfo:
+		return h.w.Info(m)
+	case level == LevelWarn:
+		return h.w.Warning(m)
+	case level == LevelError:
+		return h.w.Err(m)
+	default:
+		return h.w.Crit(m)
+	}
+}
+
+//Name returns "syslog".
+func (h *SyslogHandler) Name() string {
+	return "syslog"
+}
+
+//Check dials the syslog daemon again to tell it is reachable, the dial error is
+//returned. A udp daemon can not be told down this way, only its address is resolved.
+func (h *SyslogHandler) Check() error {
+	if atomic.LoadInt32(&h.closed) == 1 {
+		return misuse(ErrHandlerClosed)
+	}
+
+	w, err := syslog.Dial(h.network, h.raddr, h.priority, h.tag)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (h *SyslogHandler) Close() error {
+	atomic.StoreInt32(&h.closed, 1)
+	return h.w.Close()
+}
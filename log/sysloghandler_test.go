@@ -0,0 +1,102 @@
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogHandler(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	h, err := NewSyslogHandler("udp", c.LocalAddr().String(), syslog.LOG_INFO|syslog.LOG_USER, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Write([]byte("hello\nworld\n"))
+	h.Close()
+
+	buf := make([]byte, 1024)
+	for _, msg := range []string{"hello", "world"} {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := string(buf[0:n]); !strings.HasSuffix(strings.TrimSpace(s), msg) {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestSyslogHandlerWriteLevel(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	h, err := NewSyslogHandler("udp", c.LocalAddr().String(), syslog.LOG_INFO|syslog.LOG_USER, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(h, 0)
+	l.SetLevel(LevelDebug)
+	l.Debug("debug")
+	l.Warn("warn")
+	l.Error("error")
+	l.Close()
+
+	buf := make([]byte, 1024)
+	for _, p := range []syslog.Priority{syslog.LOG_DEBUG, syslog.LOG_WARNING, syslog.LOG_ERR} {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s := string(buf[0:n]); !strings.HasPrefix(s, fmt.Sprintf("<%d>", p|syslog.LOG_USER)) {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestSyslogHandlerCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewSyslogHandler("tcp", ln.Addr().String(), syslog.LOG_INFO|syslog.LOG_USER, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the daemon stopped
+	ln.Close()
+	if err := h.Check(); err == nil {
+		t.Fatal("must fail")
+	}
+
+	h.Close()
+	if err := misused(h.Check); !errors.Is(err, ErrHandlerClosed) {
+		t.Fatal(err)
+	}
+}
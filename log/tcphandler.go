@@ -0,0 +1,255 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	tcpMinBackoff = 100 * time.Millisecond
+	tcpMaxBackoff = 30 * time.Second
+
+	tcpDefaultBufferSize = 1 << 20
+	tcpDefaultTimeout    = 5 * time.Second
+)
+
+//dialTCP dials the endpoint of TCPHandler, tests replace it.
+var dialTCP = net.DialTimeout
+
+//TCPHandler writes log lines as they are to a remote TCP endpoint, e.g. a log aggregator.
+//
+//If the connection drops, it is redialed on later writes with exponential backoff,
+//from 100ms up to 30s. Logs written while disconnected are buffered, up to 1MB by default,
+//the oldest are dropped beyond, and buffered logs are written first after reconnect.
+//
+//It is safe for concurrent use.
+type TCPHandler struct {
+	mu sync.Mutex
+
+	addr    string
+	c       net.Conn
+	timeout time.Duration
+
+	backoff  time.Duration
+	nextDial time.Time
+	//a dial is in progress without h.mu
+	dialing bool
+
+	pending     [][]byte
+	pendingSize int
+	bufferSize  int
+
+	dropped int64
+	closed  bool
+}
+
+func NewTCPHandler(addr string) (*TCPHandler, error) {
+	h := new(TCPHandler)
+
+	h.addr = addr
+	h.timeout = tcpDefaultTimeout
+	h.bufferSize = tcpDefaultBufferSize
+
+	return h, nil
+}
+
+//SetBufferSize sets the max bytes of logs buffered while disconnected.
+func (h *TCPHandler) SetBufferSize(size int) {
+	h.mu.Lock()
+	h.bufferSize = size
+	h.dropOldest()
+	h.mu.Unlock()
+}
+
+//SetTimeout sets the timeout of dial and every write, default is 5s.
+func (h *TCPHandler) SetTimeout(timeout time.Duration) {
+	h.mu.Lock()
+	h.timeout = timeout
+	h.mu.Unlock()
+}
+
+//Dropped returns the number of logs dropped because the buffer was full.
+func (h *TCPHandler) Dropped() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.dropped
+}
+
+//Name returns "tcp:" and the address.
+func (h *TCPHandler) Name() string {
+	return "tcp:" + h.addr
+}
+
+//connect dials if disconnected and the backoff has passed, h.mu must be held.
+//It is released while dialing, so other writes buffer logs instead of waiting.
+func (h *TCPHandler) connect() bool {
+	if h.c != nil {
+		return true
+	}
+
+	if h.dialing || time.Now().Before(h.nextDial) {
+		return false
+	}
+
+	h.dialing = true
+	timeout := h.timeout
+	h.mu.Unlock()
+	c, err := dialTCP("tcp", h.addr, timeout)
+	h.mu.Lock()
+	h.dialing = false
+
+	if err != nil {
+		h.disconnect()
+		return false
+	} else if h.closed {
+		c.Close()
+		return false
+	}
+
+	h.c = c
+	h.backoff = 0
+	return true
+}
+
+//disconnect closes the connection and schedules the next dial, h.mu must be held.
+func (h *TCPHandler) disconnect() {
+	if h.c != nil {
+		h.c.Close()
+		h.c = nil
+	}
+
+	if h.backoff == 0 {
+		h.backoff = tcpMinBackoff
+	} else if h.backoff *= 2; h.backoff > tcpMaxBackoff {
+		h.backoff = tcpMaxBackoff
+	}
+	h.nextDial = time.Now().Add(h.backoff)
+}
+
+//buffer keeps a copy of p until reconnected, h.mu must be held.
+func (h *TCPHandler) buffer(p []byte) {
+	h.pending = append(h.pending, append([]byte(nil), p...))
+	h.pendingSize += len(p)
+	h.dropOldest()
+}
+
+func (h *TCPHandler) dropOldest() {
+	for h.pendingSize > h.bufferSize && len(h.pending) > 0 {
+		h.pendingSize -= len(h.pending[0])
+		h.pending[0] = nil
+		h.pending = h.pending[1:]
+		h.dropped++
+	}
+}
+
+//flush writes buffered logs, h.mu must be held and connected.
+func (h *TCPHandler) flush() error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+
+	bufs := net.Buffers(append([][]byte(nil), h.pending...))
+
+	h.c.SetWriteDeadline(time.Now().Add(h.timeout))
+	_, err := bufs.WriteTo(h.c)
+	if err != nil {
+		//a partly written log may be written again, better than lost
+		h.disconnect()
+		return err
+	}
+
+	h.pending = nil
+	h.pendingSize = 0
+	return nil
+}
+
+//Write writes p to the connection, or buffers it if disconnected,
+//so it never returns an error for a dropped connection.
+func (h *TCPHandler) Write(p []byte) (n int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+
+	if !h.connect() || h.flush() != nil {
+		h.buffer(p)
+		return len(p), nil
+	}
+
+	h.c.SetWriteDeadline(time.Now().Add(h.timeout))
+	if _, err = h.c.Write(p); err != nil {
+		h.disconnect()
+		h.buffer(p)
+	}
+	return len(p), nil
+}
+
+//Flush writes buffered logs if connected, or if the backoff has passed and dialing succeeds,
+//else they stay buffered for the next write.
+func (h *TCPHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+
+	if !h.connect() {
+		return nil
+	}
+	return h.flush()
+}
+
+//Check dials at once if disconnected, whatever the backoff is, an error is
+//returned if it fails. A dropped connection is only found by the next write.
+func (h *TCPHandler) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+
+	h.nextDial = time.Time{}
+	if !h.connect() {
+		return fmt.Errorf("not connected to %s", h.addr)
+	}
+	return nil
+}
+
+//Close tries to write buffered logs, then closes the connection,
+//an error is returned if some buffered logs can not be sent.
+//Closing it again does nothing.
+func (h *TCPHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	h.nextDial = time.Time{}
+
+	var err error
+	if h.connect() {
+		err = h.flush()
+	}
+	h.closed = true
+
+	if len(h.pending) > 0 && err == nil {
+		err = fmt.Errorf("%d buffered logs not sent to %s", len(h.pending), h.addr)
+	}
+
+	if h.c != nil {
+		if e := h.c.Close(); err == nil {
+			err = e
+		}
+		h.c = nil
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package log
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	h, _ := NewTCPHandler(ln.Addr().String())
+
+	h.Write([]byte("1\n"))
+
+	//pretend the connection dropped and the backoff has not passed
+	h.mu.Lock()
+	h.disconnect()
+	h.nextDial = time.Now().Add(time.Hour)
+	h.mu.Unlock()
+
+	h.SetBufferSize(4)
+	h.Write([]byte("2\n"))
+	h.Write([]byte("3\n"))
+	h.Write([]byte("4\n"))
+
+	if n := h.Dropped(); n != 1 {
+		t.Fatal(n)
+	}
+
+	h.mu.Lock()
+	h.nextDial = time.Time{}
+	h.mu.Unlock()
+
+	h.Write([]byte("5\n"))
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	for _, want := range []string{"1\n", "3\n4\n5\n"} {
+		c, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, _ = ioutil.ReadAll(c)
+		c.Close()
+
+		if string(data) != want {
+			t.Fatal(string(data))
+		}
+	}
+}
+
+func TestTCPHandlerBackoff(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	h, _ := NewTCPHandler(addr)
+
+	h.Write([]byte("hello\n"))
+	if h.backoff != tcpMinBackoff {
+		t.Fatal(h.backoff)
+	}
+
+	h.nextDial = time.Time{}
+	h.Write([]byte("hello\n"))
+	if h.backoff != 2*tcpMinBackoff {
+		t.Fatal(h.backoff)
+	}
+
+	if err := h.Close(); err == nil {
+		t.Fatal("must fail")
+	}
+}
+
+func TestTCPHandlerDialUnlocked(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	dialed := make(chan struct{})
+	release := make(chan struct{})
+	dialTCP = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		close(dialed)
+		<-release
+		return net.DialTimeout(network, addr, timeout)
+	}
+	defer func() {
+		dialTCP = net.DialTimeout
+	}()
+
+	h, _ := NewTCPHandler(ln.Addr().String())
+
+	done := make(chan struct{})
+	go func() {
+		h.Write([]byte("1\n"))
+		close(done)
+	}()
+	<-dialed
+
+	//a slow dial does not block other writes, they are buffered meanwhile
+	h.Write([]byte("2\n"))
+	close(release)
+	<-done
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, _ := ioutil.ReadAll(c)
+	c.Close()
+
+	if string(data) != "2\n1\n" {
+		t.Fatal(string(data))
+	}
+}
+
+func TestTCPHandlerClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	h, _ := NewTCPHandler(ln.Addr().String())
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []func() error{
+		func() error { _, err := h.Write([]byte("a\n")); return err },
+		h.Flush,
+		h.Check,
+	} {
+		if err := misused(f); !errors.Is(err, ErrHandlerClosed) {
+			t.Fatal(err)
+		}
+	}
+}
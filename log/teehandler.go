@@ -0,0 +1,101 @@
+package log
+
+import (
+	"time"
+)
+
+//TeeOnLevelHandler writes all logs to a main handler, and copies the ones with
+//level >= minLevel to a tee handler, e.g. logs in a file with errors also on stderr,
+//so that docker logs shows them at once.
+//
+//It is a short form of a LevelRouterHandler with two routes.
+//A Write without level, e.g. not from a Logger, is written as LevelInfo.
+//
+//It is safe for concurrent use if both handlers are.
+type TeeOnLevelHandler struct {
+	main     Handler
+	tee      Handler
+	minLevel int
+}
+
+func NewTeeOnLevelHandler(main Handler, tee Handler, minLevel int) (*TeeOnLevelHandler, error) {
+	h := new(TeeOnLevelHandler)
+
+	h.main = main
+	h.tee = tee
+	h.minLevel = minLevel
+
+	return h, nil
+}
+
+func (h *TeeOnLevelHandler) Write(p []byte) (n int, err error) {
+	return h.WriteLevel(LevelInfo, p)
+}
+
+//WriteLevel writes p to main, and to tee if level >= minLevel,
+//a tee error is returned only if main succeeds.
+func (h *TeeOnLevelHandler) WriteLevel(level int, p []byte) (n int, err error) {
+	n, err = writeLevel(h.main, level, p)
+	if level >= h.minLevel {
+		if _, e := writeLevel(h.tee, level, p); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+//WriteTime writes p with level and t like WriteLevel, see TimeWriter.
+func (h *TeeOnLevelHandler) WriteTime(level int, t time.Time, p []byte) (n int, err error) {
+	n, err = writeTime(h.main, level, t, p)
+	if level >= h.minLevel {
+		if _, e := writeTime(h.tee, level, t, p); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+//HandleRecord passes r to main, and to tee if its level >= minLevel, see RecordHandler.
+func (h *TeeOnLevelHandler) HandleRecord(r *Record) error {
+	err := handleRecord(h.main, r)
+	if r.Level >= h.minLevel {
+		if e := handleRecord(h.tee, r); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (h *TeeOnLevelHandler) Sync() error {
+	err := syncHandler(h.main)
+	if e := syncHandler(h.tee); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+//Check checks both handlers, the first error is returned.
+func (h *TeeOnLevelHandler) Check() error {
+	err := checkHandler(h.main)
+	if e := checkHandler(h.tee); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+func (h *TeeOnLevelHandler) Flush() error {
+	err := flushHandler(h.main)
+	if e := flushHandler(h.tee); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+//Close closes both handlers, the first error is returned.
+func (h *TeeOnLevelHandler) Close() error {
+	err := h.main.Close()
+	if e := h.tee.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTeeOnLevelHandler(t *testing.T) {
+	var main, stderr bytes.Buffer
+	mh, _ := NewStreamHandler(&main)
+	eh, _ := NewStreamHandler(&stderr)
+
+	h, _ := NewTeeOnLevelHandler(mh, eh, LevelError)
+
+	l := New(h, Llevel)
+	l.Info("hello")
+	l.Error("failed")
+	l.Fatal("exit")
+	l.Sync()
+
+	h.Write([]byte("raw\n"))
+	l.Close()
+
+	if s := main.String(); s != "[Info] hello\n[Error] failed\n[Fatal] exit\nraw\n" {
+		t.Fatal(s)
+	}
+	if s := stderr.String(); s != "[Error] failed\n[Fatal] exit\n" {
+		t.Fatal(s)
+	}
+}
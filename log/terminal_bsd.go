@@ -0,0 +1,9 @@
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package log
+
+import (
+	"syscall"
+)
+
+const ioctlReadTermios = syscall.TIOCGETA
@@ -0,0 +1,7 @@
+package log
+
+import (
+	"syscall"
+)
+
+const ioctlReadTermios = syscall.TCGETS
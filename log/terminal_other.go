@@ -0,0 +1,23 @@
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package log
+
+import (
+	"io"
+	"os"
+)
+
+//isTerminal reports whether w is a character device, which approximates a terminal
+//where it can not be asked, e.g. /dev/null is one too, SetColor(ColorNever) overrides it.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,31 @@
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package log
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+//isTerminal reports whether w is a terminal, by reading its terminal attributes,
+//which only a tty has, so other character devices, e.g. /dev/null, are not.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	c, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var errno syscall.Errno
+	err = c.Control(func(fd uintptr) {
+		var t syscall.Termios
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlReadTermios, uintptr(unsafe.Pointer(&t)))
+	})
+	return err == nil && errno == 0
+}
@@ -0,0 +1,21 @@
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package log
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsTerminalNullDevice(t *testing.T) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	//a character device, but not a terminal
+	if isTerminal(f) {
+		t.Fatal("the null device is not a terminal")
+	}
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+//TestHandler writes logs to a bytes.Buffer, for asserting logs in tests.
+//
+//It is safe for concurrent use, use its String and Contains methods to read
+//logs while logging, the buffer itself is only safe to read after that.
+type TestHandler struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+//NewTestHandler returns a TestHandler and the buffer it writes.
+func NewTestHandler() (*TestHandler, *bytes.Buffer) {
+	h := new(TestHandler)
+
+	h.buf = new(bytes.Buffer)
+
+	return h, h.buf
+}
+
+func (h *TestHandler) Write(p []byte) (n int, err error) {
+	h.mu.Lock()
+	n, err = h.buf.Write(p)
+	h.mu.Unlock()
+	return
+}
+
+//String returns all written logs.
+func (h *TestHandler) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buf.String()
+}
+
+//Contains reports whether substr is within the written logs.
+func (h *TestHandler) Contains(substr string) bool {
+	return strings.Contains(h.String(), substr)
+}
+
+//Reset discards all written logs.
+func (h *TestHandler) Reset() {
+	h.mu.Lock()
+	h.buf.Reset()
+	h.mu.Unlock()
+}
+
+func (h *TestHandler) Close() error {
+	return nil
+}
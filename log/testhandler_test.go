@@ -0,0 +1,23 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestTestHandler(t *testing.T) {
+	h, buf := NewTestHandler()
+
+	l := New(h, Llevel)
+	l.Info("hello world")
+	l.Sync()
+
+	if !h.Contains("[Info] hello") {
+		t.Fatal(h.String())
+	}
+
+	l.Close()
+
+	if buf.String() != "[Info] hello world\n" {
+		t.Fatal(buf.String())
+	}
+}
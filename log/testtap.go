@@ -0,0 +1,105 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//TestTap captures the logs of the default logger as Records, while still writing them
+//to its handler, for tests asserting on logs written deep in the call stack, see InstallTestTap.
+//
+//It is safe for concurrent use.
+type TestTap struct {
+	l    *Logger
+	next Handler
+
+	mu      sync.Mutex
+	records []Record
+}
+
+//InstallTestTap puts a TestTap at the front of the handler chain of the default logger,
+//restore puts the former handler back, e.g.
+//
+//  tap, restore := log.InstallTestTap()
+//  defer restore()
+func InstallTestTap() (tap *TestTap, restore func()) {
+	tap = &TestTap{l: std}
+	tap.next = std.SwapHandler(tap)
+
+	return tap, func() {
+		std.SwapHandler(tap.next)
+	}
+}
+
+//HandleRecord captures a copy of r and passes r on, see RecordHandler.
+func (h *TestTap) HandleRecord(r *Record) error {
+	rec := *r
+	rec.Line = append([]byte(nil), r.Line...)
+
+	h.mu.Lock()
+	h.records = append(h.records, rec)
+	h.mu.Unlock()
+
+	return handleRecord(h.next, r)
+}
+
+//Write captures p as a record with level info, it was not written by a Logger.
+func (h *TestTap) Write(p []byte) (n int, err error) {
+	rec := Record{Level: LevelInfo, Time: time.Now(), Msg: strings.TrimSuffix(string(p), "\n")}
+	rec.Line = append([]byte(nil), p...)
+
+	h.mu.Lock()
+	h.records = append(h.records, rec)
+	h.mu.Unlock()
+
+	return h.next.Write(p)
+}
+
+//Records returns the captured records, after the logs queued by the default logger
+//are written, so logs written before the call are in.
+func (h *TestTap) Records() []Record {
+	h.l.Flush()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]Record(nil), h.records...)
+}
+
+//Contains reports whether a captured record has substr in its message.
+func (h *TestTap) Contains(substr string) bool {
+	for _, r := range h.Records() {
+		if strings.Contains(r.Msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+//Reset discards the captured records.
+func (h *TestTap) Reset() {
+	h.l.Flush()
+
+	h.mu.Lock()
+	h.records = nil
+	h.mu.Unlock()
+}
+
+func (h *TestTap) Sync() error {
+	return syncHandler(h.next)
+}
+
+func (h *TestTap) Flush() error {
+	return flushHandler(h.next)
+}
+
+//Check checks the handler captured logs are written to, see HealthChecker.
+func (h *TestTap) Check() error {
+	return checkHandler(h.next)
+}
+
+//Close closes the handler captured logs are written to.
+func (h *TestTap) Close() error {
+	return h.next.Close()
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestInstallTestTap(t *testing.T) {
+	th, buf := NewTestHandler()
+	old := std.SwapHandler(th)
+	defer std.SwapHandler(old)
+
+	tap, restore := InstallTestTap()
+	//error, an earlier test may have raised the level of std
+	std.WithField("user", "a").Error("deep in the stack")
+
+	rs := tap.Records()
+	if len(rs) != 1 {
+		t.Fatal(rs)
+	}
+	if r := rs[0]; r.Level != LevelError || r.Msg != "deep in the stack" || r.Fields["user"] != "a" {
+		t.Fatal(r)
+	}
+	if !tap.Contains("stack") || tap.Contains("nothing") {
+		t.Fatal("must contain stack only")
+	}
+
+	//still written on
+	if !th.Contains("deep in the stack user=a\n") {
+		t.Fatal(th.String())
+	}
+
+	tap.Reset()
+	restore()
+	std.Error("after")
+	std.Flush()
+
+	if rs := tap.Records(); len(rs) != 0 {
+		t.Fatal(rs)
+	}
+	if !th.Contains("after") {
+		t.Fatal(buf.String())
+	}
+}
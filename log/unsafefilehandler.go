@@ -0,0 +1,86 @@
+package log
+
+import (
+	"os"
+)
+
+//UnsafeFileHandler writes log to a file like FileHandler, but without any locking,
+//for a single writer wanting maximum throughput.
+//
+//It is NOT safe for concurrent use, Write, Sync and Close must not be called
+//concurrently. A Logger writes to its handler from one goroutine, so it is safe
+//as the handler of a single Logger, unless also used elsewhere.
+type UnsafeFileHandler struct {
+	fd *os.File
+
+	fileName string
+	closed   bool
+}
+
+//NewUnsafeFileHandler opens fileName with flag, like NewFileHandler.
+func NewUnsafeFileHandler(fileName string, flag int) (*UnsafeFileHandler, error) {
+	fileName = logPath(fileName)
+	makeParentDir(fileName)
+
+	f, err := os.OpenFile(fileName, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(UnsafeFileHandler)
+
+	h.fd = f
+	h.fileName = fileName
+
+	return h, nil
+}
+
+func (h *UnsafeFileHandler) Write(b []byte) (n int, err error) {
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+	return writeFull(h.fd, b)
+}
+
+//WriteString writes s without converting it to a byte slice.
+func (h *UnsafeFileHandler) WriteString(s string) (n int, err error) {
+	if h.closed {
+		return 0, misuse(ErrHandlerClosed)
+	}
+	return writeStringFull(h.fd, s)
+}
+
+//Sync commits the file to stable storage.
+func (h *UnsafeFileHandler) Sync() error {
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return h.fd.Sync()
+}
+
+//Check checks the file is writable and still the file at its path, see checkFile.
+func (h *UnsafeFileHandler) Check() error {
+	if h.closed {
+		return misuse(ErrHandlerClosed)
+	}
+	return checkFile(h.fd, h.fileName)
+}
+
+//Filename returns the path of the file being written.
+func (h *UnsafeFileHandler) Filename() string {
+	return h.fileName
+}
+
+//Name returns "file:" and the file name.
+func (h *UnsafeFileHandler) Name() string {
+	return "file:" + h.fileName
+}
+
+//Close closes the file, closing it again does nothing.
+func (h *UnsafeFileHandler) Close() error {
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.fd.Close()
+}